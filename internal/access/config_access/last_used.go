@@ -0,0 +1,144 @@
+package configaccess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LastUsedFileName is the file client key last-use times are persisted to.
+const LastUsedFileName = "client-keys-last-used.json"
+
+// lastUsedFlushInterval is how often changed last-use times are written to the state file.
+// Requests only update memory; writing them back to the config file instead would trigger a
+// reload per call.
+const lastUsedFlushInterval = time.Minute
+
+// lastUsed records when each client key id last authenticated a request.
+var lastUsed = &lastUsedTracker{times: make(map[string]time.Time)}
+
+type lastUsedTracker struct {
+	mu        sync.Mutex
+	times     map[string]time.Time
+	statePath string
+	// dirty marks last-use times that have not been written to statePath yet.
+	dirty     bool
+	flushOnce sync.Once
+	// writeMu serializes state file writes, which happen outside mu.
+	writeMu sync.Mutex
+}
+
+func markUsed(id string, at time.Time) {
+	lastUsed.mu.Lock()
+	lastUsed.times[id] = at.UTC()
+	lastUsed.dirty = true
+	lastUsed.mu.Unlock()
+}
+
+// LastUsed reports when the client key with the given id last authenticated a request.
+func LastUsed(id string) (time.Time, bool) {
+	lastUsed.mu.Lock()
+	defer lastUsed.mu.Unlock()
+	at, ok := lastUsed.times[id]
+	return at, ok
+}
+
+// ForgetLastUsed drops usage tracking for a removed client key.
+func ForgetLastUsed(id string) {
+	lastUsed.mu.Lock()
+	defer lastUsed.mu.Unlock()
+	if _, ok := lastUsed.times[id]; ok {
+		delete(lastUsed.times, id)
+		lastUsed.dirty = true
+	}
+}
+
+// SetLastUsedStatePath configures where last-use times are persisted and loads any existing
+// state. Times are written back every minute and on FlushLastUsed.
+func SetLastUsedStatePath(path string) {
+	path = strings.TrimSpace(path)
+	t := lastUsed
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if path == t.statePath {
+		return
+	}
+	t.statePath = path
+	if path == "" {
+		return
+	}
+	t.flushOnce.Do(func() { go t.flushLoop() })
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("client keys: failed to read last-used state %s: %v", path, err)
+		}
+		return
+	}
+	var loaded map[string]time.Time
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		log.Warnf("client keys: failed to parse last-used state %s: %v", path, err)
+		return
+	}
+	for id, at := range loaded {
+		if current, ok := t.times[id]; !ok || at.After(current) {
+			t.times[id] = at
+		}
+	}
+}
+
+// FlushLastUsed writes changed last-use times to the state file. It is called periodically and
+// should be called once more on shutdown.
+func FlushLastUsed() {
+	t := lastUsed
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	t.mu.Lock()
+	path := t.statePath
+	if !t.dirty || path == "" {
+		t.mu.Unlock()
+		return
+	}
+	data, err := json.Marshal(t.times)
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		log.Warnf("client keys: failed to encode last-used state: %v", err)
+		return
+	}
+	if err = writeLastUsedState(path, data); err != nil {
+		log.Warnf("client keys: %v", err)
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+	}
+}
+
+func (t *lastUsedTracker) flushLoop() {
+	ticker := time.NewTicker(lastUsedFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		FlushLastUsed()
+	}
+}
+
+func writeLastUsedState(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create last-used state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write last-used state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace last-used state: %w", err)
+	}
+	return nil
+}
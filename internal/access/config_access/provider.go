@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -20,11 +21,13 @@ func Register() {
 }
 
 type provider struct {
-	name string
-	keys map[string]struct{}
+	name       string
+	keys       map[string]struct{}
+	clientKeys map[string]sdkconfig.ClientKey
+	policies   map[string]*sdkaccess.Policy
 }
 
-func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+func newProvider(cfg *sdkconfig.AccessProvider, root *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
 	name := cfg.Name
 	if name == "" {
 		name = sdkconfig.DefaultAccessProviderName
@@ -36,7 +39,27 @@ func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkacce
 		}
 		keys[key] = struct{}{}
 	}
-	return &provider{name: name, keys: keys}, nil
+	var clientKeys map[string]sdkconfig.ClientKey
+	if root != nil && len(root.ClientKeys) > 0 {
+		clientKeys = make(map[string]sdkconfig.ClientKey, len(root.ClientKeys))
+		for _, entry := range root.ClientKeys {
+			if entry.ID == "" || entry.Hash == "" {
+				continue
+			}
+			clientKeys[entry.Hash] = entry
+		}
+	}
+	var policies map[string]*sdkaccess.Policy
+	if root != nil && len(root.APIKeyPolicies) > 0 {
+		policies = make(map[string]*sdkaccess.Policy, len(root.APIKeyPolicies))
+		for i := range root.APIKeyPolicies {
+			entry := &root.APIKeyPolicies[i]
+			if policy := sdkaccess.PolicyFromConfig(entry); policy != nil {
+				policies[entry.APIKey] = policy
+			}
+		}
+	}
+	return &provider{name: name, keys: keys, clientKeys: clientKeys, policies: policies}, nil
 }
 
 func (p *provider) Identifier() string {
@@ -50,7 +73,7 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	if p == nil {
 		return nil, sdkaccess.ErrNotHandled
 	}
-	if len(p.keys) == 0 {
+	if len(p.keys) == 0 && len(p.clientKeys) == 0 {
 		return nil, sdkaccess.ErrNotHandled
 	}
 	authHeader := r.Header.Get("Authorization")
@@ -90,8 +113,30 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 				Metadata: map[string]string{
 					"source": candidate.source,
 				},
+				Policy: p.policies[candidate.value],
 			}, nil
 		}
+		if len(p.clientKeys) == 0 {
+			continue
+		}
+		entry, ok := p.clientKeys[sdkconfig.HashClientKey(candidate.value)]
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		if entry.Disabled || entry.Expired(now) {
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		markUsed(entry.ID, now)
+		return &sdkaccess.Result{
+			Provider:  p.Identifier(),
+			Principal: entry.ID,
+			Metadata: map[string]string{
+				"source": candidate.source,
+				"key-id": entry.ID,
+			},
+			Policy: p.policies[entry.ID],
+		}, nil
 	}
 
 	return nil, sdkaccess.ErrInvalidCredential
@@ -0,0 +1,67 @@
+package configaccess
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestProviderAuthenticatesHashedClientKeys(t *testing.T) {
+	root := &sdkconfig.SDKConfig{
+		ClientKeys: []sdkconfig.ClientKey{
+			{ID: "ck-active", Hash: sdkconfig.HashClientKey("sk-active")},
+			{ID: "ck-disabled", Hash: sdkconfig.HashClientKey("sk-disabled"), Disabled: true},
+			{ID: "ck-expired", Hash: sdkconfig.HashClientKey("sk-expired"), ExpiresAt: time.Now().Add(-time.Minute)},
+		},
+		APIKeyPolicies: []sdkconfig.APIKeyPolicy{{APIKey: "ck-active", AllowedModels: []string{"gemini-*"}}},
+	}
+	p, err := newProvider(root.InlineAPIKeyProvider(), root)
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer sk-active")
+	res, err := p.Authenticate(req.Context(), req)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if res.Principal != "ck-active" || res.Policy == nil || res.Policy.AllowsModel("claude-sonnet-4") {
+		t.Fatalf("Authenticate() result = %+v", res)
+	}
+	if _, ok := LastUsed("ck-active"); !ok {
+		t.Fatalf("LastUsed() not recorded")
+	}
+
+	for _, key := range []string{"sk-disabled", "sk-expired", "sk-unknown"} {
+		req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("X-Api-Key", key)
+		if _, err = p.Authenticate(req.Context(), req); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+			t.Fatalf("%s: Authenticate() error = %v, want ErrInvalidCredential", key, err)
+		}
+	}
+}
+
+func TestLastUsedPersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LastUsedFileName)
+	SetLastUsedStatePath(path)
+	t.Cleanup(func() { SetLastUsedStatePath("") })
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	markUsed("ck-persisted", at)
+	FlushLastUsed()
+
+	// Simulate a restart: drop the in-memory times and load the state file again.
+	ForgetLastUsed("ck-persisted")
+	SetLastUsedStatePath("")
+	SetLastUsedStatePath(path)
+	if got, ok := LastUsed("ck-persisted"); !ok || !got.Equal(at) {
+		t.Fatalf("LastUsed() after reload = %v, %v; want %v", got, ok, at)
+	}
+}
@@ -0,0 +1,247 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// minRefetchInterval bounds how often an unknown key id may trigger a JWKS refetch.
+const minRefetchInterval = time.Minute
+
+// verificationKey is a parsed JSON Web Key usable for signature verification.
+type verificationKey struct {
+	kid    string
+	alg    string
+	rsa    *rsa.PublicKey
+	ecdsa  *ecdsa.PublicKey
+	secret []byte
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJWKS decodes a JWK set, skipping keys that are unsupported or not meant for signatures.
+func parseJWKS(data []byte) ([]*verificationKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	keys := make([]*verificationKey, 0, len(set.Keys))
+	for i := range set.Keys {
+		jwk := set.Keys[i]
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			log.Debugf("jwt access: skipping jwk %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseJWK(jwk jsonWebKey) (*verificationKey, error) {
+	key := &verificationKey{kid: jwk.Kid, alg: jwk.Alg}
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeSegment(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeSegment(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() <= 1 {
+			return nil, fmt.Errorf("unsupported exponent")
+		}
+		key.rsa = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeSegment(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeSegment(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		key.ecdsa = pub
+	case "oct":
+		secret, err := decodeSegment(jwk.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key material: %w", err)
+		}
+		key.secret = secret
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	return key, nil
+}
+
+// keySet serves verification keys from static configuration, a local JWKS file, or a remote JWKS URL.
+type keySet struct {
+	static   []*verificationKey
+	file     string
+	url      string
+	refresh  time.Duration
+	client   *http.Client
+	mu       sync.Mutex
+	keys     []*verificationKey
+	loadedAt time.Time
+	// loading is closed when the load in flight finishes; nil when no load is running.
+	loading chan struct{}
+}
+
+// lookup returns candidate keys for kid. An unknown kid triggers a rate-limited reload.
+func (s *keySet) lookup(ctx context.Context, kid string) []*verificationKey {
+	s.mu.Lock()
+	stale := s.loadedAt.IsZero() || (s.refresh > 0 && time.Since(s.loadedAt) >= s.refresh)
+	s.mu.Unlock()
+	if stale {
+		s.reload(ctx)
+	}
+
+	s.mu.Lock()
+	matches := s.matchLocked(kid)
+	retry := len(matches) == 0 && kid != "" && time.Since(s.loadedAt) >= minRefetchInterval
+	s.mu.Unlock()
+	if !retry {
+		return matches
+	}
+	s.reload(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.matchLocked(kid)
+}
+
+func (s *keySet) matchLocked(kid string) []*verificationKey {
+	all := make([]*verificationKey, 0, len(s.static)+len(s.keys))
+	all = append(all, s.static...)
+	all = append(all, s.keys...)
+	if kid == "" {
+		return all
+	}
+	out := make([]*verificationKey, 0, 1)
+	for _, key := range all {
+		if key.kid == kid || key.kid == "" {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// reload reads the key source without holding s.mu. Callers arriving while a load is in flight
+// wait for it instead of starting another one.
+func (s *keySet) reload(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	if wait := s.loading; wait != nil {
+		s.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+		}
+		return
+	}
+	if s.file == "" && s.url == "" {
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	s.loading = done
+	s.mu.Unlock()
+
+	// The load is shared by every waiting request, so it must not end with the one that started it.
+	var (
+		data []byte
+		err  error
+	)
+	if s.file != "" {
+		data, err = os.ReadFile(s.file)
+	} else {
+		data, err = s.fetch(context.Background())
+	}
+	var keys []*verificationKey
+	if err == nil {
+		keys, err = parseJWKS(data)
+	}
+
+	s.mu.Lock()
+	// Record the attempt even on failure so a broken source is not hammered.
+	s.loadedAt = time.Now()
+	if err != nil {
+		log.Warnf("jwt access: failed to load jwks: %v", err)
+	} else {
+		s.keys = keys
+	}
+	s.loading = nil
+	s.mu.Unlock()
+	close(done)
+}
+
+func (s *keySet) fetch(ctx context.Context) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("jwt access: close jwks response body: %v", errClose)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func decodeSegment(seg string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+}
@@ -0,0 +1,451 @@
+// Package jwtaccess implements the "jwt" request access provider, which authenticates
+// callers with signed bearer tokens issued by an external identity provider.
+package jwtaccess
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPrincipalClaim = "sub"
+	defaultLeeway         = 30 * time.Second
+	defaultJWKSRefresh    = time.Hour
+	jwksFetchTimeout      = 10 * time.Second
+)
+
+var registerOnce sync.Once
+
+// Register ensures the jwt provider is available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(sdkconfig.AccessProviderTypeJWT, newProvider)
+	})
+}
+
+type provider struct {
+	name           string
+	keys           *keySet
+	algorithms     map[string]struct{}
+	issuer         string
+	audiences      []string
+	leeway         time.Duration
+	requireExpiry  bool
+	principalClaim string
+	metadataClaims []string
+	policies       map[string]*sdkaccess.Policy
+	now            func() time.Time
+}
+
+// newProvider builds a jwt provider from the provider-specific config block:
+//
+//	secret                 HS256 shared secret
+//	jwks-file / jwks-url   JWK set with RSA (RS256), EC P-256 (ES256) or oct (HS256) keys
+//	jwks-refresh-interval  how often a remote JWK set is refetched (default 1h)
+//	issuer, audience       expected iss and aud (audience may be a list)
+//	algorithms             accepted alg values (default HS256, RS256, ES256)
+//	leeway                 clock skew tolerated for exp/nbf/iat (default 30s)
+//	require-expiry         reject tokens without exp (default true)
+//	principal-claim        claim used as the request principal (default sub)
+//	metadata-claims        claims copied into the access metadata
+func newProvider(cfg *sdkconfig.AccessProvider, root *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		name = sdkconfig.AccessProviderTypeJWT
+	}
+	opts := cfg.Config
+
+	keys := &keySet{
+		file:    stringOption(opts, "jwks-file"),
+		url:     stringOption(opts, "jwks-url"),
+		refresh: durationOption(opts, "jwks-refresh-interval", defaultJWKSRefresh),
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+	}
+	if secret := stringOption(opts, "secret"); secret != "" {
+		keys.static = append(keys.static, &verificationKey{secret: []byte(secret)})
+	}
+	if keys.file != "" && keys.url != "" {
+		return nil, fmt.Errorf("jwt access: jwks-file and jwks-url are mutually exclusive")
+	}
+	if len(keys.static) == 0 && keys.file == "" && keys.url == "" {
+		return nil, fmt.Errorf("jwt access: one of secret, jwks-file or jwks-url is required")
+	}
+
+	algorithms := make(map[string]struct{})
+	for _, alg := range stringListOption(opts, "algorithms") {
+		alg = strings.ToUpper(alg)
+		switch alg {
+		case "HS256", "RS256", "ES256":
+			algorithms[alg] = struct{}{}
+		default:
+			return nil, fmt.Errorf("jwt access: unsupported algorithm %q", alg)
+		}
+	}
+	if len(algorithms) == 0 {
+		algorithms = map[string]struct{}{"HS256": {}, "RS256": {}, "ES256": {}}
+	}
+
+	principalClaim := stringOption(opts, "principal-claim")
+	if principalClaim == "" {
+		principalClaim = defaultPrincipalClaim
+	}
+
+	requireExpiry := true
+	if v, ok := boolOption(opts, "require-expiry"); ok {
+		requireExpiry = v
+	}
+
+	p := &provider{
+		name:           name,
+		keys:           keys,
+		algorithms:     algorithms,
+		issuer:         stringOption(opts, "issuer"),
+		audiences:      stringListOption(opts, "audience"),
+		leeway:         durationOption(opts, "leeway", defaultLeeway),
+		requireExpiry:  requireExpiry,
+		principalClaim: principalClaim,
+		metadataClaims: stringListOption(opts, "metadata-claims"),
+		now:            time.Now,
+	}
+	if root != nil && len(root.APIKeyPolicies) > 0 {
+		p.policies = make(map[string]*sdkaccess.Policy, len(root.APIKeyPolicies))
+		for i := range root.APIKeyPolicies {
+			entry := &root.APIKeyPolicies[i]
+			if policy := sdkaccess.PolicyFromConfig(entry); policy != nil {
+				p.policies[entry.APIKey] = policy
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return sdkconfig.AccessProviderTypeJWT
+	}
+	return p.name
+}
+
+func (p *provider) Authenticate(ctx context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil || r == nil {
+		return nil, sdkaccess.ErrNotHandled
+	}
+
+	candidates := []struct {
+		value  string
+		source string
+	}{
+		{extractBearerToken(r.Header.Get("Authorization")), "authorization"},
+		{r.Header.Get("X-Api-Key"), "x-api-key"},
+		{r.Header.Get("X-Goog-Api-Key"), "x-goog-api-key"},
+	}
+
+	for _, candidate := range candidates {
+		if !looksLikeJWT(candidate.value) {
+			continue
+		}
+		claims, err := p.verify(ctx, candidate.value)
+		if err != nil {
+			log.Debugf("jwt access: rejected token from %s: %v", candidate.source, err)
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		principal := claimString(claims[p.principalClaim])
+		if principal == "" {
+			log.Debugf("jwt access: token missing principal claim %q", p.principalClaim)
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		metadata := map[string]string{"source": candidate.source}
+		if iss := claimString(claims["iss"]); iss != "" {
+			metadata["issuer"] = iss
+		}
+		if sub := claimString(claims["sub"]); sub != "" {
+			metadata["subject"] = sub
+		}
+		for _, name := range p.metadataClaims {
+			if value := claimString(claims[name]); value != "" {
+				metadata[name] = value
+			}
+		}
+		return &sdkaccess.Result{
+			Provider:  p.Identifier(),
+			Principal: principal,
+			Metadata:  metadata,
+			Policy:    p.policies[principal],
+		}, nil
+	}
+
+	return nil, sdkaccess.ErrNoCredentials
+}
+
+// verify checks the token signature and registered claims, returning the decoded claim set.
+func (p *provider) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	headerRaw, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err = json.Unmarshal(headerRaw, &header); err != nil {
+		return nil, fmt.Errorf("parse header: %w", err)
+	}
+	if _, ok := p.algorithms[header.Alg]; !ok {
+		return nil, fmt.Errorf("algorithm %q not allowed", header.Alg)
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+
+	verified := false
+	for _, key := range p.keys.lookup(ctx, header.Kid) {
+		if key.alg != "" && key.alg != header.Alg {
+			continue
+		}
+		if verifySignature(header.Alg, key, signingInput, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature verification failed")
+	}
+
+	payloadRaw, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	var claims map[string]any
+	decoder := json.NewDecoder(strings.NewReader(string(payloadRaw)))
+	decoder.UseNumber()
+	if err = decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("parse claims: %w", err)
+	}
+	if err = p.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (p *provider) validateClaims(claims map[string]any) error {
+	now := p.now()
+	if exp, ok := claimTime(claims["exp"]); ok {
+		if now.After(exp.Add(p.leeway)) {
+			return errors.New("token expired")
+		}
+	} else if p.requireExpiry {
+		return errors.New("token has no expiry")
+	}
+	if nbf, ok := claimTime(claims["nbf"]); ok && now.Add(p.leeway).Before(nbf) {
+		return errors.New("token not yet valid")
+	}
+	if iat, ok := claimTime(claims["iat"]); ok && now.Add(p.leeway).Before(iat) {
+		return errors.New("token issued in the future")
+	}
+	if p.issuer != "" && claimString(claims["iss"]) != p.issuer {
+		return fmt.Errorf("unexpected issuer %q", claimString(claims["iss"]))
+	}
+	if len(p.audiences) > 0 {
+		tokenAud := claimStrings(claims["aud"])
+		matched := false
+		for _, want := range p.audiences {
+			for _, got := range tokenAud {
+				if want == got {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return errors.New("audience mismatch")
+		}
+	}
+	return nil
+}
+
+func verifySignature(alg string, key *verificationKey, signingInput, signature []byte) bool {
+	digest := sha256.Sum256(signingInput)
+	switch alg {
+	case "HS256":
+		if len(key.secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS256":
+		if key.rsa == nil {
+			return false
+		}
+		return rsa.VerifyPKCS1v15(key.rsa, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		if key.ecdsa == nil || len(signature) != 64 {
+			return false
+		}
+		rInt := new(big.Int).SetBytes(signature[:32])
+		sInt := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.ecdsa, digest[:], rInt, sInt)
+	default:
+		return false
+	}
+}
+
+func looksLikeJWT(value string) bool {
+	return value != "" && strings.Count(value, ".") == 2
+}
+
+func extractBearerToken(header string) string {
+	if header == "" {
+		return ""
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return header
+	}
+	if strings.ToLower(parts[0]) != "bearer" {
+		return header
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+func claimString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	case []any:
+		return strings.Join(claimStrings(val), ",")
+	default:
+		return ""
+	}
+}
+
+func claimStrings(v any) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s := claimString(item); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func claimTime(v any) (time.Time, bool) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := num.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func stringOption(opts map[string]any, key string) string {
+	if v, ok := opts[key].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+func stringListOption(opts map[string]any, key string) []string {
+	switch val := opts[key].(type) {
+	case string:
+		if trimmed := strings.TrimSpace(val); trimmed != "" {
+			return []string{trimmed}
+		}
+	case []string:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				out = append(out, trimmed)
+			}
+		}
+		return out
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				if trimmed := strings.TrimSpace(s); trimmed != "" {
+					out = append(out, trimmed)
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func boolOption(opts map[string]any, key string) (bool, bool) {
+	switch val := opts[key].(type) {
+	case bool:
+		return val, true
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err == nil {
+			return parsed, true
+		}
+	}
+	return false, false
+}
+
+// durationOption accepts Go duration strings ("90s") or integer seconds.
+func durationOption(opts map[string]any, key string, fallback time.Duration) time.Duration {
+	switch val := opts[key].(type) {
+	case int:
+		return time.Duration(val) * time.Second
+	case int64:
+		return time.Duration(val) * time.Second
+	case float64:
+		return time.Duration(val * float64(time.Second))
+	case string:
+		trimmed := strings.TrimSpace(val)
+		if d, err := time.ParseDuration(trimmed); err == nil {
+			return d
+		}
+		if secs, err := strconv.Atoi(trimmed); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return fallback
+}
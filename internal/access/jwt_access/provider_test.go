@@ -0,0 +1,209 @@
+package jwtaccess
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	input := encodeSegment(t, map[string]any{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func authenticate(t *testing.T, p sdkaccess.Provider, token string) (*sdkaccess.Result, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return p.Authenticate(req.Context(), req)
+}
+
+func TestProviderHS256ClaimsMapping(t *testing.T) {
+	p, err := newProvider(&sdkconfig.AccessProvider{
+		Name: "sso",
+		Type: sdkconfig.AccessProviderTypeJWT,
+		Config: map[string]any{
+			"secret":          "s3cret",
+			"issuer":          "https://idp.example.com/",
+			"audience":        []any{"cli-proxy"},
+			"metadata-claims": []any{"email"},
+		},
+	}, &sdkconfig.SDKConfig{
+		APIKeyPolicies: []sdkconfig.APIKeyPolicy{{APIKey: "alice", AllowedModels: []string{"gemini-*"}}},
+	})
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+
+	token := signHS256(t, "s3cret", map[string]any{
+		"sub":   "alice",
+		"iss":   "https://idp.example.com/",
+		"aud":   "cli-proxy",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "alice@example.com",
+	})
+	res, err := authenticate(t, p, token)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if res.Principal != "alice" || res.Provider != "sso" {
+		t.Fatalf("Authenticate() result = %+v", res)
+	}
+	if res.Metadata["email"] != "alice@example.com" || res.Metadata["issuer"] != "https://idp.example.com/" {
+		t.Fatalf("Authenticate() metadata = %v", res.Metadata)
+	}
+	if res.Policy == nil || res.Policy.AllowsModel("claude-sonnet-4") {
+		t.Fatalf("Authenticate() policy = %+v, want gemini-only policy", res.Policy)
+	}
+}
+
+func TestProviderRejectsInvalidTokens(t *testing.T) {
+	p, err := newProvider(&sdkconfig.AccessProvider{
+		Type:   sdkconfig.AccessProviderTypeJWT,
+		Config: map[string]any{"secret": "s3cret", "audience": "cli-proxy"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+
+	cases := map[string]string{
+		"wrong secret": signHS256(t, "other", map[string]any{"sub": "a", "aud": "cli-proxy", "exp": time.Now().Add(time.Hour).Unix()}),
+		"expired":      signHS256(t, "s3cret", map[string]any{"sub": "a", "aud": "cli-proxy", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":    signHS256(t, "s3cret", map[string]any{"sub": "a", "aud": "cli-proxy"}),
+		"audience":     signHS256(t, "s3cret", map[string]any{"sub": "a", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}),
+	}
+	for name, token := range cases {
+		if _, err = authenticate(t, p, token); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+			t.Fatalf("%s: Authenticate() error = %v, want ErrInvalidCredential", name, err)
+		}
+	}
+
+	if _, err = authenticate(t, p, "sk-plain-api-key"); !errors.Is(err, sdkaccess.ErrNoCredentials) {
+		t.Fatalf("plain key: Authenticate() error = %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestProviderES256FromJWKSFile(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwks := map[string]any{"keys": []any{map[string]any{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": "k1",
+		"x":   base64.RawURLEncoding.EncodeToString(priv.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(priv.Y.FillBytes(make([]byte, 32))),
+	}}}
+	raw, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	p, err := newProvider(&sdkconfig.AccessProvider{
+		Type:   sdkconfig.AccessProviderTypeJWT,
+		Config: map[string]any{"jwks-file": path, "principal-claim": "email"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newProvider() error = %v", err)
+	}
+
+	input := encodeSegment(t, map[string]any{"alg": "ES256", "kid": "k1"}) + "." +
+		encodeSegment(t, map[string]any{"email": "bob@example.com", "exp": time.Now().Add(time.Hour).Unix()})
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	token := input + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	res, err := authenticate(t, p, token)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if res.Principal != "bob@example.com" {
+		t.Fatalf("Principal = %q, want %q", res.Principal, "bob@example.com")
+	}
+}
+
+func TestKeySetSharesRemoteFetchWithoutHoldingLock(t *testing.T) {
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","kid":"k1","k":"c2VjcmV0"}]}`))
+	}))
+	defer server.Close()
+
+	keys := &keySet{url: server.URL, client: server.Client()}
+	var wg sync.WaitGroup
+	results := make([]int, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = len(keys.lookup(context.Background(), "k1"))
+		}(i)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fetches.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	locked := make(chan struct{})
+	go func() {
+		keys.mu.Lock()
+		keys.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("key set lock held during the JWKS fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+	for i, n := range results {
+		if n != 1 {
+			t.Fatalf("lookup %d returned %d keys, want 1", i, n)
+		}
+	}
+}
@@ -52,6 +52,10 @@ func ReconcileProviders(oldCfg, newCfg *config.Config, existing []sdkaccess.Prov
 		}
 
 		forceRebuild := strings.EqualFold(strings.TrimSpace(providerCfg.Type), sdkConfig.AccessProviderTypeConfigAPIKey)
+		if !forceRebuild && strings.EqualFold(strings.TrimSpace(providerCfg.Type), sdkConfig.AccessProviderTypeJWT) {
+			// JWT providers resolve per-principal policies at build time.
+			forceRebuild = !apiKeyPoliciesEqual(oldCfg, newCfg)
+		}
 		if oldCfgProvider, ok := oldCfgMap[key]; ok {
 			isAliased := oldCfgProvider == providerCfg
 			if !forceRebuild && !isAliased && providerConfigEqual(oldCfgProvider, providerCfg) {
@@ -80,37 +84,6 @@ func ReconcileProviders(oldCfg, newCfg *config.Config, existing []sdkaccess.Prov
 		finalIDs[key] = struct{}{}
 	}
 
-	if len(result) == 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(newCfg.APIKeys); inline != nil {
-			key := providerIdentifier(inline)
-			if key != "" {
-				if oldCfgProvider, ok := oldCfgMap[key]; ok {
-					if providerConfigEqual(oldCfgProvider, inline) {
-						if existingProvider, okExisting := existingMap[key]; okExisting {
-							result = append(result, existingProvider)
-							finalIDs[key] = struct{}{}
-							goto inlineDone
-						}
-					}
-				}
-				provider, buildErr := sdkaccess.BuildProvider(inline, &newCfg.SDKConfig)
-				if buildErr != nil {
-					return nil, nil, nil, nil, buildErr
-				}
-				if _, existed := existingMap[key]; existed {
-					appendChange(&updated, key)
-				} else if _, hadOld := oldCfgMap[key]; hadOld {
-					appendChange(&updated, key)
-				} else {
-					appendChange(&added, key)
-				}
-				result = append(result, provider)
-				finalIDs[key] = struct{}{}
-			}
-		}
-	inlineDone:
-	}
-
 	removedSet := make(map[string]struct{})
 	for id := range existingMap {
 		if _, ok := finalIDs[id]; !ok {
@@ -165,29 +138,27 @@ func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider
 	if cfg == nil {
 		return result
 	}
-	for i := range cfg.Access.Providers {
-		providerCfg := &cfg.Access.Providers[i]
-		if providerCfg.Type == "" {
-			continue
-		}
-		key := providerIdentifier(providerCfg)
-		if key == "" {
-			continue
-		}
-		result[key] = providerCfg
-	}
-	if len(result) == 0 && len(cfg.APIKeys) > 0 {
-		if provider := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); provider != nil {
-			if key := providerIdentifier(provider); key != "" {
-				result[key] = provider
-			}
-		}
+	for _, providerCfg := range collectProviderEntries(cfg) {
+		result[providerIdentifier(providerCfg)] = providerCfg
 	}
 	return result
 }
 
 func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
-	entries := make([]*sdkConfig.AccessProvider, 0, len(cfg.Access.Providers))
+	entries := make([]*sdkConfig.AccessProvider, 0, len(cfg.Access.Providers)+1)
+	hasInline := false
+	for i := range cfg.Access.Providers {
+		if strings.EqualFold(strings.TrimSpace(cfg.Access.Providers[i].Type), sdkConfig.AccessProviderTypeConfigAPIKey) {
+			hasInline = true
+			break
+		}
+	}
+	// Top-level api-keys and client-keys are served by an implicit inline provider ahead of declared providers.
+	if !hasInline {
+		if inline := cfg.InlineAPIKeyProvider(); inline != nil {
+			entries = append(entries, inline)
+		}
+	}
 	for i := range cfg.Access.Providers {
 		providerCfg := &cfg.Access.Providers[i]
 		if providerCfg.Type == "" {
@@ -197,11 +168,6 @@ func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
 			entries = append(entries, providerCfg)
 		}
 	}
-	if len(entries) == 0 && len(cfg.APIKeys) > 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); inline != nil {
-			entries = append(entries, inline)
-		}
-	}
 	return entries
 }
 
@@ -244,6 +210,16 @@ func providerConfigEqual(a, b *sdkConfig.AccessProvider) bool {
 	return true
 }
 
+func apiKeyPoliciesEqual(oldCfg, newCfg *config.Config) bool {
+	if oldCfg == nil || newCfg == nil {
+		return oldCfg == nil && newCfg == nil
+	}
+	if len(oldCfg.APIKeyPolicies) != len(newCfg.APIKeyPolicies) {
+		return false
+	}
+	return len(oldCfg.APIKeyPolicies) == 0 || reflect.DeepEqual(oldCfg.APIKeyPolicies, newCfg.APIKeyPolicies)
+}
+
 func stringSetEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,153 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// auditTargetQueryKeys lists query parameters safe to record as the target of a call.
+// Parameters that may carry secrets (for example api-key) are deliberately omitted.
+var auditTargetQueryKeys = []string{"name", "index", "all", "provider"}
+
+// AuditMiddleware records every authenticated mutating management call in the audit log.
+// It must run before Middleware so calls rejected for insufficient role are recorded too.
+func (h *Handler) AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		if h.auditLog == nil {
+			c.Next()
+			return
+		}
+
+		before := snapshotConfig(h.cfg)
+		c.Next()
+
+		identity := c.GetString(ManagementIdentityKey)
+		if identity == "" {
+			// Unauthenticated attempts are covered by the failed-attempt ban logic.
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		status := c.Writer.Status()
+		entry := audit.Entry{
+			Identity: identity,
+			Role:     c.GetString(ManagementRoleKey),
+			RemoteIP: c.ClientIP(),
+			Method:   c.Request.Method,
+			Route:    route,
+			Target:   auditTarget(c),
+			Status:   status,
+			Outcome:  audit.OutcomeSuccess,
+		}
+		if status >= http.StatusBadRequest {
+			entry.Outcome = audit.OutcomeFailure
+		}
+		if after := snapshotConfig(h.cfg); before != nil && after != nil {
+			entry.Changes = diff.BuildConfigChangeDetails(before, after)
+		}
+		if err := h.auditLog.Append(c.Request.Context(), entry); err != nil {
+			log.Errorf("failed to record audit entry for %s %s: %v", entry.Method, entry.Route, err)
+		}
+	}
+}
+
+// FlushAuditLog mirrors audit records not yet persisted to the token store. The server calls
+// it on shutdown.
+func (h *Handler) FlushAuditLog(ctx context.Context) {
+	if h == nil || h.auditLog == nil {
+		return
+	}
+	h.auditLog.Flush(ctx)
+}
+
+// GetAuditLog returns audit entries newest first. Supports offset and limit query parameters.
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	offset := 0
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		offset = value
+	}
+	limit, err := parseLimit(c.Query("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit: " + err.Error()})
+		return
+	}
+	if limit == 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+	entries, total, err := h.auditLog.List(offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"offset":  offset,
+		"limit":   limit,
+	})
+}
+
+func auditTarget(c *gin.Context) map[string]string {
+	target := make(map[string]string)
+	for _, param := range c.Params {
+		target[param.Key] = param.Value
+	}
+	for _, key := range auditTargetQueryKeys {
+		if value := strings.TrimSpace(c.Query(key)); value != "" {
+			target[key] = value
+		}
+	}
+	if len(target) == 0 {
+		return nil
+	}
+	return target
+}
+
+// snapshotConfig deep-copies cfg through YAML so later in-place edits can be diffed.
+func snapshotConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	var out config.Config
+	if err = yaml.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return &out
+}
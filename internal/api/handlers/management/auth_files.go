@@ -262,9 +262,10 @@ func (h *Handler) ListAuthFiles(c *gin.Context) {
 		return
 	}
 	auths := h.authManager.List()
+	tiers := priorityTiers(auths)
 	files := make([]gin.H, 0, len(auths))
 	for _, auth := range auths {
-		if entry := h.buildAuthFileEntry(auth); entry != nil {
+		if entry := h.buildAuthFileEntry(auth, tiers[auth.ID]); entry != nil {
 			files = append(files, entry)
 		}
 	}
@@ -358,7 +359,40 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 	c.JSON(200, gin.H{"files": files})
 }
 
-func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
+// priorityTiers ranks each auth's priority among the distinct priorities of its provider.
+// Tier 1 holds the credentials the weighted routing strategy exhausts first.
+func priorityTiers(auths []*coreauth.Auth) map[string]int {
+	levels := make(map[string][]int)
+	for _, auth := range auths {
+		provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+		priority := auth.Priority()
+		found := false
+		for _, p := range levels[provider] {
+			if p == priority {
+				found = true
+				break
+			}
+		}
+		if !found {
+			levels[provider] = append(levels[provider], priority)
+		}
+	}
+	tiers := make(map[string]int, len(auths))
+	for _, auth := range auths {
+		provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+		priority := auth.Priority()
+		tier := 1
+		for _, p := range levels[provider] {
+			if p > priority {
+				tier++
+			}
+		}
+		tiers[auth.ID] = tier
+	}
+	return tiers
+}
+
+func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth, tier int) gin.H {
 	if auth == nil {
 		return nil
 	}
@@ -389,6 +423,11 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 		"runtime_only":   runtimeOnly,
 		"source":         "memory",
 		"size":           int64(0),
+		"priority":       auth.Priority(),
+		"weight":         auth.Weight(),
+	}
+	if tier > 0 {
+		entry["tier"] = tier
 	}
 	if email := authEmail(auth); email != "" {
 		entry["email"] = email
@@ -430,9 +469,34 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if claims := extractCodexIDTokenClaims(auth); claims != nil {
 		entry["id_token"] = claims
 	}
+	if quota := authQuotaUsage(auth); quota != nil {
+		entry["quota"] = quota
+	}
 	return entry
 }
 
+// authQuotaUsage returns the remaining capacity the provider last reported for the credential
+// and per model, or nil when nothing was reported.
+func authQuotaUsage(auth *coreauth.Auth) gin.H {
+	models := gin.H{}
+	for model, state := range auth.ModelStates {
+		if state != nil && state.Quota.Usage != nil {
+			models[model] = state.Quota.Usage
+		}
+	}
+	if auth.Quota.Usage == nil && len(models) == 0 {
+		return nil
+	}
+	quota := gin.H{}
+	if auth.Quota.Usage != nil {
+		quota["latest"] = auth.Quota.Usage
+	}
+	if len(models) > 0 {
+		quota["models"] = models
+	}
+	return quota
+}
+
 func extractCodexIDTokenClaims(auth *coreauth.Auth) gin.H {
 	if auth == nil || auth.Metadata == nil {
 		return nil
@@ -659,6 +723,77 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
+// findAuthByName resolves an auth by file name or ID.
+func (h *Handler) findAuthByName(name string) (*coreauth.Auth, bool) {
+	if h.authManager == nil || name == "" {
+		return nil, false
+	}
+	for _, auth := range h.authManager.List() {
+		if auth.FileName == name || auth.ID == name {
+			return auth, true
+		}
+	}
+	return nil, false
+}
+
+// PatchAuthFileStatus enables or disables an auth file without removing it.
+func (h *Handler) PatchAuthFileStatus(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	var body struct {
+		Name     string `json:"name"`
+		Disabled *bool  `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" || body.Disabled == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	auth, ok := h.findAuthByName(strings.TrimSpace(body.Name))
+	if !ok {
+		c.JSON(404, gin.H{"error": "auth file not found"})
+		return
+	}
+	auth.Disabled = *body.Disabled
+	if auth.Disabled {
+		auth.Status = coreauth.StatusDisabled
+		auth.StatusMessage = "disabled via management API"
+	} else {
+		auth.Status = coreauth.StatusActive
+		auth.StatusMessage = ""
+	}
+	auth.UpdatedAt = time.Now()
+	if _, err := h.authManager.Update(c.Request.Context(), auth); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to update auth: %v", err)})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "disabled": auth.Disabled})
+}
+
+// RefreshAuthFile refreshes the tokens of an auth file immediately.
+func (h *Handler) RefreshAuthFile(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(400, gin.H{"error": "name is required"})
+		return
+	}
+	auth, ok := h.findAuthByName(name)
+	if !ok {
+		c.JSON(404, gin.H{"error": "auth file not found"})
+		return
+	}
+	if err := h.authManager.RefreshAuth(c.Request.Context(), auth.ID); err != nil {
+		c.JSON(502, gin.H{"error": fmt.Sprintf("refresh failed: %v", err)})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
 func (h *Handler) authIDForPath(path string) string {
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -0,0 +1,189 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// GetBudgets returns the configured client budgets together with current-window consumption.
+func (h *Handler) GetBudgets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"budgets": h.cfg.Budgets,
+		"usage":   budget.Default().Snapshot(),
+	})
+}
+
+// PutBudgets replaces all client budgets.
+func (h *Handler) PutBudgets(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.ClientBudget
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.ClientBudget `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.Budgets = arr
+	h.cfg.SanitizeBudgets()
+	budget.Default().SetConfig(h.cfg.Budgets)
+	h.persist(c)
+}
+
+// PatchBudget updates a single budget selected by index or by api-key and period.
+func (h *Handler) PatchBudget(c *gin.Context) {
+	type budgetPatch struct {
+		APIKey       *string `json:"api-key"`
+		Period       *string `json:"period"`
+		InputTokens  *int64  `json:"input-tokens"`
+		OutputTokens *int64  `json:"output-tokens"`
+		TotalTokens  *int64  `json:"total-tokens"`
+	}
+	var body struct {
+		Index  *int         `json:"index"`
+		Match  *string      `json:"match"`
+		Period *string      `json:"period"`
+		Value  *budgetPatch `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	targetIndex := -1
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.Budgets) {
+		targetIndex = *body.Index
+	}
+	if targetIndex == -1 && body.Match != nil {
+		match := strings.TrimSpace(*body.Match)
+		period := ""
+		if body.Period != nil {
+			period = strings.ToLower(strings.TrimSpace(*body.Period))
+		}
+		for i := range h.cfg.Budgets {
+			if h.cfg.Budgets[i].APIKey == match && (period == "" || h.cfg.Budgets[i].Period == period) {
+				targetIndex = i
+				break
+			}
+		}
+	}
+	if targetIndex == -1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+		return
+	}
+
+	entry := h.cfg.Budgets[targetIndex]
+	if body.Value.APIKey != nil {
+		entry.APIKey = strings.TrimSpace(*body.Value.APIKey)
+	}
+	if body.Value.Period != nil {
+		entry.Period = *body.Value.Period
+	}
+	if body.Value.InputTokens != nil {
+		entry.InputTokens = *body.Value.InputTokens
+	}
+	if body.Value.OutputTokens != nil {
+		entry.OutputTokens = *body.Value.OutputTokens
+	}
+	if body.Value.TotalTokens != nil {
+		entry.TotalTokens = *body.Value.TotalTokens
+	}
+	h.cfg.Budgets[targetIndex] = entry
+	h.cfg.SanitizeBudgets()
+	budget.Default().SetConfig(h.cfg.Budgets)
+	h.persist(c)
+}
+
+// DeleteBudget removes budgets by api-key (optionally narrowed by period) or by index.
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	if val := strings.TrimSpace(c.Query("api-key")); val != "" {
+		period := strings.ToLower(strings.TrimSpace(c.Query("period")))
+		out := make([]config.ClientBudget, 0, len(h.cfg.Budgets))
+		for _, v := range h.cfg.Budgets {
+			if v.APIKey == val && (period == "" || v.Period == period) {
+				continue
+			}
+			out = append(out, v)
+		}
+		h.cfg.Budgets = out
+		budget.Default().SetConfig(h.cfg.Budgets)
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.Budgets) {
+			h.cfg.Budgets = append(h.cfg.Budgets[:idx], h.cfg.Budgets[idx+1:]...)
+			budget.Default().SetConfig(h.cfg.Budgets)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "missing api-key or index"})
+}
+
+// GetBudgetUsage returns current-window consumption for every budgeted client key.
+func (h *Handler) GetBudgetUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"usage": budget.Default().Snapshot()})
+}
+
+// PutBudgetUsage overwrites current-window consumption for a client key and period.
+func (h *Handler) PutBudgetUsage(c *gin.Context) {
+	var body struct {
+		APIKey       string `json:"api-key"`
+		Period       string `json:"period"`
+		InputTokens  int64  `json:"input-tokens"`
+		OutputTokens int64  `json:"output-tokens"`
+		TotalTokens  int64  `json:"total-tokens"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	key := strings.TrimSpace(body.APIKey)
+	period := strings.ToLower(strings.TrimSpace(body.Period))
+	if key == "" || !validBudgetPeriod(period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api-key and a valid period are required"})
+		return
+	}
+	budget.Default().SetUsage(key, period, body.InputTokens, body.OutputTokens, body.TotalTokens)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteBudgetUsage resets consumption for a client key, optionally limited to one period.
+func (h *Handler) DeleteBudgetUsage(c *gin.Context) {
+	key := strings.TrimSpace(c.Query("api-key"))
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing api-key"})
+		return
+	}
+	period := strings.ToLower(strings.TrimSpace(c.Query("period")))
+	if period != "" && !validBudgetPeriod(period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period"})
+		return
+	}
+	budget.Default().Reset(key, period)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func validBudgetPeriod(period string) bool {
+	switch period {
+	case config.BudgetPeriodDaily, config.BudgetPeriodWeekly, config.BudgetPeriodMonthly:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetCircuitBreakers returns the circuit breakers that are open, half-open or counting failures,
+// together with the recent state transition history (newest first).
+func (h *Handler) GetCircuitBreakers(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	breakers, history := h.authManager.CircuitBreakers()
+	if breakers == nil {
+		breakers = []coreauth.BreakerStatus{}
+	}
+	if history == nil {
+		history = []coreauth.BreakerTransition{}
+	}
+	enabled := h.cfg != nil && h.cfg.CircuitBreaker.Enabled
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "breakers": breakers, "history": history})
+}
@@ -0,0 +1,228 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// clientKeyView is the management representation of a client key. It never carries the digest
+// or plaintext; the plaintext is returned only by create and rotate.
+type clientKeyView struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name,omitempty"`
+	Owner      string     `json:"owner,omitempty"`
+	Hint       string     `json:"hint,omitempty"`
+	CreatedAt  *time.Time `json:"created-at,omitempty"`
+	RotatedAt  *time.Time `json:"rotated-at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires-at,omitempty"`
+	LastUsedAt *time.Time `json:"last-used-at,omitempty"`
+	Disabled   bool       `json:"disabled"`
+	Expired    bool       `json:"expired"`
+}
+
+func newClientKeyView(entry *config.ClientKey, now time.Time) clientKeyView {
+	view := clientKeyView{
+		ID:        entry.ID,
+		Name:      entry.Name,
+		Owner:     entry.Owner,
+		Hint:      entry.Hint,
+		CreatedAt: optionalTime(entry.CreatedAt),
+		RotatedAt: optionalTime(entry.RotatedAt),
+		ExpiresAt: optionalTime(entry.ExpiresAt),
+		Disabled:  entry.Disabled,
+		Expired:   entry.Expired(now),
+	}
+	if at, ok := configaccess.LastUsed(entry.ID); ok {
+		view.LastUsedAt = &at
+	}
+	return view
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// parseClientKeyExpiry resolves an absolute RFC3339 expiry or a relative duration such as "720h".
+// An empty expires-at clears the expiry.
+func parseClientKeyExpiry(expiresAt, expiresIn *string, now time.Time) (time.Time, bool, error) {
+	if expiresIn != nil && strings.TrimSpace(*expiresIn) != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(*expiresIn))
+		if err != nil || d <= 0 {
+			return time.Time{}, false, fmt.Errorf("invalid expires-in")
+		}
+		return now.Add(d).UTC(), true, nil
+	}
+	if expiresAt == nil {
+		return time.Time{}, false, nil
+	}
+	raw := strings.TrimSpace(*expiresAt)
+	if raw == "" {
+		return time.Time{}, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid expires-at")
+	}
+	return t.UTC(), true, nil
+}
+
+func (h *Handler) findClientKey(id string) int {
+	id = strings.TrimSpace(id)
+	for i := range h.cfg.ClientKeys {
+		if h.cfg.ClientKeys[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findClientKeyByPlaintext returns the index of the client key whose digest matches plaintext.
+func (h *Handler) findClientKeyByPlaintext(plaintext string) int {
+	hash := config.HashClientKey(strings.TrimSpace(plaintext))
+	for i := range h.cfg.ClientKeys {
+		if h.cfg.ClientKeys[i].Hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// saveClientKeys writes the config and reports failures to the caller without writing
+// a success body, so create and rotate can return the generated plaintext.
+func (h *Handler) saveClientKeys(c *gin.Context) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := config.SaveConfigPreserveComments(h.configFilePath, h.cfg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
+		return false
+	}
+	return true
+}
+
+// GetClientKeys lists client keys with lifecycle metadata and last use.
+func (h *Handler) GetClientKeys(c *gin.Context) {
+	now := time.Now()
+	items := make([]clientKeyView, 0, len(h.cfg.ClientKeys))
+	for i := range h.cfg.ClientKeys {
+		items = append(items, newClientKeyView(&h.cfg.ClientKeys[i], now))
+	}
+	c.JSON(http.StatusOK, gin.H{"client-keys": items})
+}
+
+// CreateClientKey generates a new client key. The plaintext key is only returned in this response.
+func (h *Handler) CreateClientKey(c *gin.Context) {
+	var body struct {
+		Name      string  `json:"name"`
+		Owner     string  `json:"owner"`
+		ExpiresAt *string `json:"expires-at"`
+		ExpiresIn *string `json:"expires-in"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	now := time.Now()
+	expiry, _, err := parseClientKeyExpiry(body.ExpiresAt, body.ExpiresIn, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, plaintext, err := config.NewClientKey("", now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entry.Name = strings.TrimSpace(body.Name)
+	entry.Owner = strings.TrimSpace(body.Owner)
+	entry.ExpiresAt = expiry
+	h.cfg.ClientKeys = append(h.cfg.ClientKeys, entry)
+	if !h.saveClientKeys(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"client-key": newClientKeyView(&entry, now), "key": plaintext})
+}
+
+// RotateClientKey replaces the key material of a client key while keeping its id and settings.
+func (h *Handler) RotateClientKey(c *gin.Context) {
+	idx := h.findClientKey(c.Param("id"))
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client key not found"})
+		return
+	}
+	plaintext, err := config.GenerateClientKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	entry := &h.cfg.ClientKeys[idx]
+	entry.Hash = config.HashClientKey(plaintext)
+	entry.Hint = config.ClientKeyHint(plaintext)
+	entry.RotatedAt = now.UTC()
+	view := newClientKeyView(entry, now)
+	if !h.saveClientKeys(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"client-key": view, "key": plaintext})
+}
+
+// PatchClientKey updates the name, owner, expiry or disabled flag of a client key.
+func (h *Handler) PatchClientKey(c *gin.Context) {
+	var body struct {
+		Name      *string `json:"name"`
+		Owner     *string `json:"owner"`
+		ExpiresAt *string `json:"expires-at"`
+		ExpiresIn *string `json:"expires-in"`
+		Disabled  *bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	idx := h.findClientKey(c.Param("id"))
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client key not found"})
+		return
+	}
+	expiry, setExpiry, err := parseClientKeyExpiry(body.ExpiresAt, body.ExpiresIn, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry := &h.cfg.ClientKeys[idx]
+	if body.Name != nil {
+		entry.Name = strings.TrimSpace(*body.Name)
+	}
+	if body.Owner != nil {
+		entry.Owner = strings.TrimSpace(*body.Owner)
+	}
+	if setExpiry {
+		entry.ExpiresAt = expiry
+	}
+	if body.Disabled != nil {
+		entry.Disabled = *body.Disabled
+	}
+	h.persist(c)
+}
+
+// DeleteClientKey removes a client key.
+func (h *Handler) DeleteClientKey(c *gin.Context) {
+	idx := h.findClientKey(c.Param("id"))
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client key not found"})
+		return
+	}
+	id := h.cfg.ClientKeys[idx].ID
+	h.cfg.ClientKeys = append(h.cfg.ClientKeys[:idx], h.cfg.ClientKeys[idx+1:]...)
+	configaccess.ForgetLastUsed(id)
+	h.persist(c)
+}
@@ -29,9 +29,82 @@ func (h *Handler) GetConfig(c *gin.Context) {
 		return
 	}
 	cfgCopy := *h.cfg
+	if c.GetString(ManagementRoleKey) != config.ManagementRoleAdmin {
+		redacted, err := redactConfig(&cfgCopy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, redacted)
+		return
+	}
 	c.JSON(200, &cfgCopy)
 }
 
+// redactedConfigKeys are the JSON keys of configuration values that carry credentials.
+var redactedConfigKeys = map[string]struct{}{
+	"api-key":           {},
+	"api-keys":          {},
+	"upstream-api-key":  {},
+	"upstream-api-keys": {},
+	"hash":              {},
+	"headers":           {},
+	"proxy-url":         {},
+}
+
+// redactConfig returns the JSON form of cfg with every credential replaced by "<redacted>", for
+// management keys below the admin role.
+func redactConfig(cfg *config.Config) (map[string]any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var tree map[string]any
+	if err = json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	redactConfigValue(tree)
+	return tree, nil
+}
+
+func redactConfigValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if _, secret := redactedConfigKeys[key]; secret {
+				v[key] = maskConfigValue(child)
+				continue
+			}
+			v[key] = redactConfigValue(child)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactConfigValue(v[i])
+		}
+	}
+	return value
+}
+
+// maskConfigValue replaces every non-empty string below value, keeping its shape.
+func maskConfigValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return "<redacted>"
+	case map[string]any:
+		for key, child := range v {
+			v[key] = maskConfigValue(child)
+		}
+	case []any:
+		for i := range v {
+			v[i] = maskConfigValue(v[i])
+		}
+	}
+	return value
+}
+
 type releaseInfo struct {
 	TagName string `json:"tag_name"`
 	Name    string `json:"name"`
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
@@ -105,18 +107,207 @@ func (h *Handler) deleteFromStringList(c *gin.Context, target *[]string, after f
 }
 
 // api-keys
-func (h *Handler) GetAPIKeys(c *gin.Context) { c.JSON(200, gin.H{"api-keys": h.cfg.APIKeys}) }
+//
+// Plaintext api-keys are no longer stored. Keys written through these legacy routes are
+// hashed into client-keys the same way plaintext keys are migrated on load, and existing
+// keys are matched by digest.
+
+// GetAPIKeys reports that plaintext keys can no longer be listed and points to client-keys.
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	c.JSON(410, gin.H{
+		"error":    "plaintext api-keys are no longer stored; list keys through client-keys",
+		"location": managementPrefix + "/client-keys",
+	})
+}
+
+// importAPIKeys adds plaintext keys as client keys, reusing entries that already hold them.
+func (h *Handler) importAPIKeys(c *gin.Context, keys []string) bool {
+	h.cfg.APIKeys = append([]string(nil), keys...)
+	_, err := h.cfg.MigratePlaintextAPIKeys()
+	h.cfg.APIKeys = nil
+	h.cfg.RemoveInlineAPIKeyProviders()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// PutAPIKeys adds the listed keys as client keys. Keys are not removed; use DELETE or the
+// client-keys routes for that.
 func (h *Handler) PutAPIKeys(c *gin.Context) {
-	h.putStringList(c, func(v []string) {
-		h.cfg.APIKeys = append([]string(nil), v...)
-		h.cfg.Access.Providers = nil
-	}, nil)
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []string
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []string `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	if !h.importAPIKeys(c, arr) {
+		return
+	}
+	h.persist(c)
 }
+
+// PatchAPIKeys replaces the key material of the client key matching old with new, keeping its
+// id and settings, or adds new as a client key when old is unknown.
 func (h *Handler) PatchAPIKeys(c *gin.Context) {
-	h.patchStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	var body struct {
+		Old *string `json:"old"`
+		New *string `json:"new"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	if body.Old == nil || body.New == nil || strings.TrimSpace(*body.New) == "" {
+		c.JSON(400, gin.H{"error": "missing old or new"})
+		return
+	}
+	plaintext := strings.TrimSpace(*body.New)
+	if idx := h.findClientKeyByPlaintext(*body.Old); idx >= 0 {
+		entry := &h.cfg.ClientKeys[idx]
+		entry.Hash = config.HashClientKey(plaintext)
+		entry.Hint = config.ClientKeyHint(plaintext)
+		entry.RotatedAt = time.Now().UTC()
+		h.persist(c)
+		return
+	}
+	if !h.importAPIKeys(c, []string{plaintext}) {
+		return
+	}
+	h.persist(c)
 }
+
+// DeleteAPIKeys removes the client key matching ?value=.
 func (h *Handler) DeleteAPIKeys(c *gin.Context) {
-	h.deleteFromStringList(c, &h.cfg.APIKeys, func() { h.cfg.Access.Providers = nil })
+	val := strings.TrimSpace(c.Query("value"))
+	if val == "" {
+		c.JSON(400, gin.H{"error": "missing value"})
+		return
+	}
+	idx := h.findClientKeyByPlaintext(val)
+	if idx < 0 {
+		c.JSON(404, gin.H{"error": "api key not found"})
+		return
+	}
+	id := h.cfg.ClientKeys[idx].ID
+	h.cfg.ClientKeys = append(h.cfg.ClientKeys[:idx], h.cfg.ClientKeys[idx+1:]...)
+	configaccess.ForgetLastUsed(id)
+	h.persist(c)
+}
+
+// api-key-policies: []APIKeyPolicy
+func (h *Handler) GetAPIKeyPolicies(c *gin.Context) {
+	c.JSON(200, gin.H{"api-key-policies": h.cfg.APIKeyPolicies})
+}
+func (h *Handler) PutAPIKeyPolicies(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.APIKeyPolicy
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.APIKeyPolicy `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil || len(obj.Items) == 0 {
+			c.JSON(400, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.APIKeyPolicies = arr
+	h.cfg.SanitizeAPIKeyPolicies()
+	h.persist(c)
+}
+func (h *Handler) PatchAPIKeyPolicy(c *gin.Context) {
+	type apiKeyPolicyPatch struct {
+		APIKey           *string   `json:"api-key"`
+		AllowedModels    *[]string `json:"allowed-models"`
+		AllowedProviders *[]string `json:"allowed-providers"`
+		Prefix           *string   `json:"prefix"`
+		AllowedEndpoints *[]string `json:"allowed-endpoints"`
+	}
+	var body struct {
+		Index *int               `json:"index"`
+		Match *string            `json:"match"`
+		Value *apiKeyPolicyPatch `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(400, gin.H{"error": "invalid body"})
+		return
+	}
+	targetIndex := -1
+	if body.Index != nil && *body.Index >= 0 && *body.Index < len(h.cfg.APIKeyPolicies) {
+		targetIndex = *body.Index
+	}
+	if targetIndex == -1 && body.Match != nil {
+		match := strings.TrimSpace(*body.Match)
+		for i := range h.cfg.APIKeyPolicies {
+			if h.cfg.APIKeyPolicies[i].APIKey == match {
+				targetIndex = i
+				break
+			}
+		}
+	}
+	if targetIndex == -1 {
+		c.JSON(404, gin.H{"error": "item not found"})
+		return
+	}
+
+	entry := h.cfg.APIKeyPolicies[targetIndex]
+	if body.Value.APIKey != nil {
+		entry.APIKey = strings.TrimSpace(*body.Value.APIKey)
+	}
+	if body.Value.AllowedModels != nil {
+		entry.AllowedModels = append([]string(nil), (*body.Value.AllowedModels)...)
+	}
+	if body.Value.AllowedProviders != nil {
+		entry.AllowedProviders = append([]string(nil), (*body.Value.AllowedProviders)...)
+	}
+	if body.Value.Prefix != nil {
+		entry.Prefix = strings.TrimSpace(*body.Value.Prefix)
+	}
+	if body.Value.AllowedEndpoints != nil {
+		entry.AllowedEndpoints = append([]string(nil), (*body.Value.AllowedEndpoints)...)
+	}
+	h.cfg.APIKeyPolicies[targetIndex] = entry
+	h.cfg.SanitizeAPIKeyPolicies()
+	h.persist(c)
+}
+func (h *Handler) DeleteAPIKeyPolicy(c *gin.Context) {
+	if val := c.Query("api-key"); val != "" {
+		out := make([]config.APIKeyPolicy, 0, len(h.cfg.APIKeyPolicies))
+		for _, v := range h.cfg.APIKeyPolicies {
+			if v.APIKey != val {
+				out = append(out, v)
+			}
+		}
+		h.cfg.APIKeyPolicies = out
+		h.persist(c)
+		return
+	}
+	if idxStr := c.Query("index"); idxStr != "" {
+		var idx int
+		_, err := fmt.Sscanf(idxStr, "%d", &idx)
+		if err == nil && idx >= 0 && idx < len(h.cfg.APIKeyPolicies) {
+			h.cfg.APIKeyPolicies = append(h.cfg.APIKeyPolicies[:idx], h.cfg.APIKeyPolicies[idx+1:]...)
+			h.persist(c)
+			return
+		}
+	}
+	c.JSON(400, gin.H{"error": "missing api-key or index"})
 }
 
 // gemini-api-key: []GeminiKey
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -40,6 +41,7 @@ type Handler struct {
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	auditLog            *audit.Log
 }
 
 // NewHandler creates a new management handler instance.
@@ -47,15 +49,23 @@ func NewHandler(cfg *config.Config, configFilePath string, manager *coreauth.Man
 	envSecret, _ := os.LookupEnv("MANAGEMENT_PASSWORD")
 	envSecret = strings.TrimSpace(envSecret)
 
+	tokenStore := sdkAuth.GetTokenStore()
+	var auditLog *audit.Log
+	if configFilePath != "" {
+		persister, _ := tokenStore.(audit.Persister)
+		auditLog = audit.NewLog(filepath.Join(filepath.Dir(configFilePath), audit.FileName), persister)
+	}
+
 	return &Handler{
 		cfg:                 cfg,
 		configFilePath:      configFilePath,
 		failedAttempts:      make(map[string]*attemptInfo),
 		authManager:         manager,
 		usageStats:          usage.GetRequestStatistics(),
-		tokenStore:          sdkAuth.GetTokenStore(),
+		tokenStore:          tokenStore,
 		allowRemoteOverride: envSecret != "",
 		envSecret:           envSecret,
+		auditLog:            auditLog,
 	}
 }
 
@@ -159,7 +169,11 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				h.attemptsMu.Unlock()
 			}
 		}
-		if secretHash == "" && envSecret == "" {
+		var managementKeys []config.ManagementKey
+		if cfg != nil {
+			managementKeys = cfg.RemoteManagement.Keys
+		}
+		if secretHash == "" && envSecret == "" && len(managementKeys) == 0 {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 				"error": "remote management key not set",
 				"hint":  "Set remote-management.secret-key in config.yaml (or MANAGEMENT_PASSWORD) to enable management authentication.",
@@ -192,26 +206,28 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		if localClient {
 			if lp := h.localPassword; lp != "" {
 				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
+					authorizeManagement(c, "local", config.ManagementRoleAdmin)
 					return
 				}
 			}
 		}
 
-		if envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1 {
-			if !localClient {
-				h.attemptsMu.Lock()
-				if ai := h.failedAttempts[clientIP]; ai != nil {
-					ai.count = 0
-					ai.blockedUntil = time.Time{}
+		var identity, role string
+		switch {
+		case envSecret != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(envSecret)) == 1:
+			identity, role = "env", config.ManagementRoleAdmin
+		case secretHash != "" && bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) == nil:
+			identity, role = "secret-key", config.ManagementRoleAdmin
+		default:
+			for i := range managementKeys {
+				if bcrypt.CompareHashAndPassword([]byte(managementKeys[i].Key), []byte(provided)) == nil {
+					identity, role = managementKeys[i].Name, managementKeys[i].Role
+					break
 				}
-				h.attemptsMu.Unlock()
 			}
-			c.Next()
-			return
 		}
 
-		if secretHash == "" || bcrypt.CompareHashAndPassword([]byte(secretHash), []byte(provided)) != nil {
+		if identity == "" {
 			if !localClient {
 				fail()
 			}
@@ -228,7 +244,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 		}
 
-		c.Next()
+		authorizeManagement(c, identity, role)
 	}
 }
 
@@ -0,0 +1,48 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetHealthProbes returns the latest background health probe result per credential.
+func (h *Handler) GetHealthProbes(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	probes := h.authManager.HealthProbes()
+	if probes == nil {
+		probes = []coreauth.HealthProbeStatus{}
+	}
+	enabled := h.cfg != nil && h.cfg.HealthProbe.Enabled
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "probes": probes})
+}
+
+// ProbeAuthFile runs a health probe against an auth file immediately.
+func (h *Handler) ProbeAuthFile(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(400, gin.H{"error": "name is required"})
+		return
+	}
+	auth, ok := h.findAuthByName(name)
+	if !ok {
+		c.JSON(404, gin.H{"error": "auth file not found"})
+		return
+	}
+	status, err := h.authManager.ProbeAuth(c.Request.Context(), auth.ID)
+	if err != nil {
+		c.JSON(422, gin.H{"error": fmt.Sprintf("probe failed: %v", err)})
+		return
+	}
+	c.JSON(200, status)
+}
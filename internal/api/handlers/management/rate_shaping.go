@@ -0,0 +1,17 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRateShaping returns the token buckets credentials are paced with, including the limits
+// learned from provider response headers.
+func (h *Handler) GetRateShaping(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"buckets": h.authManager.RateShapingStats()})
+}
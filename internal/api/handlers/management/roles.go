@@ -0,0 +1,91 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	// ManagementIdentityKey is the gin context key holding the authenticated management key name.
+	ManagementIdentityKey = "managementIdentity"
+	// ManagementRoleKey is the gin context key holding the authenticated management role.
+	ManagementRoleKey = "managementRole"
+)
+
+const managementPrefix = "/v0/management"
+
+// viewerReads are the GET routes viewers may call: usage, statistics, the audit log (whose
+// change details are redacted), the server and error logs, the configuration (with credentials
+// redacted below the admin role) and configuration views that carry no upstream credentials,
+// client keys or request contents. Every other route requires the operator role when listed in
+// operatorRoutes and the admin role otherwise.
+var viewerReads = map[string]struct{}{
+	"/usage":                               {},
+	"/usage/export":                        {},
+	"/audit":                               {},
+	"/routing/stats":                       {},
+	"/circuit-breakers":                    {},
+	"/health-probes":                       {},
+	"/request-queue":                       {},
+	"/rate-shaping":                        {},
+	"/latest-version":                      {},
+	"/config":                              {},
+	"/logs":                                {},
+	"/request-error-logs":                  {},
+	"/auth-files":                          {},
+	"/auth-files/models":                   {},
+	"/debug":                               {},
+	"/logging-to-file":                     {},
+	"/usage-statistics-enabled":            {},
+	"/quota-exceeded/switch-project":       {},
+	"/quota-exceeded/switch-preview-model": {},
+	"/ws-auth":                             {},
+	"/request-retry":                       {},
+	"/max-retry-interval":                  {},
+	"/oauth-excluded-models":               {},
+	"/ampcode/upstream-url":                {},
+	"/ampcode/restrict-management-to-localhost": {},
+	"/ampcode/model-mappings":                   {},
+	"/ampcode/force-model-mappings":             {},
+}
+
+// operatorRoutes are mutating routes that operators may call.
+var operatorRoutes = map[string]struct{}{
+	http.MethodPatch + " /auth-files/status": {},
+	http.MethodPost + " /auth-files/refresh": {},
+	http.MethodPost + " /health-probes":      {},
+}
+
+// requiredManagementRole returns the least privileged role allowed to call the route.
+func requiredManagementRole(method, fullPath string) string {
+	route := strings.TrimPrefix(fullPath, managementPrefix)
+	if method == http.MethodGet || method == http.MethodHead {
+		if _, ok := viewerReads[route]; ok {
+			return config.ManagementRoleViewer
+		}
+		return config.ManagementRoleAdmin
+	}
+	if _, ok := operatorRoutes[method+" "+route]; ok {
+		return config.ManagementRoleOperator
+	}
+	return config.ManagementRoleAdmin
+}
+
+// authorizeManagement records the management identity and continues when its role covers the route.
+func authorizeManagement(c *gin.Context, identity, role string) {
+	c.Set(ManagementIdentityKey, identity)
+	c.Set(ManagementRoleKey, role)
+	required := requiredManagementRole(c.Request.Method, c.FullPath())
+	if config.ManagementRoleRank(role) < config.ManagementRoleRank(required) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":    "insufficient management role",
+			"role":     role,
+			"required": required,
+		})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,72 @@
+package management
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// routingStatView explains how the selector currently sees one credential.
+type routingStatView struct {
+	ID             string                   `json:"id"`
+	Name           string                   `json:"name,omitempty"`
+	Provider       string                   `json:"provider"`
+	Status         coreauth.Status          `json:"status"`
+	Disabled       bool                     `json:"disabled"`
+	Unavailable    bool                     `json:"unavailable"`
+	NextRetryAfter *time.Time               `json:"next_retry_after,omitempty"`
+	Priority       int                      `json:"priority"`
+	Weight         int                      `json:"weight"`
+	InFlight       int                      `json:"in_flight"`
+	Models         []coreauth.ModelLoadStat `json:"models,omitempty"`
+}
+
+// GetRoutingStats returns live in-flight counts, time-to-first-byte averages and availability
+// per credential, as used by the least-load routing strategy.
+func (h *Handler) GetRoutingStats(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	loads := make(map[string]coreauth.LoadStat)
+	for _, stat := range h.authManager.LoadStats() {
+		loads[stat.AuthID] = stat
+	}
+	auths := h.authManager.List()
+	items := make([]routingStatView, 0, len(auths))
+	for _, auth := range auths {
+		load := loads[auth.ID]
+		view := routingStatView{
+			ID:          auth.ID,
+			Name:        strings.TrimSpace(auth.FileName),
+			Provider:    strings.TrimSpace(auth.Provider),
+			Status:      auth.Status,
+			Disabled:    auth.Disabled,
+			Unavailable: auth.Unavailable,
+			Priority:    auth.Priority(),
+			Weight:      auth.Weight(),
+			InFlight:    load.InFlight,
+			Models:      load.Models,
+		}
+		if auth.Unavailable && !auth.NextRetryAfter.IsZero() {
+			next := auth.NextRetryAfter
+			view.NextRetryAfter = &next
+		}
+		items = append(items, view)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Provider != items[j].Provider {
+			return items[i].Provider < items[j].Provider
+		}
+		return items[i].ID < items[j].ID
+	})
+	strategy := ""
+	if h.cfg != nil {
+		strategy = coreauth.NormalizeStrategy(h.cfg.Routing.Strategy)
+	}
+	c.JSON(http.StatusOK, gin.H{"strategy": strategy, "credentials": items})
+}
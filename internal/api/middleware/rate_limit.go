@@ -0,0 +1,124 @@
+// Package middleware provides HTTP middleware components for the CLI Proxy API server.
+// This file contains the inbound rate limiting middleware and the helper that renders
+// throttling errors in the caller's API dialect.
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// RateLimitMiddleware enforces per-client request, token and concurrency limits.
+// It must run after authentication so the client API key is available on the context.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := ""
+		if v, exists := c.Get("apiKey"); exists {
+			if s, ok := v.(string); ok {
+				key = s
+			}
+		}
+
+		release, decision := limiter.Acquire(key)
+		if !decision.Allowed {
+			message := fmt.Sprintf("Rate limit exceeded: %d %s allowed for this API key", decision.Limit, decision.Reason)
+			AbortWithClientError(c, http.StatusTooManyRequests, message, decision.RetryAfter)
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
+
+// AbortWithClientError aborts the request with an error body shaped for the calling API
+// (Gemini for /v1beta, Claude for /v1/messages, OpenAI otherwise). A positive retryAfter
+// is surfaced through the Retry-After header rounded up to whole seconds.
+func AbortWithClientError(c *gin.Context, status int, message string, retryAfter time.Duration) {
+	if c == nil {
+		return
+	}
+	if retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+
+	path := ""
+	if c.Request != nil && c.Request.URL != nil {
+		path = c.Request.URL.Path
+	}
+
+	var body []byte
+	switch {
+	case strings.HasPrefix(path, "/v1beta"):
+		body, _ = json.Marshal(gin.H{
+			"error": gin.H{
+				"code":    status,
+				"message": message,
+				"status":  geminiStatus(status),
+			},
+		})
+	case strings.HasPrefix(path, "/v1/messages"):
+		body, _ = json.Marshal(gin.H{
+			"type": "error",
+			"error": gin.H{
+				"type":    claudeErrorType(status),
+				"message": message,
+			},
+		})
+	default:
+		body = handlers.BuildErrorResponseBody(status, message)
+	}
+
+	c.Abort()
+	c.Data(status, "application/json", body)
+}
+
+func geminiStatus(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "INTERNAL"
+		}
+		return "INVALID_ARGUMENT"
+	}
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "api_error"
+		}
+		return "invalid_request_error"
+	}
+}
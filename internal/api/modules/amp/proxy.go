@@ -15,6 +15,19 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// removeQueryParam drops every value of a query parameter.
+func removeQueryParam(req *http.Request, key string) {
+	if req == nil || req.URL == nil {
+		return
+	}
+	q := req.URL.Query()
+	if _, ok := q[key]; !ok {
+		return
+	}
+	q.Del(key)
+	req.URL.RawQuery = q.Encode()
+}
+
 func removeQueryValuesMatching(req *http.Request, key string, match string) {
 	if req == nil || req.URL == nil || match == "" {
 		return
@@ -80,6 +93,12 @@ func createReverseProxy(upstreamURL string, secretSource SecretSource) (*httputi
 		clientKey := getClientAPIKeyFromContext(req.Context())
 		removeQueryValuesMatching(req, "key", clientKey)
 		removeQueryValuesMatching(req, "auth_token", clientKey)
+		switch getClientCredentialSourceFromContext(req.Context()) {
+		case "query-key":
+			removeQueryParam(req, "key")
+		case "query-auth-token":
+			removeQueryParam(req, "auth_token")
+		}
 
 		// Preserve correlation headers for debugging
 		if req.Header.Get("X-Request-ID") == "" {
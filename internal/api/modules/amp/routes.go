@@ -21,6 +21,10 @@ import (
 // from gin.Context to the request context for SecretSource lookup.
 type clientAPIKeyContextKey struct{}
 
+// clientCredentialSourceContextKey is the context key used to pass where the client
+// credential was read from (e.g. "query-key"), as reported by the access provider.
+type clientCredentialSourceContextKey struct{}
+
 // clientAPIKeyMiddleware injects the authenticated client API key from gin.Context["apiKey"]
 // into the request context so that SecretSource can look it up for per-client upstream routing.
 func clientAPIKeyMiddleware() gin.HandlerFunc {
@@ -33,6 +37,14 @@ func clientAPIKeyMiddleware() gin.HandlerFunc {
 				c.Request = c.Request.WithContext(ctx)
 			}
 		}
+		// Hashed client keys authenticate under their key id, so remember the credential
+		// source to strip query credentials that no longer equal the principal.
+		if metadata, exists := c.Get("accessMetadata"); exists {
+			if meta, ok := metadata.(map[string]string); ok && meta["source"] != "" {
+				ctx := context.WithValue(c.Request.Context(), clientCredentialSourceContextKey{}, meta["source"])
+				c.Request = c.Request.WithContext(ctx)
+			}
+		}
 		c.Next()
 	}
 }
@@ -48,6 +60,15 @@ func getClientAPIKeyFromContext(ctx context.Context) string {
 	return ""
 }
 
+// getClientCredentialSourceFromContext retrieves the client credential source from request context.
+// Returns empty string if not present.
+func getClientCredentialSourceFromContext(ctx context.Context) string {
+	if source, ok := ctx.Value(clientCredentialSourceContextKey{}).(string); ok {
+		return source
+	}
+	return ""
+}
+
 // localhostOnlyMiddleware returns a middleware that dynamically checks the module's
 // localhost restriction setting. This allows hot-reload of the restriction without restarting.
 func (m *AmpModule) localhostOnlyMiddleware() gin.HandlerFunc {
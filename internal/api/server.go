@@ -19,14 +19,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/contextwindow"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/filestore"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsecache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -34,6 +39,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -55,6 +61,29 @@ type serverOptionConfig struct {
 // ServerOption customises HTTP server construction.
 type ServerOption func(*serverOptionConfig)
 
+// statePath resolves where the state file name, such as client budget consumption, is
+// persisted.
+func statePath(configPath, name string) string {
+	if base := util.WritablePath(); base != "" {
+		return filepath.Join(base, name)
+	}
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), name)
+}
+
+// responseCacheDir returns the default directory of the disk response cache backend.
+func responseCacheDir(configPath string) string {
+	if base := util.WritablePath(); base != "" {
+		return filepath.Join(base, "response-cache")
+	}
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "response-cache")
+}
+
 func defaultRequestLoggerFactory(cfg *config.Config, configPath string) logging.RequestLogger {
 	configDir := filepath.Dir(configPath)
 	if base := util.WritablePath(); base != "" {
@@ -257,6 +286,14 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	ratelimit.Default().SetConfig(cfg.RateLimit)
+	budget.Default().SetConfig(cfg.Budgets)
+	budget.Default().SetStatePath(statePath(configFilePath, budget.StateFileName))
+	configaccess.SetLastUsedStatePath(statePath(configFilePath, configaccess.LastUsedFileName))
+	responsecache.Default().SetDefaultDir(responseCacheDir(configFilePath))
+	responsecache.Default().SetStore(sdkAuth.GetTokenStore())
+	responsecache.Default().SetConfig(cfg.ResponseCache)
+	contextwindow.Default().SetConfig(cfg.ContextWindow)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -290,7 +327,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 
 	// Register management routes when configuration or environment secrets are available.
-	hasManagementSecret := cfg.RemoteManagement.SecretKey != "" || envManagementSecret
+	hasManagementSecret := managementKeyConfigured(cfg) || envManagementSecret
 	s.managementRoutesEnabled.Store(hasManagementSecret)
 	if hasManagementSecret {
 		s.registerManagementRoutes()
@@ -329,7 +366,7 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), middleware.RateLimitMiddleware(ratelimit.Default()))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
@@ -341,7 +378,7 @@ func (s *Server) setupRoutes() {
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), middleware.RateLimitMiddleware(ratelimit.Default()))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -499,11 +536,18 @@ func (s *Server) registerManagementRoutes() {
 	log.Info("management routes registered after secret key configuration")
 
 	mgmt := s.engine.Group("/v0/management")
-	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
+	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.AuditMiddleware(), s.mgmt.Middleware())
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/audit", s.mgmt.GetAuditLog)
+		mgmt.GET("/routing/stats", s.mgmt.GetRoutingStats)
+		mgmt.GET("/circuit-breakers", s.mgmt.GetCircuitBreakers)
+		mgmt.GET("/health-probes", s.mgmt.GetHealthProbes)
+		mgmt.POST("/health-probes", s.mgmt.ProbeAuthFile)
+		mgmt.GET("/request-queue", s.mgmt.GetRequestQueue)
+		mgmt.GET("/rate-shaping", s.mgmt.GetRateShaping)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
@@ -541,6 +585,25 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/api-keys", s.mgmt.PatchAPIKeys)
 		mgmt.DELETE("/api-keys", s.mgmt.DeleteAPIKeys)
 
+		mgmt.GET("/client-keys", s.mgmt.GetClientKeys)
+		mgmt.POST("/client-keys", s.mgmt.CreateClientKey)
+		mgmt.POST("/client-keys/:id/rotate", s.mgmt.RotateClientKey)
+		mgmt.PATCH("/client-keys/:id", s.mgmt.PatchClientKey)
+		mgmt.DELETE("/client-keys/:id", s.mgmt.DeleteClientKey)
+
+		mgmt.GET("/api-key-policies", s.mgmt.GetAPIKeyPolicies)
+		mgmt.PUT("/api-key-policies", s.mgmt.PutAPIKeyPolicies)
+		mgmt.PATCH("/api-key-policies", s.mgmt.PatchAPIKeyPolicy)
+		mgmt.DELETE("/api-key-policies", s.mgmt.DeleteAPIKeyPolicy)
+
+		mgmt.GET("/budgets", s.mgmt.GetBudgets)
+		mgmt.PUT("/budgets", s.mgmt.PutBudgets)
+		mgmt.PATCH("/budgets", s.mgmt.PatchBudget)
+		mgmt.DELETE("/budgets", s.mgmt.DeleteBudget)
+		mgmt.GET("/budgets/usage", s.mgmt.GetBudgetUsage)
+		mgmt.PUT("/budgets/usage", s.mgmt.PutBudgetUsage)
+		mgmt.DELETE("/budgets/usage", s.mgmt.DeleteBudgetUsage)
+
 		mgmt.GET("/gemini-api-key", s.mgmt.GetGeminiKeys)
 		mgmt.PUT("/gemini-api-key", s.mgmt.PutGeminiKeys)
 		mgmt.PATCH("/gemini-api-key", s.mgmt.PatchGeminiKey)
@@ -614,6 +677,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
+		mgmt.POST("/auth-files/refresh", s.mgmt.RefreshAuthFile)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
@@ -628,6 +693,11 @@ func (s *Server) registerManagementRoutes() {
 	}
 }
 
+// managementKeyConfigured reports whether the config defines a management secret or named management keys.
+func managementKeyConfigured(cfg *config.Config) bool {
+	return cfg != nil && (cfg.RemoteManagement.SecretKey != "" || len(cfg.RemoteManagement.Keys) > 0)
+}
+
 func (s *Server) managementAvailabilityMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !s.managementRoutesEnabled.Load() {
@@ -816,6 +886,11 @@ func (s *Server) Stop(ctx context.Context) error {
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	budget.Default().Flush()
+	configaccess.FlushLastUsed()
+	if s.mgmt != nil {
+		s.mgmt.FlushAuditLog(ctx)
+	}
 
 	log.Debug("API server stopped")
 	return nil
@@ -920,6 +995,10 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
+	ratelimit.Default().SetConfig(cfg.RateLimit)
+	budget.Default().SetConfig(cfg.Budgets)
+	responsecache.Default().SetConfig(cfg.ResponseCache)
+	contextwindow.Default().SetConfig(cfg.ContextWindow)
 
 	// Update log level dynamically when debug flag changes
 	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
@@ -933,9 +1012,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 	prevSecretEmpty := true
 	if oldCfg != nil {
-		prevSecretEmpty = oldCfg.RemoteManagement.SecretKey == ""
+		prevSecretEmpty = !managementKeyConfigured(oldCfg)
 	}
-	newSecretEmpty := cfg.RemoteManagement.SecretKey == ""
+	newSecretEmpty := !managementKeyConfigured(cfg)
 	if s.envManagementSecret {
 		s.registerManagementRoutes()
 		if s.managementRoutesEnabled.CompareAndSwap(false, true) {
@@ -1045,6 +1124,9 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 				if len(result.Metadata) > 0 {
 					c.Set("accessMetadata", result.Metadata)
 				}
+				if result.Policy != nil {
+					c.Set("accessPolicy", result.Policy)
+				}
 			}
 			c.Next()
 			return
@@ -0,0 +1,220 @@
+// Package audit records mutating management API calls in an append-only log.
+// Records are written as JSON lines to a local file and mirrored in batches to the
+// active token store backend when it supports audit persistence.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FileName is the audit log file name, stored next to the configuration file.
+const FileName = "audit.jsonl"
+
+// persistInterval is how often appended records are mirrored to the token store. Mirroring
+// every record on its own would commit and push, or re-upload the whole log, per call.
+const persistInterval = 10 * time.Second
+
+// Outcome values recorded for each entry.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry describes a single mutating management call.
+type Entry struct {
+	Seq      int64             `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Identity string            `json:"identity,omitempty"`
+	Role     string            `json:"role,omitempty"`
+	RemoteIP string            `json:"remote-ip,omitempty"`
+	Method   string            `json:"method"`
+	Route    string            `json:"route"`
+	Target   map[string]string `json:"target,omitempty"`
+	Changes  []string          `json:"changes,omitempty"`
+	Status   int               `json:"status"`
+	Outcome  string            `json:"outcome"`
+}
+
+// Persister mirrors appended audit records to a token store backend. Backends that keep
+// the audit file on local disk do not need to implement it. records holds the JSON encoded
+// entries appended since the last call, oldest first; path is the local log holding them all.
+type Persister interface {
+	PersistAuditLog(ctx context.Context, path string, records [][]byte) error
+}
+
+// Log is an append-only audit log backed by a JSON lines file.
+type Log struct {
+	mu        sync.Mutex
+	path      string
+	persister Persister
+	seq       int64
+	loaded    bool
+	// pending holds the records not mirrored to the persister yet.
+	pending     [][]byte
+	persistOnce sync.Once
+	// persistMu serializes persister calls, which happen outside mu.
+	persistMu sync.Mutex
+}
+
+// NewLog creates an audit log writing to path and mirroring records through persister (optional).
+func NewLog(path string, persister Persister) *Log {
+	return &Log{path: path, persister: persister}
+}
+
+// Path returns the local audit log file path.
+func (l *Log) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Append assigns a sequence number to entry and records it. The record is mirrored to the
+// persister within persistInterval, or on Flush.
+func (l *Log) Append(_ context.Context, entry Entry) error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.loaded {
+		entries, err := l.readLocked()
+		if err != nil {
+			return err
+		}
+		if n := len(entries); n > 0 {
+			l.seq = entries[n-1].Seq
+		}
+		l.loaded = true
+	}
+	l.seq++
+	entry.Seq = l.seq
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("audit: encode entry: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("audit: create directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: open log: %w", err)
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("audit: write log: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("audit: close log: %w", err)
+	}
+	if l.persister != nil {
+		l.pending = append(l.pending, data)
+		l.persistOnce.Do(func() { go l.persistLoop() })
+	}
+	return nil
+}
+
+// Flush mirrors the pending records to the persister. It is called periodically and should be
+// called once more on shutdown. Records that fail to persist are retried with the next batch.
+func (l *Log) Flush(ctx context.Context) {
+	if l == nil || l.persister == nil {
+		return
+	}
+	l.persistMu.Lock()
+	defer l.persistMu.Unlock()
+	l.mu.Lock()
+	records := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	if len(records) == 0 {
+		return
+	}
+	if err := l.persister.PersistAuditLog(ctx, l.path, records); err != nil {
+		log.Warnf("audit: failed to persist %d records to token store: %v", len(records), err)
+		l.mu.Lock()
+		l.pending = append(records, l.pending...)
+		l.mu.Unlock()
+	}
+}
+
+func (l *Log) persistLoop() {
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.Flush(context.Background())
+	}
+}
+
+// List returns entries newest first, skipping offset entries and returning at most limit.
+// It also reports the total number of entries.
+func (l *Log) List(offset, limit int) ([]Entry, int, error) {
+	if l == nil || l.path == "" {
+		return nil, 0, nil
+	}
+	l.mu.Lock()
+	entries, err := l.readLocked()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(entries)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total || limit <= 0 {
+		return []Entry{}, total, nil
+	}
+	end := total - offset
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	out := make([]Entry, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		out = append(out, entries[i])
+	}
+	return out, total, nil
+}
+
+func (l *Log) readLocked() ([]Entry, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("audit: read log: %w", err)
+	}
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if errUnmarshal := json.Unmarshal(line, &entry); errUnmarshal != nil {
+			log.Debugf("audit: skipping malformed record: %v", errUnmarshal)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("audit: scan log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,381 @@
+// Package budget enforces per-client token budgets that reset on a calendar schedule.
+// Consumption is fed from the core usage record stream and persisted to disk so that
+// budgets survive process restarts.
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// StateFileName is the file used to persist budget consumption.
+const StateFileName = "budgets.json"
+
+// flushInterval is how often changed consumption is written to the state file.
+const flushInterval = 5 * time.Second
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTracker)
+}
+
+// Default returns the shared tracker fed by the global usage manager.
+func Default() *Tracker { return defaultTracker }
+
+// Usage reports the consumption of a client key within the current budget window.
+type Usage struct {
+	APIKey       string    `json:"api-key"`
+	Period       string    `json:"period"`
+	WindowStart  time.Time `json:"window-start"`
+	ResetAt      time.Time `json:"reset-at"`
+	InputTokens  int64     `json:"input-tokens"`
+	OutputTokens int64     `json:"output-tokens"`
+	TotalTokens  int64     `json:"total-tokens"`
+}
+
+// Exceeded describes an exhausted budget.
+type Exceeded struct {
+	APIKey  string
+	Period  string
+	Kind    string
+	Limit   int64
+	Used    int64
+	ResetAt time.Time
+}
+
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("%s %s token budget exhausted (%d/%d), resets at %s", e.Period, e.Kind, e.Used, e.Limit, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+type counter struct {
+	WindowStart  time.Time `json:"window-start"`
+	InputTokens  int64     `json:"input-tokens"`
+	OutputTokens int64     `json:"output-tokens"`
+	TotalTokens  int64     `json:"total-tokens"`
+}
+
+// Tracker accumulates token consumption per client key and period.
+// It implements coreusage.Plugin.
+type Tracker struct {
+	mu        sync.Mutex
+	budgets   map[string][]config.ClientBudget
+	counters  map[string]map[string]*counter
+	statePath string
+	// dirty marks consumption that has not been written to statePath yet.
+	dirty     bool
+	flushOnce sync.Once
+	// writeMu serializes state file writes, which happen outside mu.
+	writeMu sync.Mutex
+	now     func() time.Time
+}
+
+// NewTracker constructs a tracker without budgets or persistence.
+func NewTracker() *Tracker {
+	return &Tracker{
+		budgets:  make(map[string][]config.ClientBudget),
+		counters: make(map[string]map[string]*counter),
+		now:      time.Now,
+	}
+}
+
+// SetConfig replaces the configured budgets. Consumption for keys that remain budgeted is kept.
+func (t *Tracker) SetConfig(entries []config.ClientBudget) {
+	if t == nil {
+		return
+	}
+	budgets := make(map[string][]config.ClientBudget, len(entries))
+	for i := range entries {
+		key := strings.TrimSpace(entries[i].APIKey)
+		if key == "" {
+			continue
+		}
+		budgets[key] = append(budgets[key], entries[i])
+	}
+	t.mu.Lock()
+	t.budgets = budgets
+	t.mu.Unlock()
+}
+
+// SetStatePath configures where consumption is persisted and loads any existing state.
+// Consumption is written back every few seconds and on Flush.
+func (t *Tracker) SetStatePath(path string) {
+	if t == nil {
+		return
+	}
+	path = strings.TrimSpace(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if path == t.statePath {
+		return
+	}
+	t.statePath = path
+	if path == "" {
+		return
+	}
+	t.flushOnce.Do(func() { go t.flushLoop() })
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("budget: failed to read state %s: %v", path, err)
+		}
+		return
+	}
+	var loaded map[string]map[string]*counter
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		log.Warnf("budget: failed to parse state %s: %v", path, err)
+		return
+	}
+	for key, periods := range loaded {
+		for period, c := range periods {
+			if c == nil {
+				continue
+			}
+			t.counterLocked(key, period).merge(c)
+		}
+	}
+}
+
+// Check returns an *Exceeded error when any budget attached to key is exhausted.
+func (t *Tracker) Check(key string) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := t.budgets[key]
+	if len(entries) == 0 {
+		return nil
+	}
+	now := t.now()
+	for i := range entries {
+		entry := entries[i]
+		c := t.currentLocked(key, entry.Period, now)
+		resetAt := nextWindowStart(entry.Period, c.WindowStart)
+		switch {
+		case entry.TotalTokens > 0 && c.TotalTokens >= entry.TotalTokens:
+			return &Exceeded{APIKey: key, Period: entry.Period, Kind: "total", Limit: entry.TotalTokens, Used: c.TotalTokens, ResetAt: resetAt}
+		case entry.InputTokens > 0 && c.InputTokens >= entry.InputTokens:
+			return &Exceeded{APIKey: key, Period: entry.Period, Kind: "input", Limit: entry.InputTokens, Used: c.InputTokens, ResetAt: resetAt}
+		case entry.OutputTokens > 0 && c.OutputTokens >= entry.OutputTokens:
+			return &Exceeded{APIKey: key, Period: entry.Period, Kind: "output", Limit: entry.OutputTokens, Used: c.OutputTokens, ResetAt: resetAt}
+		}
+	}
+	return nil
+}
+
+// HandleUsage implements coreusage.Plugin by charging reported tokens to budgeted keys.
+func (t *Tracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil {
+		return
+	}
+	input := record.Detail.InputTokens
+	output := record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	total := record.Detail.TotalTokens
+	if total <= 0 {
+		total = input + output
+	}
+	if total <= 0 && input <= 0 && output <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := t.budgets[record.APIKey]
+	if len(entries) == 0 {
+		return
+	}
+	now := t.now()
+	for i := range entries {
+		c := t.currentLocked(record.APIKey, entries[i].Period, now)
+		c.InputTokens += input
+		c.OutputTokens += output
+		c.TotalTokens += total
+	}
+	t.dirty = true
+}
+
+// Snapshot lists current-window consumption for every budgeted key, sorted by key and period.
+func (t *Tracker) Snapshot() []Usage {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	out := make([]Usage, 0, len(t.budgets))
+	for key, entries := range t.budgets {
+		for i := range entries {
+			c := t.currentLocked(key, entries[i].Period, now)
+			out = append(out, Usage{
+				APIKey:       key,
+				Period:       entries[i].Period,
+				WindowStart:  c.WindowStart,
+				ResetAt:      nextWindowStart(entries[i].Period, c.WindowStart),
+				InputTokens:  c.InputTokens,
+				OutputTokens: c.OutputTokens,
+				TotalTokens:  c.TotalTokens,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].APIKey != out[j].APIKey {
+			return out[i].APIKey < out[j].APIKey
+		}
+		return out[i].Period < out[j].Period
+	})
+	return out
+}
+
+// SetUsage overwrites the current-window consumption for key and period.
+func (t *Tracker) SetUsage(key, period string, input, output, total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	c := t.currentLocked(key, period, t.now())
+	c.InputTokens = input
+	c.OutputTokens = output
+	c.TotalTokens = total
+	t.dirty = true
+	t.mu.Unlock()
+	t.Flush()
+}
+
+// Reset clears consumption for key. An empty period clears every period of the key.
+func (t *Tracker) Reset(key, period string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if period == "" {
+		delete(t.counters, key)
+	} else if periods, ok := t.counters[key]; ok {
+		delete(periods, period)
+		if len(periods) == 0 {
+			delete(t.counters, key)
+		}
+	}
+	t.dirty = true
+	t.mu.Unlock()
+	t.Flush()
+}
+
+func (t *Tracker) counterLocked(key, period string) *counter {
+	periods, ok := t.counters[key]
+	if !ok {
+		periods = make(map[string]*counter)
+		t.counters[key] = periods
+	}
+	c, ok := periods[period]
+	if !ok {
+		c = &counter{}
+		periods[period] = c
+	}
+	return c
+}
+
+// currentLocked returns the counter for key/period, rolling it over when its window has ended.
+func (t *Tracker) currentLocked(key, period string, now time.Time) *counter {
+	c := t.counterLocked(key, period)
+	start := windowStart(period, now)
+	if !c.WindowStart.Equal(start) {
+		*c = counter{WindowStart: start}
+	}
+	return c
+}
+
+func (c *counter) merge(other *counter) {
+	if other.WindowStart.After(c.WindowStart) {
+		*c = *other
+	}
+}
+
+// Flush writes changed consumption to the state file. It is called periodically and should be
+// called once more on shutdown.
+func (t *Tracker) Flush() {
+	if t == nil {
+		return
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	t.mu.Lock()
+	path := t.statePath
+	if !t.dirty || path == "" {
+		t.mu.Unlock()
+		return
+	}
+	data, err := json.Marshal(t.counters)
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		log.Warnf("budget: failed to encode state: %v", err)
+		return
+	}
+	if err = writeState(path, data); err != nil {
+		log.Warnf("budget: %v", err)
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+	}
+}
+
+func (t *Tracker) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.Flush()
+	}
+}
+
+func writeState(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace state: %w", err)
+	}
+	return nil
+}
+
+// windowStart returns the UTC start of the calendar period containing now.
+func windowStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case config.BudgetPeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case config.BudgetPeriodMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextWindowStart returns the start of the period following the window beginning at start.
+func nextWindowStart(period string, start time.Time) time.Time {
+	switch period {
+	case config.BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case config.BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
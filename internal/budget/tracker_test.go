@@ -0,0 +1,68 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestTrackerRejectsAndResetsDaily(t *testing.T) {
+	now := time.Date(2025, 3, 5, 23, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.SetConfig([]config.ClientBudget{{APIKey: "k", Period: config.BudgetPeriodDaily, TotalTokens: 100}})
+
+	tracker.HandleUsage(context.Background(), coreusage.Record{APIKey: "k", Detail: coreusage.Detail{InputTokens: 70, OutputTokens: 40}})
+
+	err := tracker.Check("k")
+	var exceeded *Exceeded
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("Check() error = %v, want *Exceeded", err)
+	}
+	if want := time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC); !exceeded.ResetAt.Equal(want) {
+		t.Fatalf("ResetAt = %v, want %v", exceeded.ResetAt, want)
+	}
+	if err = tracker.Check("other"); err != nil {
+		t.Fatalf("Check(other) error = %v, want nil", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err = tracker.Check("k"); err != nil {
+		t.Fatalf("Check() after reset error = %v, want nil", err)
+	}
+}
+
+func TestTrackerPersistsState(t *testing.T) {
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), StateFileName)
+	entries := []config.ClientBudget{{APIKey: "k", Period: config.BudgetPeriodMonthly, OutputTokens: 50}}
+
+	first := NewTracker()
+	first.now = func() time.Time { return now }
+	first.SetConfig(entries)
+	first.SetStatePath(path)
+	first.HandleUsage(context.Background(), coreusage.Record{APIKey: "k", Detail: coreusage.Detail{OutputTokens: 30, ReasoningTokens: 25}})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state written on the request path, want it deferred to Flush (stat error %v)", err)
+	}
+	first.Flush()
+
+	second := NewTracker()
+	second.now = func() time.Time { return now }
+	second.SetConfig(entries)
+	second.SetStatePath(path)
+
+	snapshot := second.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].OutputTokens != 55 {
+		t.Fatalf("Snapshot() = %+v, want restored output tokens 55", snapshot)
+	}
+	if err := second.Check("k"); err == nil {
+		t.Fatalf("Check() error = nil, want exhausted budget")
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clientKeyHashPrefix marks a stored client key digest.
+const clientKeyHashPrefix = "sha256:"
+
+// ClientKey is a client API key stored as a digest together with lifecycle metadata.
+// The plaintext key is only returned once, when the key is created or rotated.
+type ClientKey struct {
+	// ID is the stable identifier used as the request principal and in per-client settings
+	// (api-key-policies, rate-limit, budgets, context-window keys, ampcode upstream-api-keys).
+	ID string `yaml:"id" json:"id"`
+	// Name is a human readable label.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Owner identifies the team or person responsible for the key.
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	// Hash is the "sha256:<hex>" digest of the plaintext key.
+	Hash string `yaml:"hash" json:"hash"`
+	// Hint holds the last characters of the plaintext key to help identify it.
+	Hint string `yaml:"hint,omitempty" json:"hint,omitempty"`
+	// CreatedAt records when the key was created.
+	CreatedAt time.Time `yaml:"created-at,omitempty" json:"created-at,omitempty"`
+	// RotatedAt records when the key material was last replaced.
+	RotatedAt time.Time `yaml:"rotated-at,omitempty" json:"rotated-at,omitempty"`
+	// ExpiresAt optionally ends the key's validity.
+	ExpiresAt time.Time `yaml:"expires-at,omitempty" json:"expires-at,omitempty"`
+	// Disabled revokes the key without deleting it.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// Expired reports whether the key has passed its expiry at the given time.
+func (k *ClientKey) Expired(now time.Time) bool {
+	return k != nil && !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// HashClientKey returns the stored digest form of a plaintext client key.
+func HashClientKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return clientKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateClientKey creates a random plaintext client key.
+func GenerateClientKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate client key: %w", err)
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
+
+// GenerateClientKeyID creates a random client key identifier.
+func GenerateClientKeyID() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate client key id: %w", err)
+	}
+	return "ck-" + hex.EncodeToString(buf), nil
+}
+
+// ClientKeyIDFromHash derives the identifier of a key migrated from plaintext api-keys from
+// its digest, so the same plaintext key maps to the same id on every load.
+func ClientKeyIDFromHash(hash string) string {
+	digest := strings.TrimPrefix(hash, clientKeyHashPrefix)
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return "ck-" + digest
+}
+
+// ClientKeyHint returns the trailing characters of plaintext used to recognise a key.
+func ClientKeyHint(plaintext string) string {
+	if len(plaintext) <= 4 {
+		return ""
+	}
+	return "..." + plaintext[len(plaintext)-4:]
+}
+
+// NewClientKey creates a client key entry and returns it with its plaintext value.
+func NewClientKey(plaintext string, now time.Time) (ClientKey, string, error) {
+	var err error
+	if plaintext == "" {
+		if plaintext, err = GenerateClientKey(); err != nil {
+			return ClientKey{}, "", err
+		}
+	}
+	id, err := GenerateClientKeyID()
+	if err != nil {
+		return ClientKey{}, "", err
+	}
+	return ClientKey{
+		ID:        id,
+		Hash:      HashClientKey(plaintext),
+		Hint:      ClientKeyHint(plaintext),
+		CreatedAt: now.UTC(),
+	}, plaintext, nil
+}
+
+// SanitizeClientKeys drops entries without an id or valid digest and removes duplicate ids.
+func (cfg *Config) SanitizeClientKeys() {
+	if cfg == nil || len(cfg.ClientKeys) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.ClientKeys))
+	out := make([]ClientKey, 0, len(cfg.ClientKeys))
+	for i := range cfg.ClientKeys {
+		entry := cfg.ClientKeys[i]
+		entry.ID = strings.TrimSpace(entry.ID)
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.Owner = strings.TrimSpace(entry.Owner)
+		entry.Hash = strings.ToLower(strings.TrimSpace(entry.Hash))
+		if entry.ID == "" || !strings.HasPrefix(entry.Hash, clientKeyHashPrefix) {
+			continue
+		}
+		if _, exists := seen[entry.ID]; exists {
+			continue
+		}
+		seen[entry.ID] = struct{}{}
+		out = append(out, entry)
+	}
+	cfg.ClientKeys = out
+}
+
+// MigratePlaintextAPIKeys replaces plaintext top-level api-keys with hashed client-keys.
+// Migrated ids are derived from the key digest and keys that already have a client-key
+// entry reuse it, so repeated migrations of the same file keep ids stable. References to a
+// migrated key in per-client settings are rewritten to the key id so existing policies,
+// limits, budgets and Amp mappings keep applying. It reports whether the configuration
+// changed.
+func (cfg *Config) MigratePlaintextAPIKeys() (bool, error) {
+	if cfg == nil || len(cfg.APIKeys) == 0 {
+		return false, nil
+	}
+	now := time.Now()
+	ids := make(map[string]string, len(cfg.ClientKeys))
+	hashes := make(map[string]string, len(cfg.ClientKeys))
+	for i := range cfg.ClientKeys {
+		ids[cfg.ClientKeys[i].ID] = cfg.ClientKeys[i].Hash
+		hashes[cfg.ClientKeys[i].Hash] = cfg.ClientKeys[i].ID
+	}
+	renamed := make(map[string]string, len(cfg.APIKeys))
+	for _, raw := range cfg.APIKeys {
+		plaintext := strings.TrimSpace(raw)
+		if plaintext == "" {
+			continue
+		}
+		if _, done := renamed[plaintext]; done {
+			continue
+		}
+		hash := HashClientKey(plaintext)
+		if id, ok := hashes[hash]; ok {
+			renamed[plaintext] = id
+			continue
+		}
+		entry, _, err := NewClientKey(plaintext, now)
+		if err != nil {
+			return false, err
+		}
+		// Keep the random id only in the unlikely case the derived one is taken.
+		if id := ClientKeyIDFromHash(hash); ids[id] == "" {
+			entry.ID = id
+		}
+		entry.Name = "migrated " + entry.Hint
+		cfg.ClientKeys = append(cfg.ClientKeys, entry)
+		ids[entry.ID] = hash
+		hashes[hash] = entry.ID
+		renamed[plaintext] = entry.ID
+	}
+	cfg.APIKeys = nil
+
+	rename := func(value string) string {
+		if id, ok := renamed[strings.TrimSpace(value)]; ok {
+			return id
+		}
+		return value
+	}
+	for i := range cfg.APIKeyPolicies {
+		cfg.APIKeyPolicies[i].APIKey = rename(cfg.APIKeyPolicies[i].APIKey)
+	}
+	for i := range cfg.RateLimit.Keys {
+		cfg.RateLimit.Keys[i].APIKey = rename(cfg.RateLimit.Keys[i].APIKey)
+	}
+	for i := range cfg.Budgets {
+		cfg.Budgets[i].APIKey = rename(cfg.Budgets[i].APIKey)
+	}
+	for i := range cfg.ContextWindow.Keys {
+		cfg.ContextWindow.Keys[i].APIKey = rename(cfg.ContextWindow.Keys[i].APIKey)
+	}
+	for i := range cfg.AmpCode.UpstreamAPIKeys {
+		keys := cfg.AmpCode.UpstreamAPIKeys[i].APIKeys
+		for j := range keys {
+			keys[j] = rename(keys[j])
+		}
+	}
+	return true, nil
+}
@@ -66,6 +66,33 @@ type Config struct {
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// CircuitBreaker holds back credentials that keep failing with timeouts or 5xx responses.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// Cluster shares credential cooldowns and load between replicas using the same Postgres store.
+	Cluster ClusterConfig `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+
+	// RequestQueue parks requests while every credential of their model is cooling down instead of answering 429.
+	RequestQueue RequestQueueConfig `yaml:"request-queue,omitempty" json:"request-queue,omitempty"`
+
+	// Hedging sends a duplicate of slow streaming requests to a second credential for opted-in models.
+	Hedging HedgingConfig `yaml:"hedging,omitempty" json:"hedging,omitempty"`
+
+	// HealthProbe periodically sends a cheap request through every credential to find dead ones before traffic does.
+	HealthProbe HealthProbeConfig `yaml:"health-probe,omitempty" json:"health-probe,omitempty"`
+
+	// RateLimit configures inbound per-client throttling on the public API routes.
+	RateLimit RateLimitConfig `yaml:"rate-limit,omitempty" json:"rate-limit,omitempty"`
+
+	// Budgets defines token budgets per client API key that reset on a daily, weekly or monthly schedule.
+	Budgets []ClientBudget `yaml:"budgets,omitempty" json:"budgets,omitempty"`
+
+	// ResponseCache answers repeated identical non-streaming requests from a cache instead of upstream.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// ContextWindow trims or reroutes requests that exceed the context window of the target model.
+	ContextWindow ContextWindowConfig `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -102,6 +129,10 @@ type Config struct {
 	// gemini-api-key, codex-api-key, claude-api-key, openai-compatibility, vertex-api-key, and ampcode.
 	OAuthModelMappings map[string][]ModelNameMapping `yaml:"oauth-model-mappings,omitempty" json:"oauth-model-mappings,omitempty"`
 
+	// Fallbacks defines cross-model fallback chains used when every credential of a model
+	// is cooling down or out of quota.
+	Fallbacks []ModelFallback `yaml:"fallbacks,omitempty" json:"fallbacks,omitempty"`
+
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
@@ -129,6 +160,42 @@ type RemoteManagement struct {
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
+	// Keys lists additional named management keys with scoped roles. The secret-key keeps admin access.
+	Keys []ManagementKey `yaml:"keys,omitempty"`
+}
+
+// Management key roles, from least to most privileged.
+const (
+	// ManagementRoleViewer may read usage, statistics and settings without credentials.
+	ManagementRoleViewer = "viewer"
+	// ManagementRoleOperator may additionally toggle and refresh auth files.
+	ManagementRoleOperator = "operator"
+	// ManagementRoleAdmin may edit configuration and credentials.
+	ManagementRoleAdmin = "admin"
+)
+
+// ManagementKey is a named management API key bound to a role.
+type ManagementKey struct {
+	// Name identifies the key holder in logs and audit records.
+	Name string `yaml:"name" json:"name"`
+	// Key is the management key (plaintext or bcrypt hashed); plaintext values are hashed on load.
+	Key string `yaml:"key" json:"-"`
+	// Role is one of viewer, operator or admin. Defaults to viewer.
+	Role string `yaml:"role,omitempty" json:"role,omitempty"`
+}
+
+// ManagementRoleRank orders management roles by privilege. Unknown roles rank zero.
+func ManagementRoleRank(role string) int {
+	switch role {
+	case ManagementRoleViewer:
+		return 1
+	case ManagementRoleOperator:
+		return 2
+	case ManagementRoleAdmin:
+		return 3
+	default:
+		return 0
+	}
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -144,8 +211,248 @@ type QuotaExceeded struct {
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "weighted", "least-load".
+	// "weighted" exhausts the highest priority tier first and balances by weight within it.
+	// "least-load" prefers credentials with few in-flight requests and a low time to first byte.
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// SessionAffinity keeps requests of the same session on the same credential while it is
+	// available, preserving upstream prompt caches and thinking signatures.
+	SessionAffinity bool `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+
+	// SessionAffinityTTL is the idle time in seconds after which a session pin is forgotten.
+	// Defaults to 3600 when zero or negative.
+	SessionAffinityTTL int `yaml:"session-affinity-ttl,omitempty" json:"session-affinity-ttl,omitempty"`
+}
+
+// CircuitBreakerConfig configures the per-credential and per-credential-model circuit breakers.
+type CircuitBreakerConfig struct {
+	// Enabled turns the circuit breakers on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// FailureThreshold is the number of consecutive transient failures that opens a breaker.
+	// Defaults to 5.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// OpenSeconds is how long a breaker stays open before a probe request is allowed.
+	// It doubles each time a probe fails, up to MaxOpenSeconds. Defaults to 30.
+	OpenSeconds int `yaml:"open-seconds,omitempty" json:"open-seconds,omitempty"`
+
+	// MaxOpenSeconds caps the open period. Defaults to 600.
+	MaxOpenSeconds int `yaml:"max-open-seconds,omitempty" json:"max-open-seconds,omitempty"`
+}
+
+// ClusterConfig configures state sharing between proxy replicas.
+type ClusterConfig struct {
+	// Enabled shares MarkResult outcomes, cooldowns and in-flight counts with the other replicas.
+	// It requires a token store that supports it (currently the Postgres store).
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// ReplicaID names this replica. Defaults to the host name with a random suffix.
+	ReplicaID string `yaml:"replica-id,omitempty" json:"replica-id,omitempty"`
+}
+
+// RequestQueueConfig configures the queue that holds requests while all credentials of a model cool down.
+type RequestQueueConfig struct {
+	// Enabled turns the request queue on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// OptIn queues only requests sending "X-CPA-Queue: true". Otherwise every request is queued
+	// unless it sends "X-CPA-Queue: false".
+	OptIn bool `yaml:"opt-in,omitempty" json:"opt-in,omitempty"`
+
+	// MaxDepth caps the number of waiting requests. Defaults to 100.
+	MaxDepth int `yaml:"max-depth,omitempty" json:"max-depth,omitempty"`
+
+	// MaxPerClient caps the waiting requests of a single client API key. Defaults to MaxDepth.
+	MaxPerClient int `yaml:"max-per-client,omitempty" json:"max-per-client,omitempty"`
+
+	// TimeoutSeconds is the longest a request waits before the 429 is returned. Defaults to 60.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// HedgingConfig configures request hedging for streaming requests.
+type HedgingConfig struct {
+	// Models lists the models whose streaming requests are hedged.
+	Models []HedgedModel `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// MaxExtraPercent caps hedged duplicates at this share of the hedge-eligible requests.
+	// Defaults to 10, at most 100.
+	MaxExtraPercent int `yaml:"max-extra-percent,omitempty" json:"max-extra-percent,omitempty"`
+}
+
+// HedgedModel opts a model into request hedging.
+type HedgedModel struct {
+	// Model is the requested model name.
+	Model string `yaml:"model" json:"model"`
+
+	// DelayMs is how long the first credential may take to produce its first chunk before the
+	// request is duplicated to a second credential. Defaults to 1000.
+	DelayMs int `yaml:"delay-ms,omitempty" json:"delay-ms,omitempty"`
+}
+
+// HealthProbeConfig configures the background credential health probes.
+type HealthProbeConfig struct {
+	// Enabled turns the health probes on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// IntervalSeconds is the pause between probe rounds. Defaults to 600, minimum 60.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+
+	// TimeoutSeconds bounds a single probe, including any token refresh. Defaults to 30.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// KeepOnAuthFailure marks credentials that fail authentication as erroring instead of disabling them.
+	KeepOnAuthFailure bool `yaml:"keep-on-auth-failure,omitempty" json:"keep-on-auth-failure,omitempty"`
+
+	// Providers overrides the probe model and method per provider.
+	Providers []HealthProbeProvider `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// HealthProbeProvider selects how credentials of one provider are probed.
+type HealthProbeProvider struct {
+	// Provider is the provider identifier, e.g. "gemini-cli", "claude" or "codex".
+	Provider string `yaml:"provider" json:"provider"`
+
+	// Model is the model used for probing. Defaults to the first model registered for the credential.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Method is "count-tokens" or "generate" (a one-token completion). Providers that count
+	// tokens locally default to "generate", all others to "count-tokens".
+	Method string `yaml:"method,omitempty" json:"method,omitempty"`
+}
+
+// RateLimitConfig configures inbound throttling applied per client API key.
+type RateLimitConfig struct {
+	// Default applies to every client key without a dedicated entry in Keys.
+	Default RateLimit `yaml:"default,omitempty" json:"default,omitempty"`
+	// Keys overrides the default limits for specific client API keys.
+	Keys []ClientRateLimit `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// RateLimit describes request, token and concurrency ceilings. Zero disables a limit.
+type RateLimit struct {
+	// RequestsPerMinute caps the number of requests accepted in a sliding one-minute window.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+	// TokensPerMinute caps the total tokens reported by usage records in a sliding one-minute window.
+	TokensPerMinute int `yaml:"tokens-per-minute,omitempty" json:"tokens-per-minute,omitempty"`
+	// MaxConcurrent caps the number of in-flight requests.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+}
+
+// ClientRateLimit binds rate limits to a single client API key.
+type ClientRateLimit struct {
+	// APIKey is the client API key the limits apply to.
+	APIKey    string `yaml:"api-key" json:"api-key"`
+	RateLimit `yaml:",inline"`
+}
+
+// ModelRateLimit binds rate limits to a single upstream model of a credential.
+type ModelRateLimit struct {
+	// Model is the upstream model name the limits apply to.
+	Model     string `yaml:"model" json:"model"`
+	RateLimit `yaml:",inline"`
+}
+
+// Supported response cache backends.
+const (
+	ResponseCacheBackendMemory      = "memory"
+	ResponseCacheBackendDisk        = "disk"
+	ResponseCacheBackendPostgres    = "postgres"
+	ResponseCacheBackendObjectStore = "object-store"
+)
+
+// ResponseCacheConfig configures the exact-match cache of non-streaming responses. Entries are
+// keyed by client API key, model and request body, so clients never see each other's answers.
+type ResponseCacheConfig struct {
+	// Enabled turns the response cache on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Backend is "memory" (default), "disk", "postgres" or "object-store". The last two keep
+	// entries in the Postgres or object storage token store, which must be the one in use.
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// Dir is the directory of the disk backend. Defaults to "response-cache" next to the config file.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// TTLSeconds is how long an answer is served from the cache. Defaults to 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries caps the cached entries. The memory and disk backends evict the least recently
+	// used entry first; the postgres and object-store backends are pruned every minute, oldest
+	// entries first. Defaults to 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// Supported context window strategies.
+const (
+	ContextWindowDropOldest          = "drop-oldest"
+	ContextWindowTruncateToolResults = "truncate-tool-results"
+	ContextWindowRoute               = "route"
+)
+
+// ContextWindowConfig configures how requests that exceed the context window of the target model
+// are handled before dispatch. The policy of a client API key wins over the policy of a model,
+// which wins over the default.
+type ContextWindowConfig struct {
+	// Default applies to every request without a dedicated entry in Keys or Models.
+	Default ContextWindowPolicy `yaml:"default,omitempty" json:"default,omitempty"`
+	// Models overrides the default policy for specific requested models.
+	Models []ModelContextWindowPolicy `yaml:"models,omitempty" json:"models,omitempty"`
+	// Keys overrides the default policy for specific client keys, by id.
+	Keys []ClientContextWindowPolicy `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// ContextWindowPolicy selects what happens to a request that does not fit the context window.
+type ContextWindowPolicy struct {
+	// Strategy is "drop-oldest" (drop the oldest non-system turns), "truncate-tool-results"
+	// (shorten large tool results, oldest first) or "route" (switch to the first model of RouteTo
+	// that fits). Empty leaves requests untouched.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	// ReserveTokens is the room kept for the response when the model only reports a total context
+	// length and the request does not set a maximum output. Defaults to 4096.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+	// ToolResultMaxTokens is the size tool results are truncated to. Defaults to 2000.
+	ToolResultMaxTokens int `yaml:"tool-result-max-tokens,omitempty" json:"tool-result-max-tokens,omitempty"`
+	// RouteTo lists the larger-context models tried in order by the "route" strategy.
+	RouteTo []string `yaml:"route-to,omitempty" json:"route-to,omitempty"`
+}
+
+// ModelContextWindowPolicy binds a context window policy to a requested model.
+type ModelContextWindowPolicy struct {
+	// Model is the requested model name the policy applies to.
+	Model               string `yaml:"model" json:"model"`
+	ContextWindowPolicy `yaml:",inline"`
+}
+
+// ClientContextWindowPolicy binds a context window policy to a client API key.
+type ClientContextWindowPolicy struct {
+	// APIKey is the id of the client key the policy applies to. Plaintext keys are rewritten to
+	// their id when api-keys are migrated to client-keys.
+	APIKey              string `yaml:"api-key" json:"api-key"`
+	ContextWindowPolicy `yaml:",inline"`
+}
+
+// Supported budget periods.
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// ClientBudget caps the tokens a client API key may consume within a calendar period (UTC).
+// Zero disables the corresponding ceiling.
+type ClientBudget struct {
+	// APIKey is the client API key the budget applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+	// Period selects the reset schedule: "daily", "weekly" (Monday) or "monthly".
+	Period string `yaml:"period" json:"period"`
+	// InputTokens caps prompt tokens consumed in the period.
+	InputTokens int64 `yaml:"input-tokens,omitempty" json:"input-tokens,omitempty"`
+	// OutputTokens caps completion tokens (including reasoning) consumed in the period.
+	OutputTokens int64 `yaml:"output-tokens,omitempty" json:"output-tokens,omitempty"`
+	// TotalTokens caps all tokens consumed in the period.
+	TotalTokens int64 `yaml:"total-tokens,omitempty" json:"total-tokens,omitempty"`
 }
 
 // ModelNameMapping defines a model ID rename mapping for a specific channel.
@@ -155,6 +462,15 @@ type ModelNameMapping struct {
 	Alias string `yaml:"alias" json:"alias"`
 }
 
+// ModelFallback defines the ordered models tried when From cannot be served.
+type ModelFallback struct {
+	// From is the requested model name.
+	From string `yaml:"from" json:"from"`
+
+	// To lists the fallback models in the order they are tried. They may belong to other providers.
+	To []string `yaml:"to" json:"to"`
+}
+
 // AmpModelMapping defines a model name mapping for Amp CLI requests.
 // When Amp requests a model that isn't available locally, this mapping
 // allows routing to an alternative model that IS available.
@@ -282,6 +598,18 @@ type ClaudeKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Priority selects the credential tier for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets this credential's share of traffic within its tier (default 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Limits declares the provider limits of this key so requests are paced before they are rejected.
+	Limits RateLimit `yaml:"limits,omitempty" json:"limits,omitempty"`
+
+	// ModelLimits declares limits that apply to individual upstream models of this key.
+	ModelLimits []ModelRateLimit `yaml:"model-limits,omitempty" json:"model-limits,omitempty"`
 }
 
 // ClaudeModel describes a mapping between an alias and the actual upstream model name.
@@ -320,6 +648,18 @@ type CodexKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Priority selects the credential tier for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets this credential's share of traffic within its tier (default 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Limits declares the provider limits of this key so requests are paced before they are rejected.
+	Limits RateLimit `yaml:"limits,omitempty" json:"limits,omitempty"`
+
+	// ModelLimits declares limits that apply to individual upstream models of this key.
+	ModelLimits []ModelRateLimit `yaml:"model-limits,omitempty" json:"model-limits,omitempty"`
 }
 
 // CodexModel describes a mapping between an alias and the actual upstream model name.
@@ -357,6 +697,18 @@ type GeminiKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Priority selects the credential tier for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets this credential's share of traffic within its tier (default 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+
+	// Limits declares the provider limits of this key so requests are paced before they are rejected.
+	Limits RateLimit `yaml:"limits,omitempty" json:"limits,omitempty"`
+
+	// ModelLimits declares limits that apply to individual upstream models of this key.
+	ModelLimits []ModelRateLimit `yaml:"model-limits,omitempty" json:"model-limits,omitempty"`
 }
 
 // GeminiModel describes a mapping between an alias and the actual upstream model name.
@@ -400,6 +752,12 @@ type OpenAICompatibilityAPIKey struct {
 
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Priority selects the credential tier for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets this credential's share of traffic within its tier (default 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // OpenAICompatibilityModel represents a model configuration for OpenAI compatibility,
@@ -492,6 +850,15 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
 	}
 
+	// Hash named management keys and normalize their roles.
+	hashedKeys, errKeys := cfg.sanitizeManagementKeys()
+	if errKeys != nil {
+		return nil, fmt.Errorf("failed to hash management keys: %w", errKeys)
+	}
+	if hashedKeys {
+		cfg.legacyMigrationPending = true
+	}
+
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
 	if cfg.RemoteManagement.PanelGitHubRepository == "" {
 		cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
@@ -504,6 +871,25 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&cfg)
 
+	// Sanitize stored client keys and hash plaintext client API keys into them.
+	cfg.SanitizeClientKeys()
+	migratedKeys, errMigrate := cfg.MigratePlaintextAPIKeys()
+	if errMigrate != nil {
+		return nil, fmt.Errorf("failed to hash client api keys: %w", errMigrate)
+	}
+	if migratedKeys {
+		cfg.legacyMigrationPending = true
+	}
+
+	// Sanitize per-client API key policies.
+	cfg.SanitizeAPIKeyPolicies()
+
+	// Sanitize inbound rate limits: drop negative values and duplicate keys.
+	cfg.SanitizeRateLimits()
+
+	// Sanitize client budgets: normalize periods and drop invalid entries.
+	cfg.SanitizeBudgets()
+
 	// Sanitize Gemini API key configuration and migrate legacy entries.
 	cfg.SanitizeGeminiKeys()
 
@@ -525,6 +911,21 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Normalize global OAuth model name mappings.
 	cfg.SanitizeOAuthModelMappings()
 
+	// Normalize model fallback chains.
+	cfg.SanitizeModelFallbacks()
+
+	// Normalize health probe provider overrides.
+	cfg.SanitizeHealthProbe()
+
+	// Normalize hedged models and the hedging budget.
+	cfg.SanitizeHedging()
+
+	// Normalize the response cache backend and limits.
+	cfg.SanitizeResponseCache()
+
+	// Normalize context window strategies.
+	cfg.SanitizeContextWindow()
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -541,6 +942,210 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	return &cfg, nil
 }
 
+// SanitizeModelFallbacks trims fallback chains, drops entries without a source or targets,
+// removes self references and duplicates, and keeps the first chain declared per model.
+func (cfg *Config) SanitizeModelFallbacks() {
+	if cfg == nil || len(cfg.Fallbacks) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.Fallbacks))
+	out := make([]ModelFallback, 0, len(cfg.Fallbacks))
+	for _, entry := range cfg.Fallbacks {
+		from := strings.TrimSpace(entry.From)
+		fromKey := strings.ToLower(from)
+		if from == "" {
+			continue
+		}
+		if _, exists := seen[fromKey]; exists {
+			continue
+		}
+		targets := make([]string, 0, len(entry.To))
+		seenTarget := map[string]struct{}{fromKey: {}}
+		for _, raw := range entry.To {
+			target := strings.TrimSpace(raw)
+			key := strings.ToLower(target)
+			if target == "" {
+				continue
+			}
+			if _, exists := seenTarget[key]; exists {
+				continue
+			}
+			seenTarget[key] = struct{}{}
+			targets = append(targets, target)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		seen[fromKey] = struct{}{}
+		out = append(out, ModelFallback{From: from, To: targets})
+	}
+	cfg.Fallbacks = out
+}
+
+// SanitizeHedging trims hedged model names, drops empty and duplicate entries and clamps the
+// delays and the extra spend share to valid values.
+func (cfg *Config) SanitizeHedging() {
+	if cfg == nil {
+		return
+	}
+	if cfg.Hedging.MaxExtraPercent < 0 {
+		cfg.Hedging.MaxExtraPercent = 0
+	}
+	if cfg.Hedging.MaxExtraPercent > 100 {
+		cfg.Hedging.MaxExtraPercent = 100
+	}
+	if len(cfg.Hedging.Models) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.Hedging.Models))
+	out := make([]HedgedModel, 0, len(cfg.Hedging.Models))
+	for _, entry := range cfg.Hedging.Models {
+		model := strings.TrimSpace(entry.Model)
+		key := strings.ToLower(model)
+		if model == "" {
+			continue
+		}
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		delay := entry.DelayMs
+		if delay < 0 {
+			delay = 0
+		}
+		out = append(out, HedgedModel{Model: model, DelayMs: delay})
+	}
+	cfg.Hedging.Models = out
+}
+
+// SanitizeResponseCache normalizes the response cache backend name, falling back to the memory
+// backend for unknown names, and clamps negative limits to zero.
+func (cfg *Config) SanitizeResponseCache() {
+	if cfg == nil {
+		return
+	}
+	backend := strings.ToLower(strings.TrimSpace(cfg.ResponseCache.Backend))
+	switch backend {
+	case ResponseCacheBackendMemory, ResponseCacheBackendDisk, ResponseCacheBackendPostgres, ResponseCacheBackendObjectStore:
+	case "pgstore":
+		backend = ResponseCacheBackendPostgres
+	case "objectstore", "object_store":
+		backend = ResponseCacheBackendObjectStore
+	default:
+		backend = ResponseCacheBackendMemory
+	}
+	cfg.ResponseCache.Backend = backend
+	cfg.ResponseCache.Dir = strings.TrimSpace(cfg.ResponseCache.Dir)
+	if cfg.ResponseCache.TTLSeconds < 0 {
+		cfg.ResponseCache.TTLSeconds = 0
+	}
+	if cfg.ResponseCache.MaxEntries < 0 {
+		cfg.ResponseCache.MaxEntries = 0
+	}
+}
+
+// SanitizeContextWindow normalizes strategy names, clearing unknown ones, clamps negative limits
+// to zero and drops entries without a model or key as well as later duplicates.
+func (cfg *Config) SanitizeContextWindow() {
+	if cfg == nil {
+		return
+	}
+	cfg.ContextWindow.Default = sanitizeContextWindowPolicy(cfg.ContextWindow.Default)
+	if len(cfg.ContextWindow.Models) > 0 {
+		seen := make(map[string]struct{}, len(cfg.ContextWindow.Models))
+		out := make([]ModelContextWindowPolicy, 0, len(cfg.ContextWindow.Models))
+		for _, entry := range cfg.ContextWindow.Models {
+			model := strings.TrimSpace(entry.Model)
+			key := strings.ToLower(model)
+			if model == "" {
+				continue
+			}
+			if _, exists := seen[key]; exists {
+				continue
+			}
+			seen[key] = struct{}{}
+			out = append(out, ModelContextWindowPolicy{Model: model, ContextWindowPolicy: sanitizeContextWindowPolicy(entry.ContextWindowPolicy)})
+		}
+		cfg.ContextWindow.Models = out
+	}
+	if len(cfg.ContextWindow.Keys) > 0 {
+		seen := make(map[string]struct{}, len(cfg.ContextWindow.Keys))
+		out := make([]ClientContextWindowPolicy, 0, len(cfg.ContextWindow.Keys))
+		for _, entry := range cfg.ContextWindow.Keys {
+			apiKey := strings.TrimSpace(entry.APIKey)
+			if apiKey == "" {
+				continue
+			}
+			if _, exists := seen[apiKey]; exists {
+				continue
+			}
+			seen[apiKey] = struct{}{}
+			out = append(out, ClientContextWindowPolicy{APIKey: apiKey, ContextWindowPolicy: sanitizeContextWindowPolicy(entry.ContextWindowPolicy)})
+		}
+		cfg.ContextWindow.Keys = out
+	}
+}
+
+func sanitizeContextWindowPolicy(policy ContextWindowPolicy) ContextWindowPolicy {
+	strategy := strings.ToLower(strings.TrimSpace(policy.Strategy))
+	switch strategy {
+	case ContextWindowDropOldest, ContextWindowTruncateToolResults, ContextWindowRoute:
+	case "drop-oldest-turns", "drop":
+		strategy = ContextWindowDropOldest
+	case "truncate", "truncate-tool-result":
+		strategy = ContextWindowTruncateToolResults
+	default:
+		strategy = ""
+	}
+	policy.Strategy = strategy
+	if policy.ReserveTokens < 0 {
+		policy.ReserveTokens = 0
+	}
+	if policy.ToolResultMaxTokens < 0 {
+		policy.ToolResultMaxTokens = 0
+	}
+	if len(policy.RouteTo) > 0 {
+		routeTo := make([]string, 0, len(policy.RouteTo))
+		for _, model := range policy.RouteTo {
+			if model = strings.TrimSpace(model); model != "" {
+				routeTo = append(routeTo, model)
+			}
+		}
+		policy.RouteTo = routeTo
+	}
+	return policy
+}
+
+// SanitizeHealthProbe normalizes provider keys and probe methods, dropping entries without a
+// provider and later duplicates of the same provider.
+func (cfg *Config) SanitizeHealthProbe() {
+	if cfg == nil || len(cfg.HealthProbe.Providers) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.HealthProbe.Providers))
+	out := make([]HealthProbeProvider, 0, len(cfg.HealthProbe.Providers))
+	for _, entry := range cfg.HealthProbe.Providers {
+		provider := strings.ToLower(strings.TrimSpace(entry.Provider))
+		if provider == "" {
+			continue
+		}
+		if _, exists := seen[provider]; exists {
+			continue
+		}
+		seen[provider] = struct{}{}
+		method := strings.ToLower(strings.TrimSpace(entry.Method))
+		switch method {
+		case "count-tokens", "generate":
+		case "count_tokens", "counttokens":
+			method = "count-tokens"
+		default:
+			method = ""
+		}
+		out = append(out, HealthProbeProvider{Provider: provider, Model: strings.TrimSpace(entry.Model), Method: method})
+	}
+	cfg.HealthProbe.Providers = out
+}
+
 // SanitizeOAuthModelMappings normalizes and deduplicates global OAuth model name mappings.
 // It trims whitespace, normalizes channel keys to lower-case, drops empty entries,
 // and ensures (From, To) pairs are unique within each channel.
@@ -621,6 +1226,8 @@ func (cfg *Config) SanitizeCodexKeys() {
 		e.BaseURL = strings.TrimSpace(e.BaseURL)
 		e.Headers = NormalizeHeaders(e.Headers)
 		e.ExcludedModels = NormalizeExcludedModels(e.ExcludedModels)
+		e.Limits = normalizeRateLimit(e.Limits)
+		e.ModelLimits = normalizeModelRateLimits(e.ModelLimits)
 		if e.BaseURL == "" {
 			continue
 		}
@@ -639,6 +1246,8 @@ func (cfg *Config) SanitizeClaudeKeys() {
 		entry.Prefix = normalizeModelPrefix(entry.Prefix)
 		entry.Headers = NormalizeHeaders(entry.Headers)
 		entry.ExcludedModels = NormalizeExcludedModels(entry.ExcludedModels)
+		entry.Limits = normalizeRateLimit(entry.Limits)
+		entry.ModelLimits = normalizeModelRateLimits(entry.ModelLimits)
 	}
 }
 
@@ -661,6 +1270,8 @@ func (cfg *Config) SanitizeGeminiKeys() {
 		entry.ProxyURL = strings.TrimSpace(entry.ProxyURL)
 		entry.Headers = NormalizeHeaders(entry.Headers)
 		entry.ExcludedModels = NormalizeExcludedModels(entry.ExcludedModels)
+		entry.Limits = normalizeRateLimit(entry.Limits)
+		entry.ModelLimits = normalizeModelRateLimits(entry.ModelLimits)
 		if _, exists := seen[entry.APIKey]; exists {
 			continue
 		}
@@ -670,6 +1281,170 @@ func (cfg *Config) SanitizeGeminiKeys() {
 	cfg.GeminiKey = out
 }
 
+// SanitizeAPIKeyPolicies trims policy fields, lower-cases provider keys, normalizes
+// prefixes and drops entries without an API key. Later duplicates of a key are ignored.
+func (cfg *Config) SanitizeAPIKeyPolicies() {
+	if cfg == nil || len(cfg.APIKeyPolicies) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.APIKeyPolicies))
+	out := make([]APIKeyPolicy, 0, len(cfg.APIKeyPolicies))
+	for i := range cfg.APIKeyPolicies {
+		entry := cfg.APIKeyPolicies[i]
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		if entry.APIKey == "" {
+			continue
+		}
+		if _, exists := seen[entry.APIKey]; exists {
+			continue
+		}
+		seen[entry.APIKey] = struct{}{}
+		entry.Prefix = normalizeModelPrefix(entry.Prefix)
+		entry.AllowedModels = normalizeStringList(entry.AllowedModels, false)
+		entry.AllowedProviders = normalizeStringList(entry.AllowedProviders, true)
+		entry.AllowedEndpoints = normalizeStringList(entry.AllowedEndpoints, false)
+		out = append(out, entry)
+	}
+	cfg.APIKeyPolicies = out
+}
+
+// SanitizeRateLimits clamps negative limits to zero and removes client entries
+// without an API key or duplicating an earlier key.
+func (cfg *Config) SanitizeRateLimits() {
+	if cfg == nil {
+		return
+	}
+	cfg.RateLimit.Default = normalizeRateLimit(cfg.RateLimit.Default)
+	if len(cfg.RateLimit.Keys) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.RateLimit.Keys))
+	out := make([]ClientRateLimit, 0, len(cfg.RateLimit.Keys))
+	for i := range cfg.RateLimit.Keys {
+		entry := cfg.RateLimit.Keys[i]
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		if entry.APIKey == "" {
+			continue
+		}
+		if _, exists := seen[entry.APIKey]; exists {
+			continue
+		}
+		seen[entry.APIKey] = struct{}{}
+		entry.RateLimit = normalizeRateLimit(entry.RateLimit)
+		out = append(out, entry)
+	}
+	cfg.RateLimit.Keys = out
+}
+
+// SanitizeBudgets normalizes budget periods, clamps negative ceilings and removes
+// entries without an API key, with an unknown period, or duplicating an earlier key/period pair.
+func (cfg *Config) SanitizeBudgets() {
+	if cfg == nil || len(cfg.Budgets) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.Budgets))
+	out := make([]ClientBudget, 0, len(cfg.Budgets))
+	for i := range cfg.Budgets {
+		entry := cfg.Budgets[i]
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Period = strings.ToLower(strings.TrimSpace(entry.Period))
+		if entry.APIKey == "" {
+			continue
+		}
+		switch entry.Period {
+		case BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+		case "":
+			entry.Period = BudgetPeriodDaily
+		default:
+			continue
+		}
+		id := entry.APIKey + "|" + entry.Period
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+		if entry.InputTokens < 0 {
+			entry.InputTokens = 0
+		}
+		if entry.OutputTokens < 0 {
+			entry.OutputTokens = 0
+		}
+		if entry.TotalTokens < 0 {
+			entry.TotalTokens = 0
+		}
+		out = append(out, entry)
+	}
+	cfg.Budgets = out
+}
+
+func normalizeRateLimit(limit RateLimit) RateLimit {
+	if limit.RequestsPerMinute < 0 {
+		limit.RequestsPerMinute = 0
+	}
+	if limit.TokensPerMinute < 0 {
+		limit.TokensPerMinute = 0
+	}
+	if limit.MaxConcurrent < 0 {
+		limit.MaxConcurrent = 0
+	}
+	return limit
+}
+
+// normalizeModelRateLimits clamps negative limits to zero and removes entries without a
+// model, without any limit, or duplicating an earlier model.
+func normalizeModelRateLimits(entries []ModelRateLimit) []ModelRateLimit {
+	if len(entries) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(entries))
+	out := make([]ModelRateLimit, 0, len(entries))
+	for i := range entries {
+		entry := entries[i]
+		entry.Model = strings.TrimSpace(entry.Model)
+		entry.RateLimit = normalizeRateLimit(entry.RateLimit)
+		if entry.Model == "" || entry.RateLimit == (RateLimit{}) {
+			continue
+		}
+		key := strings.ToLower(entry.Model)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, entry)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// normalizeStringList trims entries, optionally lower-cases them, and removes empties and duplicates.
+func normalizeStringList(values []string, lower bool) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(values))
+	out := make([]string, 0, len(values))
+	for _, raw := range values {
+		trimmed := strings.TrimSpace(raw)
+		if lower {
+			trimmed = strings.ToLower(trimmed)
+		}
+		if trimmed == "" {
+			continue
+		}
+		if _, exists := seen[trimmed]; exists {
+			continue
+		}
+		seen[trimmed] = struct{}{}
+		out = append(out, trimmed)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
 func normalizeModelPrefix(prefix string) string {
 	trimmed := strings.TrimSpace(prefix)
 	trimmed = strings.Trim(trimmed, "/")
@@ -691,7 +1466,45 @@ func syncInlineAccessProvider(cfg *Config) {
 			cfg.APIKeys = append([]string(nil), provider.APIKeys...)
 		}
 	}
-	cfg.Access.Providers = nil
+	// Inline API key providers are represented by top-level api-keys; keep other provider types.
+	cfg.RemoveInlineAPIKeyProviders()
+}
+
+// sanitizeManagementKeys drops management keys without a name or key, defaults roles to viewer
+// and hashes plaintext keys. It reports whether any key was hashed.
+func (cfg *Config) sanitizeManagementKeys() (bool, error) {
+	if cfg == nil || len(cfg.RemoteManagement.Keys) == 0 {
+		return false, nil
+	}
+	hashed := false
+	seen := make(map[string]struct{}, len(cfg.RemoteManagement.Keys))
+	out := make([]ManagementKey, 0, len(cfg.RemoteManagement.Keys))
+	for _, entry := range cfg.RemoteManagement.Keys {
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.Key = strings.TrimSpace(entry.Key)
+		entry.Role = strings.ToLower(strings.TrimSpace(entry.Role))
+		if entry.Name == "" || entry.Key == "" {
+			continue
+		}
+		if _, exists := seen[entry.Name]; exists {
+			continue
+		}
+		seen[entry.Name] = struct{}{}
+		if ManagementRoleRank(entry.Role) == 0 {
+			entry.Role = ManagementRoleViewer
+		}
+		if !looksLikeBcrypt(entry.Key) {
+			value, err := hashSecret(entry.Key)
+			if err != nil {
+				return false, err
+			}
+			entry.Key = value
+			hashed = true
+		}
+		out = append(out, entry)
+	}
+	cfg.RemoteManagement.Keys = out
+	return hashed, nil
 }
 
 // looksLikeBcrypt returns true if the provided string appears to be a bcrypt hash.
@@ -815,7 +1628,8 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 		return fmt.Errorf("expected generated root mapping node")
 	}
 
-	// Remove deprecated sections before merging back the sanitized config.
+	// Remove deprecated sections before merging back the sanitized config. The auth block is
+	// regenerated from the declared providers.
 	removeLegacyAuthBlock(original.Content[0])
 	removeLegacyOpenAICompatAPIKeys(original.Content[0])
 	removeLegacyAmpKeys(original.Content[0])
@@ -854,7 +1668,9 @@ func sanitizeConfigForPersist(cfg *Config) *Config {
 	}
 	clone := *cfg
 	clone.SDKConfig = cfg.SDKConfig
-	clone.SDKConfig.Access = AccessConfig{}
+	// Inline API key providers are persisted as top-level api-keys; declared providers such as
+	// jwt are written back as they are.
+	clone.SDKConfig.RemoveInlineAPIKeyProviders()
 	return &clone
 }
 
@@ -20,11 +20,36 @@ type SDKConfig struct {
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// ClientKeys lists hashed client API keys with lifecycle metadata. Plaintext APIKeys are
+	// migrated here on load.
+	ClientKeys []ClientKey `yaml:"client-keys,omitempty" json:"client-keys,omitempty"`
+
+	// APIKeyPolicies attaches per-client restrictions (models, providers, prefix, endpoints) to entries in APIKeys.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies,omitempty" json:"api-key-policies,omitempty"`
+
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
+
+	// Coalescing attaches identical requests to the upstream call already in flight for them.
+	Coalescing CoalescingConfig `yaml:"coalescing,omitempty" json:"coalescing,omitempty"`
+}
+
+// CoalescingConfig selects which requests are coalesced. Only byte-identical requests of the same
+// client API key to the same endpoint are attached to each other, and only while the first of
+// them is still running; they all receive its response.
+type CoalescingConfig struct {
+	// CountTokens coalesces token counting requests.
+	CountTokens bool `yaml:"count-tokens,omitempty" json:"count-tokens,omitempty"`
+
+	// NonStreaming coalesces non-streaming generation requests.
+	NonStreaming bool `yaml:"non-streaming,omitempty" json:"non-streaming,omitempty"`
+
+	// Streaming coalesces streaming requests. An attached client first receives the chunks sent
+	// so far and then follows the live stream.
+	Streaming bool `yaml:"streaming,omitempty" json:"streaming,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -37,6 +62,34 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// FailoverModels lists models whose text-only streams are continued on another credential when
+	// the upstream stream breaks after output was sent. Supports '*' wildcards. Empty disables it.
+	// Continuations are only sent to Claude and Gemini upstreams, which support assistant prefill.
+	FailoverModels []string `yaml:"failover-models,omitempty" json:"failover-models,omitempty"`
+
+	// MaxFailovers caps how many times a single stream may be continued. Default is 1.
+	MaxFailovers int `yaml:"max-failovers,omitempty" json:"max-failovers,omitempty"`
+}
+
+// APIKeyPolicy restricts what a single client API key may access.
+// Empty lists place no restriction on the corresponding dimension.
+type APIKeyPolicy struct {
+	// APIKey is the client key (from api-keys) this policy applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// AllowedModels lists model name patterns the key may request. Supports '*' wildcards (e.g. "claude-*").
+	AllowedModels []string `yaml:"allowed-models,omitempty" json:"allowed-models,omitempty"`
+
+	// AllowedProviders restricts execution to the listed provider keys (e.g. "claude", "gemini-cli").
+	AllowedProviders []string `yaml:"allowed-providers,omitempty" json:"allowed-providers,omitempty"`
+
+	// Prefix binds the key to credentials registered under this model prefix (e.g. "teamA").
+	// Requests are rewritten to "<prefix>/<model>" before credential selection.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// AllowedEndpoints lists request path patterns the key may call (e.g. "/v1/messages", "/v1beta/*").
+	AllowedEndpoints []string `yaml:"allowed-endpoints,omitempty" json:"allowed-endpoints,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
@@ -67,6 +120,9 @@ const (
 	// AccessProviderTypeConfigAPIKey is the built-in provider validating inline API keys.
 	AccessProviderTypeConfigAPIKey = "config-api-key"
 
+	// AccessProviderTypeJWT is the built-in provider validating signed bearer tokens (JWT/OIDC).
+	AccessProviderTypeJWT = "jwt"
+
 	// DefaultAccessProviderName is applied when no provider name is supplied.
 	DefaultAccessProviderName = "config-inline"
 )
@@ -87,6 +143,38 @@ func (c *SDKConfig) ConfigAPIKeyProvider() *AccessProvider {
 	return nil
 }
 
+// RemoveInlineAPIKeyProviders drops declared inline API key providers, which top-level api-keys
+// replace, and keeps every other provider type.
+func (c *SDKConfig) RemoveInlineAPIKeyProviders() {
+	if c == nil {
+		return
+	}
+	var kept []AccessProvider
+	for i := range c.Access.Providers {
+		if c.Access.Providers[i].Type == AccessProviderTypeConfigAPIKey {
+			continue
+		}
+		kept = append(kept, c.Access.Providers[i])
+	}
+	c.Access.Providers = kept
+}
+
+// InlineAPIKeyProvider returns the implicit provider serving top-level api-keys and client-keys.
+// It returns nil when neither is configured.
+func (c *SDKConfig) InlineAPIKeyProvider() *AccessProvider {
+	if c == nil {
+		return nil
+	}
+	if len(c.APIKeys) == 0 && len(c.ClientKeys) == 0 {
+		return nil
+	}
+	return &AccessProvider{
+		Name:    DefaultAccessProviderName,
+		Type:    AccessProviderTypeConfigAPIKey,
+		APIKeys: append([]string(nil), c.APIKeys...),
+	}
+}
+
 // MakeInlineAPIKeyProvider constructs an inline API key provider configuration.
 // It returns nil when no keys are supplied.
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
@@ -30,6 +30,12 @@ type VertexCompatKey struct {
 
 	// Models defines the model configurations including aliases for routing.
 	Models []VertexCompatModel `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Priority selects the credential tier for the weighted routing strategy; higher tiers are used first.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Weight sets this credential's share of traffic within its tier (default 1).
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
 }
 
 // VertexCompatModel represents a model configuration for Vertex compatibility,
@@ -0,0 +1,289 @@
+package contextwindow
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// estimateMarginPercent inflates the counts of payloads that are not tokenized by an OpenAI
+// tokenizer upstream, since the local tokenizer only approximates theirs.
+const estimateMarginPercent = 10
+
+// conversation locates the turns and tool results of a request in one of the client formats.
+type conversation struct {
+	format  string
+	payload []byte
+	// path is the JSON path of the message array.
+	path  string
+	items []gjson.Result
+}
+
+func parseConversation(format string, payload []byte) (*conversation, bool) {
+	var path string
+	switch format {
+	case constant.OpenAI, constant.Claude:
+		path = "messages"
+	case constant.OpenaiResponse:
+		path = "input"
+	case constant.Gemini:
+		path = "contents"
+	case constant.GeminiCLI:
+		path = "request.contents"
+	default:
+		return nil, false
+	}
+	messages := gjson.GetBytes(payload, path)
+	if !messages.IsArray() {
+		return nil, false
+	}
+	return &conversation{format: format, payload: payload, path: path, items: messages.Array()}, true
+}
+
+// isSystem reports whether item carries instructions that are never dropped.
+func (c *conversation) isSystem(item gjson.Result) bool {
+	switch c.format {
+	case constant.OpenAI, constant.OpenaiResponse:
+		role := item.Get("role").String()
+		return role == "system" || role == "developer"
+	default:
+		// Claude and Gemini keep instructions outside the message array.
+		return false
+	}
+}
+
+// startsTurn reports whether item is a user message that opens a turn. Tool results sent back
+// in user messages belong to the turn of the call they answer.
+func (c *conversation) startsTurn(item gjson.Result) bool {
+	switch c.format {
+	case constant.OpenAI, constant.OpenaiResponse:
+		return item.Get("role").String() == "user"
+	case constant.Claude:
+		if item.Get("role").String() != "user" {
+			return false
+		}
+		content := item.Get("content")
+		if !content.IsArray() {
+			return true
+		}
+		opens := len(content.Array()) == 0
+		content.ForEach(func(_, block gjson.Result) bool {
+			if block.Get("type").String() != "tool_result" {
+				opens = true
+				return false
+			}
+			return true
+		})
+		return opens
+	default:
+		if item.Get("role").String() == "model" {
+			return false
+		}
+		opens := true
+		item.Get("parts").ForEach(func(_, part gjson.Result) bool {
+			if part.Get("functionResponse").Exists() {
+				opens = false
+				return false
+			}
+			return true
+		})
+		return opens
+	}
+}
+
+// dropOldest removes the oldest turns, leaving system messages in place, until about excess
+// tokens are freed. The last turn is always kept so the request still asks something.
+func (c *conversation) dropOldest(model string, excess int64) ([]byte, int) {
+	last := -1
+	for i := len(c.items) - 1; i >= 0; i-- {
+		if c.startsTurn(c.items[i]) && !c.isSystem(c.items[i]) {
+			last = i
+			break
+		}
+	}
+	if last <= 0 {
+		return c.payload, 0
+	}
+	dropped := make(map[int]bool)
+	var freed int64
+	for start := 0; start < last && freed < excess; {
+		end := start + 1
+		for end < last && !c.startsTurn(c.items[end]) {
+			end++
+		}
+		for i := start; i < end; i++ {
+			if c.isSystem(c.items[i]) {
+				continue
+			}
+			dropped[i] = true
+			freed += c.estimate(model, c.items[i].Raw)
+		}
+		start = end
+	}
+	if len(dropped) == 0 {
+		return c.payload, 0
+	}
+	kept := make([]string, 0, len(c.items)-len(dropped))
+	for i, item := range c.items {
+		if !dropped[i] {
+			kept = append(kept, item.Raw)
+		}
+	}
+	updated, err := sjson.SetRawBytes(c.payload, c.path, []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return c.payload, 0
+	}
+	return updated, len(dropped)
+}
+
+// toolResult is the text of a tool result and where it sits in the payload.
+type toolResult struct {
+	path string
+	text string
+	// object marks Gemini function responses, which must stay JSON objects.
+	object bool
+}
+
+func (c *conversation) toolResults() []toolResult {
+	var results []toolResult
+	addContent := func(path string, content gjson.Result) {
+		if content.Type == gjson.String {
+			results = append(results, toolResult{path: path, text: content.String()})
+			return
+		}
+		content.ForEach(func(index, part gjson.Result) bool {
+			if text := part.Get("text"); text.Type == gjson.String {
+				results = append(results, toolResult{path: fmt.Sprintf("%s.%d.text", path, index.Int()), text: text.String()})
+			}
+			return true
+		})
+	}
+	for i, item := range c.items {
+		base := fmt.Sprintf("%s.%d", c.path, i)
+		switch c.format {
+		case constant.OpenAI:
+			if item.Get("role").String() == "tool" {
+				addContent(base+".content", item.Get("content"))
+			}
+		case constant.OpenaiResponse:
+			if item.Get("type").String() == "function_call_output" {
+				addContent(base+".output", item.Get("output"))
+			}
+		case constant.Claude:
+			item.Get("content").ForEach(func(index, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_result" {
+					addContent(fmt.Sprintf("%s.content.%d.content", base, index.Int()), block.Get("content"))
+				}
+				return true
+			})
+		default:
+			item.Get("parts").ForEach(func(index, part gjson.Result) bool {
+				if response := part.Get("functionResponse.response"); response.Exists() {
+					results = append(results, toolResult{path: fmt.Sprintf("%s.parts.%d.functionResponse.response", base, index.Int()), text: response.Raw, object: true})
+				}
+				return true
+			})
+		}
+	}
+	return results
+}
+
+// truncateToolResults shortens tool results longer than maxTokens, oldest first, until about
+// excess tokens are freed.
+func (c *conversation) truncateToolResults(model string, excess, maxTokens int64) ([]byte, int) {
+	payload := c.payload
+	truncated := 0
+	var freed int64
+	for _, result := range c.toolResults() {
+		if freed >= excess {
+			break
+		}
+		tokens := countText(model, result.text)
+		if tokens <= maxTokens {
+			continue
+		}
+		keep := int(int64(len(result.text)) * maxTokens / tokens)
+		for keep > 0 && !utf8.RuneStart(result.text[keep]) {
+			keep--
+		}
+		text := fmt.Sprintf("%s\n[truncated %d tokens]", result.text[:keep], tokens-maxTokens)
+		var err error
+		var updated []byte
+		if result.object {
+			updated, err = sjson.SetBytes(payload, result.path, map[string]string{"result": text})
+		} else {
+			updated, err = sjson.SetBytes(payload, result.path, text)
+		}
+		if err != nil {
+			continue
+		}
+		payload = updated
+		freed += tokens - maxTokens
+		truncated++
+	}
+	return payload, truncated
+}
+
+// estimate counts the tokens of a JSON value of the conversation.
+func (c *conversation) estimate(model, raw string) int64 {
+	count := countText(model, strings.Join(collectText(gjson.Parse(raw), nil), "\n"))
+	if c.format != constant.OpenAI {
+		count += count * estimateMarginPercent / 100
+	}
+	return count
+}
+
+// countPrompt counts the prompt tokens of a request in format for model. OpenAI chat payloads
+// are counted with the tokenizer of the model; other formats are estimated with a margin.
+func countPrompt(format, model string, payload []byte) int64 {
+	if format == constant.OpenAI {
+		if count, err := executor.CountOpenAIChatTokens(model, payload); err == nil {
+			return count
+		}
+	}
+	count := countText(model, strings.Join(collectText(gjson.ParseBytes(payload), nil), "\n"))
+	return count + count*estimateMarginPercent/100
+}
+
+func countText(model, text string) int64 {
+	if text == "" {
+		return 0
+	}
+	count, err := executor.CountTextTokens(model, text)
+	if err != nil {
+		// Roughly four characters per token.
+		return int64(len(text)/4 + 1)
+	}
+	return count
+}
+
+// collectText gathers the string values of value, skipping inline binary data and signatures,
+// which do not count as prompt text.
+func collectText(value gjson.Result, segments []string) []string {
+	switch {
+	case value.IsObject():
+		value.ForEach(func(key, child gjson.Result) bool {
+			switch key.String() {
+			case "data", "signature", "thoughtSignature", "thought_signature":
+				return true
+			}
+			segments = collectText(child, segments)
+			return true
+		})
+	case value.IsArray():
+		value.ForEach(func(_, child gjson.Result) bool {
+			segments = collectText(child, segments)
+			return true
+		})
+	case value.Type == gjson.String:
+		if text := value.String(); text != "" && !strings.HasPrefix(text, "data:") {
+			segments = append(segments, text)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,196 @@
+// Package contextwindow fits requests into the context window of the target model before they
+// are dispatched. Prompts are counted locally and requests that do not fit are trimmed or
+// rerouted according to the strategy configured for the client key or model.
+package contextwindow
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultReserveTokens is the room kept for the response when neither the model nor the
+	// request bounds it.
+	defaultReserveTokens = 4096
+	// defaultToolResultMaxTokens is the size tool results are truncated to.
+	defaultToolResultMaxTokens = 2000
+)
+
+var defaultEngine = NewEngine()
+
+// Default returns the shared engine configured from the server config.
+func Default() *Engine { return defaultEngine }
+
+// Result is the request to dispatch after fitting.
+type Result struct {
+	// Model is the model to dispatch to; it differs from the requested one after routing.
+	Model string
+	// Payload is the request body to dispatch.
+	Payload []byte
+	// Trimmed lists what was changed, e.g. "dropped-messages=4". Empty when nothing was.
+	Trimmed []string
+}
+
+// Engine resolves the context window policy of a request and applies it.
+type Engine struct {
+	mu       sync.RWMutex
+	defaults config.ContextWindowPolicy
+	models   map[string]config.ContextWindowPolicy
+	keys     map[string]config.ContextWindowPolicy
+	// modelInfo looks up the context window of a model.
+	modelInfo func(model string) *registry.ModelInfo
+}
+
+// NewEngine constructs an engine that leaves every request untouched until configured.
+func NewEngine() *Engine {
+	return &Engine{
+		modelInfo: func(model string) *registry.ModelInfo {
+			return registry.GetGlobalRegistry().GetModelInfo(model)
+		},
+	}
+}
+
+// SetConfig replaces the active policies.
+func (e *Engine) SetConfig(cfg config.ContextWindowConfig) {
+	if e == nil {
+		return
+	}
+	models := make(map[string]config.ContextWindowPolicy, len(cfg.Models))
+	for i := range cfg.Models {
+		if model := strings.ToLower(strings.TrimSpace(cfg.Models[i].Model)); model != "" {
+			models[model] = cfg.Models[i].ContextWindowPolicy
+		}
+	}
+	keys := make(map[string]config.ContextWindowPolicy, len(cfg.Keys))
+	for i := range cfg.Keys {
+		if key := strings.TrimSpace(cfg.Keys[i].APIKey); key != "" {
+			keys[key] = cfg.Keys[i].ContextWindowPolicy
+		}
+	}
+	e.mu.Lock()
+	e.defaults = cfg.Default
+	e.models = models
+	e.keys = keys
+	e.mu.Unlock()
+}
+
+func (e *Engine) policyFor(apiKey, model string) config.ContextWindowPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if policy, ok := e.keys[apiKey]; ok && apiKey != "" {
+		return policy
+	}
+	if policy, ok := e.models[strings.ToLower(strings.TrimSpace(model))]; ok {
+		return policy
+	}
+	return e.defaults
+}
+
+// Fit counts the prompt of a request in format for model and, when it exceeds the context
+// window, applies the policy of apiKey or model. Requests in unsupported formats, for models
+// with an unknown context window and those that fit are returned unchanged.
+func (e *Engine) Fit(apiKey, format, model string, payload []byte) Result {
+	result := Result{Model: model, Payload: payload}
+	if e == nil {
+		return result
+	}
+	policy := e.policyFor(apiKey, model)
+	if policy.Strategy == "" {
+		return result
+	}
+	conv, ok := parseConversation(format, payload)
+	if !ok {
+		return result
+	}
+	limit := e.inputLimit(model, payload, policy)
+	if limit <= 0 {
+		return result
+	}
+	tokens := countPrompt(format, model, payload)
+	if tokens <= limit {
+		return result
+	}
+
+	switch policy.Strategy {
+	case config.ContextWindowRoute:
+		for _, candidate := range policy.RouteTo {
+			candidateLimit := e.inputLimit(candidate, payload, policy)
+			if candidateLimit <= 0 || countPrompt(format, candidate, payload) > candidateLimit {
+				continue
+			}
+			result.Model = candidate
+			if gjson.GetBytes(payload, "model").Exists() {
+				if updated, err := sjson.SetBytes(payload, "model", candidate); err == nil {
+					result.Payload = updated
+				}
+			}
+			result.Trimmed = []string{"routed-to=" + candidate}
+			return result
+		}
+	case config.ContextWindowDropOldest:
+		if updated, dropped := conv.dropOldest(model, tokens-limit); dropped > 0 {
+			result.Payload = updated
+			result.Trimmed = []string{"dropped-messages=" + strconv.Itoa(dropped)}
+		}
+	case config.ContextWindowTruncateToolResults:
+		maxTokens := int64(policy.ToolResultMaxTokens)
+		if maxTokens <= 0 {
+			maxTokens = defaultToolResultMaxTokens
+		}
+		if updated, truncated := conv.truncateToolResults(model, tokens-limit, maxTokens); truncated > 0 {
+			result.Payload = updated
+			result.Trimmed = []string{"truncated-tool-results=" + strconv.Itoa(truncated)}
+		}
+	}
+	return result
+}
+
+// inputLimit returns the prompt tokens model accepts, or 0 when its context window is unknown.
+// Models reporting only a total context length keep room for the requested output, or for the
+// reserve of the policy when the request does not bound it.
+func (e *Engine) inputLimit(model string, payload []byte, policy config.ContextWindowPolicy) int64 {
+	info := e.modelInfo(model)
+	if info == nil {
+		if base, _ := util.NormalizeThinkingModel(model); base != "" && base != model {
+			info = e.modelInfo(base)
+		}
+	}
+	if info == nil {
+		return 0
+	}
+	if info.InputTokenLimit > 0 {
+		return int64(info.InputTokenLimit)
+	}
+	if info.ContextLength <= 0 {
+		return 0
+	}
+	reserve := requestedOutputTokens(payload)
+	if reserve <= 0 {
+		reserve = int64(policy.ReserveTokens)
+	}
+	if reserve <= 0 {
+		reserve = defaultReserveTokens
+	}
+	limit := int64(info.ContextLength) - reserve
+	if limit <= 0 {
+		return 0
+	}
+	return limit
+}
+
+// requestedOutputTokens returns the output bound set by the request in any client format.
+func requestedOutputTokens(payload []byte) int64 {
+	for _, path := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens"} {
+		if value := gjson.GetBytes(payload, path).Int(); value > 0 {
+			return value
+		}
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package contextwindow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func newTestEngine(cfg config.ContextWindowConfig, windows map[string]int) *Engine {
+	engine := NewEngine()
+	engine.modelInfo = func(model string) *registry.ModelInfo {
+		if window, ok := windows[model]; ok {
+			return &registry.ModelInfo{ID: model, ContextLength: window}
+		}
+		return nil
+	}
+	engine.SetConfig(cfg)
+	return engine
+}
+
+func filler(words int) string {
+	return strings.TrimSpace(strings.Repeat("lorem ipsum dolor ", words/3))
+}
+
+func TestFit_DropsOldestTurns(t *testing.T) {
+	engine := newTestEngine(config.ContextWindowConfig{
+		Default: config.ContextWindowPolicy{Strategy: config.ContextWindowDropOldest, ReserveTokens: 100},
+	}, map[string]int{"small": 1100})
+
+	payload := `{"model":"small","messages":[{"role":"system","content":"be brief"}]}`
+	for i := 0; i < 3; i++ {
+		payload, _ = sjson.Set(payload, "messages.-1", map[string]any{"role": "user", "content": filler(600)})
+		payload, _ = sjson.Set(payload, "messages.-1", map[string]any{"role": "assistant", "content": filler(30)})
+	}
+	payload, _ = sjson.Set(payload, "messages.-1", map[string]any{"role": "user", "content": "latest question"})
+
+	result := engine.Fit("", "openai", "small", []byte(payload))
+	if len(result.Trimmed) != 1 || !strings.HasPrefix(result.Trimmed[0], "dropped-messages=") {
+		t.Fatalf("expected dropped messages to be reported, got %v", result.Trimmed)
+	}
+	messages := gjson.GetBytes(result.Payload, "messages").Array()
+	if messages[0].Get("role").String() != "system" {
+		t.Fatalf("expected the system message to be kept, got %s", messages[0].Raw)
+	}
+	if messages[len(messages)-1].Get("content").String() != "latest question" {
+		t.Fatalf("expected the last turn to be kept, got %s", messages[len(messages)-1].Raw)
+	}
+	if messages[1].Get("role").String() != "user" {
+		t.Fatalf("expected whole turns to be dropped, got %s", messages[1].Raw)
+	}
+	if tokens := countPrompt("openai", "small", result.Payload); tokens > 1000 {
+		t.Fatalf("expected the request to fit after trimming, got %d tokens", tokens)
+	}
+}
+
+func TestFit_TruncatesClaudeToolResults(t *testing.T) {
+	engine := newTestEngine(config.ContextWindowConfig{
+		Models: []config.ModelContextWindowPolicy{{
+			Model:               "small",
+			ContextWindowPolicy: config.ContextWindowPolicy{Strategy: config.ContextWindowTruncateToolResults, ToolResultMaxTokens: 50},
+		}},
+	}, map[string]int{"small": 1000})
+
+	payload := `{"model":"small","max_tokens":200,"messages":[
+		{"role":"user","content":"read the file"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"tu_1","name":"read","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"tu_1","content":""}]}]}`
+	payload, _ = sjson.Set(payload, "messages.2.content.0.content", filler(1500))
+
+	result := engine.Fit("client", "claude", "small", []byte(payload))
+	if len(result.Trimmed) != 1 || result.Trimmed[0] != "truncated-tool-results=1" {
+		t.Fatalf("expected one truncated tool result, got %v", result.Trimmed)
+	}
+	content := gjson.GetBytes(result.Payload, "messages.2.content.0.content").String()
+	if !strings.Contains(content, "[truncated ") || len(content) >= len(filler(1500)) {
+		t.Fatalf("unexpected truncated tool result %q", content)
+	}
+	if len(gjson.GetBytes(result.Payload, "messages").Array()) != 3 {
+		t.Fatal("truncation must not drop messages")
+	}
+}
+
+func TestFit_RoutesToLargerModelAndHonoursKeyPolicy(t *testing.T) {
+	engine := newTestEngine(config.ContextWindowConfig{
+		Default: config.ContextWindowPolicy{Strategy: config.ContextWindowRoute, RouteTo: []string{"medium", "large"}},
+		Keys:    []config.ClientContextWindowPolicy{{APIKey: "untouched"}},
+	}, map[string]int{"small": 5000, "medium": 6000, "large": 100000})
+
+	payload, _ := sjson.Set(`{"model":"small","messages":[]}`, "messages.-1", map[string]any{"role": "user", "content": filler(6000)})
+
+	result := engine.Fit("client", "openai", "small", []byte(payload))
+	if result.Model != "large" || gjson.GetBytes(result.Payload, "model").String() != "large" {
+		t.Fatalf("expected the request to be routed to the first model that fits, got %q", result.Model)
+	}
+	if len(result.Trimmed) != 1 || result.Trimmed[0] != "routed-to=large" {
+		t.Fatalf("unexpected trimmed list %v", result.Trimmed)
+	}
+
+	result = engine.Fit("untouched", "openai", "small", []byte(payload))
+	if result.Model != "small" || len(result.Trimmed) != 0 {
+		t.Fatalf("expected the key policy without a strategy to leave the request alone, got %+v", result.Trimmed)
+	}
+}
@@ -0,0 +1,248 @@
+// Package ratelimit implements inbound per-client throttling for the public API routes.
+// It enforces request-per-minute, token-per-minute and concurrency ceilings keyed by the
+// authenticated client API key, with token usage fed from the core usage record stream.
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// window is the sliding interval used for per-minute limits.
+const window = time.Minute
+
+// concurrencyRetryAfter is the retry hint returned when the concurrency ceiling is reached.
+const concurrencyRetryAfter = time.Second
+
+const (
+	// ReasonRequests indicates the requests-per-minute limit was exceeded.
+	ReasonRequests = "requests per minute"
+	// ReasonTokens indicates the tokens-per-minute limit was exceeded.
+	ReasonTokens = "tokens per minute"
+	// ReasonConcurrency indicates the concurrent request limit was exceeded.
+	ReasonConcurrency = "concurrent requests"
+)
+
+var defaultLimiter = NewLimiter()
+
+func init() {
+	coreusage.RegisterPlugin(defaultLimiter)
+}
+
+// Default returns the shared limiter fed by the global usage manager.
+func Default() *Limiter { return defaultLimiter }
+
+// Decision reports the outcome of an admission check.
+type Decision struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Reason names the exhausted limit when Allowed is false.
+	Reason string
+	// Limit is the configured value of the exhausted limit.
+	Limit int
+	// RetryAfter estimates when the request could be admitted.
+	RetryAfter time.Duration
+}
+
+type tokenEvent struct {
+	at     time.Time
+	tokens int64
+}
+
+type clientState struct {
+	requests []time.Time
+	tokens   []tokenEvent
+	inFlight int
+}
+
+// Limiter tracks request, token and concurrency usage per client key.
+// It implements coreusage.Plugin so token limits reflect reported usage.
+type Limiter struct {
+	mu        sync.Mutex
+	defaults  config.RateLimit
+	overrides map[string]config.RateLimit
+	clients   map[string]*clientState
+	// lastSweep is when idle client windows were last evicted.
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter constructs an empty limiter that admits every request until configured.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		clients: make(map[string]*clientState),
+		now:     time.Now,
+	}
+}
+
+// SetConfig replaces the active limits. Existing window state is retained; windows that
+// aged out are evicted.
+func (l *Limiter) SetConfig(cfg config.RateLimitConfig) {
+	if l == nil {
+		return
+	}
+	overrides := make(map[string]config.RateLimit, len(cfg.Keys))
+	for i := range cfg.Keys {
+		key := strings.TrimSpace(cfg.Keys[i].APIKey)
+		if key == "" {
+			continue
+		}
+		overrides[key] = cfg.Keys[i].RateLimit
+	}
+	l.mu.Lock()
+	l.defaults = cfg.Default
+	l.overrides = overrides
+	l.sweepLocked(l.now())
+	l.mu.Unlock()
+}
+
+// Acquire performs an admission check for key. When the request is admitted the
+// returned release function must be called once the request completes.
+func (l *Limiter) Acquire(key string) (func(), Decision) {
+	noop := func() {}
+	if l == nil {
+		return noop, Decision{Allowed: true}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limitForLocked(key)
+	if limit == (config.RateLimit{}) {
+		return noop, Decision{Allowed: true}
+	}
+	now := l.now()
+	state := l.stateLocked(key)
+	state.prune(now)
+
+	if limit.MaxConcurrent > 0 && state.inFlight >= limit.MaxConcurrent {
+		return noop, Decision{Reason: ReasonConcurrency, Limit: limit.MaxConcurrent, RetryAfter: concurrencyRetryAfter}
+	}
+	if limit.RequestsPerMinute > 0 && len(state.requests) >= limit.RequestsPerMinute {
+		oldest := state.requests[len(state.requests)-limit.RequestsPerMinute]
+		return noop, Decision{Reason: ReasonRequests, Limit: limit.RequestsPerMinute, RetryAfter: oldest.Add(window).Sub(now)}
+	}
+	if limit.TokensPerMinute > 0 {
+		if retry, exceeded := state.tokenRetryAfter(now, int64(limit.TokensPerMinute)); exceeded {
+			return noop, Decision{Reason: ReasonTokens, Limit: limit.TokensPerMinute, RetryAfter: retry}
+		}
+	}
+
+	if limit.RequestsPerMinute > 0 {
+		state.requests = append(state.requests, now)
+	}
+	state.inFlight++
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if st, ok := l.clients[key]; ok {
+				if st.inFlight > 0 {
+					st.inFlight--
+				}
+				l.gcLocked(key, st, l.now())
+			}
+		})
+	}
+	return release, Decision{Allowed: true}
+}
+
+// HandleUsage implements coreusage.Plugin by charging reported tokens to the client key.
+func (l *Limiter) HandleUsage(_ context.Context, record coreusage.Record) {
+	if l == nil {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens <= 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limitForLocked(record.APIKey).TokensPerMinute <= 0 {
+		return
+	}
+	now := l.now()
+	// Usage arrives for keys that may never call Acquire again, so their windows are swept here.
+	if now.Sub(l.lastSweep) >= window {
+		l.sweepLocked(now)
+	}
+	state := l.stateLocked(record.APIKey)
+	// Charge at report time so the window stays ordered; long streams land when they finish.
+	state.tokens = append(state.tokens, tokenEvent{at: now, tokens: tokens})
+}
+
+func (l *Limiter) limitForLocked(key string) config.RateLimit {
+	if limit, ok := l.overrides[key]; ok {
+		return limit
+	}
+	return l.defaults
+}
+
+func (l *Limiter) stateLocked(key string) *clientState {
+	state, ok := l.clients[key]
+	if !ok {
+		state = &clientState{}
+		l.clients[key] = state
+	}
+	return state
+}
+
+func (l *Limiter) gcLocked(key string, state *clientState, now time.Time) {
+	state.prune(now)
+	if state.inFlight == 0 && len(state.requests) == 0 && len(state.tokens) == 0 {
+		delete(l.clients, key)
+	}
+}
+
+// sweepLocked evicts every client whose windows are empty and that has no request in flight.
+func (l *Limiter) sweepLocked(now time.Time) {
+	for key, state := range l.clients {
+		l.gcLocked(key, state, now)
+	}
+	l.lastSweep = now
+}
+
+func (s *clientState) prune(now time.Time) {
+	cutoff := now.Add(-window)
+	idx := 0
+	for idx < len(s.requests) && !s.requests[idx].After(cutoff) {
+		idx++
+	}
+	if idx > 0 {
+		s.requests = append(s.requests[:0], s.requests[idx:]...)
+	}
+	idx = 0
+	for idx < len(s.tokens) && !s.tokens[idx].at.After(cutoff) {
+		idx++
+	}
+	if idx > 0 {
+		s.tokens = append(s.tokens[:0], s.tokens[idx:]...)
+	}
+}
+
+// tokenRetryAfter reports whether the token window is exhausted and, if so, how long
+// until enough usage ages out of the window to fall back under limit.
+func (s *clientState) tokenRetryAfter(now time.Time, limit int64) (time.Duration, bool) {
+	var total int64
+	for i := range s.tokens {
+		total += s.tokens[i].tokens
+	}
+	if total < limit {
+		return 0, false
+	}
+	for i := range s.tokens {
+		total -= s.tokens[i].tokens
+		if total < limit {
+			return s.tokens[i].at.Add(window).Sub(now), true
+		}
+	}
+	return window, true
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestLimiter(cfg config.RateLimitConfig) (*Limiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+	l.SetConfig(cfg)
+	return l, &now
+}
+
+func TestLimiterRequestsPerMinute(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitConfig{Default: config.RateLimit{RequestsPerMinute: 2}})
+
+	for i := 0; i < 2; i++ {
+		release, decision := l.Acquire("k")
+		if !decision.Allowed {
+			t.Fatalf("request %d rejected: %+v", i, decision)
+		}
+		release()
+	}
+	_, decision := l.Acquire("k")
+	if decision.Allowed || decision.Reason != ReasonRequests {
+		t.Fatalf("third request decision = %+v, want %s rejection", decision, ReasonRequests)
+	}
+	if decision.RetryAfter != time.Minute {
+		t.Fatalf("RetryAfter = %v, want %v", decision.RetryAfter, time.Minute)
+	}
+
+	*now = now.Add(time.Minute + time.Second)
+	if _, decision = l.Acquire("k"); !decision.Allowed {
+		t.Fatalf("request after window rejected: %+v", decision)
+	}
+}
+
+func TestLimiterConcurrencyAndOverrides(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{MaxConcurrent: 1},
+		Keys:    []config.ClientRateLimit{{APIKey: "vip"}},
+	})
+
+	release, decision := l.Acquire("k")
+	if !decision.Allowed {
+		t.Fatalf("first request rejected: %+v", decision)
+	}
+	if _, decision = l.Acquire("k"); decision.Allowed || decision.Reason != ReasonConcurrency {
+		t.Fatalf("second concurrent request decision = %+v, want %s rejection", decision, ReasonConcurrency)
+	}
+	release()
+	release()
+	if _, decision = l.Acquire("k"); !decision.Allowed {
+		t.Fatalf("request after release rejected: %+v", decision)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, decision = l.Acquire("vip"); !decision.Allowed {
+			t.Fatalf("override key request %d rejected: %+v", i, decision)
+		}
+	}
+}
+
+func TestLimiterTokensPerMinuteFromUsage(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitConfig{Default: config.RateLimit{TokensPerMinute: 100}})
+
+	l.HandleUsage(context.Background(), coreusage.Record{APIKey: "k", Detail: coreusage.Detail{TotalTokens: 60}})
+	if _, decision := l.Acquire("k"); !decision.Allowed {
+		t.Fatalf("request under token limit rejected: %+v", decision)
+	}
+	*now = now.Add(10 * time.Second)
+	l.HandleUsage(context.Background(), coreusage.Record{APIKey: "k", Detail: coreusage.Detail{InputTokens: 30, OutputTokens: 20}})
+
+	_, decision := l.Acquire("k")
+	if decision.Allowed || decision.Reason != ReasonTokens {
+		t.Fatalf("decision = %+v, want %s rejection", decision, ReasonTokens)
+	}
+	if decision.RetryAfter != 50*time.Second {
+		t.Fatalf("RetryAfter = %v, want %v", decision.RetryAfter, 50*time.Second)
+	}
+}
+
+func TestLimiterEvictsIdleUsageWindows(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimitConfig{Default: config.RateLimit{TokensPerMinute: 100}})
+
+	for _, key := range []string{"a", "b", "c"} {
+		l.HandleUsage(context.Background(), coreusage.Record{APIKey: key, Detail: coreusage.Detail{TotalTokens: 10}})
+	}
+	if len(l.clients) != 3 {
+		t.Fatalf("clients = %d, want 3", len(l.clients))
+	}
+
+	*now = now.Add(2 * time.Minute)
+	l.HandleUsage(context.Background(), coreusage.Record{APIKey: "d", Detail: coreusage.Detail{TotalTokens: 10}})
+	if _, ok := l.clients["a"]; ok || len(l.clients) != 1 {
+		t.Fatalf("expected idle windows to be evicted, got %d clients", len(l.clients))
+	}
+
+	*now = now.Add(2 * time.Minute)
+	l.SetConfig(config.RateLimitConfig{Default: config.RateLimit{TokensPerMinute: 100}})
+	if len(l.clients) != 0 {
+		t.Fatalf("expected SetConfig to evict idle windows, got %d clients", len(l.clients))
+	}
+}
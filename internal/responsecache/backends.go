@@ -0,0 +1,182 @@
+package responsecache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryBackend keeps entries in an in-process LRU list.
+type memoryBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func newMemoryBackend(maxEntries int) *memoryBackend {
+	return &memoryBackend{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// LoadCachedResponse implements Backend.
+func (b *memoryBackend) LoadCachedResponse(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := elem.Value.(*memoryItem)
+	if !time.Now().Before(item.expiresAt) {
+		b.order.Remove(elem)
+		delete(b.items, key)
+		return nil, nil
+	}
+	b.order.MoveToFront(elem)
+	return item.data, nil
+}
+
+// SaveCachedResponse implements Backend.
+func (b *memoryBackend) SaveCachedResponse(_ context.Context, key string, data []byte, expiresAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elem, ok := b.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.data = data
+		item.expiresAt = expiresAt
+		b.order.MoveToFront(elem)
+		return nil
+	}
+	b.items[key] = b.order.PushFront(&memoryItem{key: key, data: data, expiresAt: expiresAt})
+	for b.order.Len() > b.maxEntries {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.items, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+// diskBackend keeps one file per entry in a directory. The recency order is rebuilt from the
+// file modification times on start and tracked in memory afterwards.
+type diskBackend struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+const diskEntrySuffix = ".json"
+
+func newDiskBackend(dir string, maxEntries int) (*diskBackend, error) {
+	if strings.TrimSpace(dir) == "" {
+		return nil, fmt.Errorf("no directory configured")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", dir, err)
+	}
+	type file struct {
+		key     string
+		modTime time.Time
+	}
+	files := make([]file, 0, len(entries))
+	for _, dirEntry := range entries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasSuffix(name, diskEntrySuffix) {
+			continue
+		}
+		info, errInfo := dirEntry.Info()
+		if errInfo != nil {
+			continue
+		}
+		files = append(files, file{key: strings.TrimSuffix(name, diskEntrySuffix), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	b := &diskBackend{dir: dir, maxEntries: maxEntries, order: list.New(), items: make(map[string]*list.Element)}
+	for _, f := range files {
+		b.items[f.key] = b.order.PushFront(f.key)
+	}
+	b.mu.Lock()
+	b.evictLocked()
+	b.mu.Unlock()
+	return b, nil
+}
+
+// LoadCachedResponse implements Backend.
+func (b *diskBackend) LoadCachedResponse(_ context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.items[key]
+	if !ok {
+		return nil, nil
+	}
+	data, err := os.ReadFile(b.path(key))
+	if err != nil {
+		b.order.Remove(elem)
+		delete(b.items, key)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	b.order.MoveToFront(elem)
+	return data, nil
+}
+
+// SaveCachedResponse implements Backend. Expired files are not swept; they are overwritten or
+// evicted like any other entry.
+func (b *diskBackend) SaveCachedResponse(_ context.Context, key string, data []byte, _ time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path := b.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if elem, ok := b.items[key]; ok {
+		b.order.MoveToFront(elem)
+	} else {
+		b.items[key] = b.order.PushFront(key)
+	}
+	b.evictLocked()
+	return nil
+}
+
+func (b *diskBackend) evictLocked() {
+	for b.order.Len() > b.maxEntries {
+		oldest := b.order.Back()
+		key := oldest.Value.(string)
+		b.order.Remove(oldest)
+		delete(b.items, key)
+		_ = os.Remove(b.path(key))
+	}
+}
+
+func (b *diskBackend) path(key string) string {
+	return filepath.Join(b.dir, key+diskEntrySuffix)
+}
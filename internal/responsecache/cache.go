@@ -0,0 +1,264 @@
+// Package responsecache implements the exact-match cache of non-streaming responses. Entries
+// are keyed by client API key, model and normalized request body and kept in a pluggable
+// backend: an in-memory LRU, a local directory, or the Postgres or object storage token store.
+package responsecache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTTL        = time.Hour
+	defaultMaxEntries = 1000
+	// pruneInterval is how often backends shared between replicas are pruned.
+	pruneInterval = time.Minute
+)
+
+// Backend stores encoded cache entries. Stores that implement it can back the "postgres" and
+// "object-store" cache backends.
+type Backend interface {
+	// LoadCachedResponse returns the entry stored under key, or nil when there is none.
+	LoadCachedResponse(ctx context.Context, key string) ([]byte, error)
+	// SaveCachedResponse stores data under key until expiresAt.
+	SaveCachedResponse(ctx context.Context, key string, data []byte, expiresAt time.Time) error
+}
+
+// Pruner is implemented by backends shared between replicas, which cannot track recency in
+// process. The cache prunes them when it starts using them and every pruneInterval after, so
+// they may exceed maxEntries by the entries written in between.
+type Pruner interface {
+	// PruneCachedResponses removes expired entries, then the oldest entries beyond maxEntries.
+	// Stores that do not record the expiry of an entry treat entries older than ttl as expired.
+	PruneCachedResponses(ctx context.Context, maxEntries int, ttl time.Duration) error
+}
+
+// entry is the encoded form of a cached response.
+type entry struct {
+	Model     string    `json:"model"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created-at"`
+	ExpiresAt time.Time `json:"expires-at"`
+}
+
+var defaultCache = NewCache()
+
+// Default returns the shared response cache used by the API handlers.
+func Default() *Cache { return defaultCache }
+
+// Cache serves repeated requests from previously stored responses.
+type Cache struct {
+	mu         sync.RWMutex
+	cfg        config.ResponseCacheConfig
+	backend    Backend
+	store      any
+	defaultDir string
+	now        func() time.Time
+	// stopPrune ends the prune loop of the current backend, when it is a Pruner.
+	stopPrune context.CancelFunc
+}
+
+// NewCache constructs a disabled cache.
+func NewCache() *Cache {
+	return &Cache{now: time.Now}
+}
+
+// SetDefaultDir sets the directory of the disk backend when the configuration names none.
+func (c *Cache) SetDefaultDir(dir string) {
+	c.mu.Lock()
+	c.defaultDir = dir
+	c.mu.Unlock()
+}
+
+// SetStore sets the token store that backs the "postgres" and "object-store" backends.
+func (c *Cache) SetStore(store any) {
+	c.mu.Lock()
+	c.store = store
+	c.mu.Unlock()
+}
+
+// SetConfig applies cfg. The backend, and with it the cached entries of the memory backend, is
+// kept while the backend settings stay the same.
+func (c *Cache) SetConfig(cfg config.ResponseCacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !cfg.Enabled {
+		c.cfg = cfg
+		c.backend = nil
+		c.startPruningLocked()
+		return
+	}
+	rebuild := c.backend == nil || c.cfg.Backend != cfg.Backend || c.cfg.Dir != cfg.Dir || c.cfg.MaxEntries != cfg.MaxEntries
+	c.cfg = cfg
+	if rebuild {
+		c.backend = c.newBackend(cfg)
+		c.startPruningLocked()
+	}
+}
+
+// startPruningLocked stops the prune loop of the previous backend and starts one for the
+// current backend when it is a Pruner. c.mu must be held.
+func (c *Cache) startPruningLocked() {
+	if c.stopPrune != nil {
+		c.stopPrune()
+		c.stopPrune = nil
+	}
+	pruner, ok := c.backend.(Pruner)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopPrune = cancel
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			c.mu.RLock()
+			maxEntries, ttl := c.limitsLocked()
+			c.mu.RUnlock()
+			if err := pruner.PruneCachedResponses(ctx, maxEntries, ttl); err != nil && ctx.Err() == nil {
+				log.Debugf("response cache: prune: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// limitsLocked returns the configured entry cap and TTL with their defaults applied.
+func (c *Cache) limitsLocked() (int, time.Duration) {
+	maxEntries := c.cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	ttl := time.Duration(c.cfg.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return maxEntries, ttl
+}
+
+func (c *Cache) newBackend(cfg config.ResponseCacheConfig) Backend {
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	switch cfg.Backend {
+	case config.ResponseCacheBackendDisk:
+		dir := cfg.Dir
+		if dir == "" {
+			dir = c.defaultDir
+		}
+		backend, err := newDiskBackend(dir, maxEntries)
+		if err == nil {
+			return backend
+		}
+		log.Warnf("response cache: disk backend unavailable, using memory: %v", err)
+	case config.ResponseCacheBackendPostgres, config.ResponseCacheBackendObjectStore:
+		// These stores enforce maxEntries through Pruner.
+		if backend, ok := c.store.(Backend); ok {
+			return backend
+		}
+		log.Warnf("response cache: %s backend needs the matching token store, using memory", cfg.Backend)
+	}
+	return newMemoryBackend(maxEntries)
+}
+
+// Enabled reports whether the cache is on.
+func (c *Cache) Enabled() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.backend != nil
+}
+
+// Get returns the cached response stored under key. Backend errors count as a miss.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	c.mu.RLock()
+	backend := c.backend
+	c.mu.RUnlock()
+	if backend == nil {
+		return nil, false
+	}
+	data, err := backend.LoadCachedResponse(ctx, key)
+	if err != nil {
+		log.Debugf("response cache: load %s: %v", key, err)
+		return nil, false
+	}
+	if len(data) == 0 {
+		return nil, false
+	}
+	var cached entry
+	if err = json.Unmarshal(data, &cached); err != nil || len(cached.Payload) == 0 {
+		return nil, false
+	}
+	if !c.now().Before(cached.ExpiresAt) {
+		return nil, false
+	}
+	return cached.Payload, true
+}
+
+// Put stores the response of model under key for the configured TTL.
+func (c *Cache) Put(ctx context.Context, key, model string, payload []byte) {
+	if c == nil || key == "" || len(payload) == 0 {
+		return
+	}
+	c.mu.RLock()
+	backend := c.backend
+	_, ttl := c.limitsLocked()
+	c.mu.RUnlock()
+	if backend == nil {
+		return
+	}
+	now := c.now()
+	cached := entry{Model: model, Payload: payload, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err = backend.SaveCachedResponse(ctx, key, data, cached.ExpiresAt); err != nil {
+		log.Debugf("response cache: save %s: %v", key, err)
+	}
+}
+
+// Key returns the cache key of a request body sent by the client key scope in format for
+// model. The body is normalized so that key order, whitespace and the stream flag do not
+// matter. It returns false when the body is not a JSON object.
+func Key(scope, format, model string, body []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil || fields == nil {
+		return "", false
+	}
+	// The model is keyed in its canonical form; streaming and non-streaming requests share entries.
+	delete(fields, "model")
+	delete(fields, "stream")
+	delete(fields, "stream_options")
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(scope), []byte(format), []byte(model), normalized} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
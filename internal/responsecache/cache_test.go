@@ -0,0 +1,112 @@
+package responsecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestKey_NormalizesBody(t *testing.T) {
+	a, ok := Key("client-1", "openai", "gpt-5", []byte(`{"model":"alias","stream":true,"temperature":0,"messages":[{"role":"user","content":"hi"}]}`))
+	if !ok {
+		t.Fatal("expected a key for a JSON object body")
+	}
+	b, _ := Key("client-1", "openai", "gpt-5", []byte(`{ "messages": [{"content":"hi","role":"user"}], "temperature": 0 }`))
+	if a != b {
+		t.Fatal("key order, whitespace, model alias and stream flag must not change the key")
+	}
+	if c, _ := Key("client-2", "openai", "gpt-5", []byte(`{"temperature":0,"messages":[{"role":"user","content":"hi"}]}`)); c == a {
+		t.Fatal("different client keys must not share entries")
+	}
+	if d, _ := Key("client-1", "openai", "gpt-5", []byte(`{"temperature":0.5,"messages":[{"role":"user","content":"hi"}]}`)); d == a {
+		t.Fatal("different bodies must not share entries")
+	}
+	if _, ok = Key("client-1", "openai", "gpt-5", []byte(`not json`)); ok {
+		t.Fatal("expected no key for a body that is not JSON")
+	}
+}
+
+func TestCache_ExpiresAndEvicts(t *testing.T) {
+	now := time.Now()
+	cache := NewCache()
+	cache.now = func() time.Time { return now }
+	cache.SetConfig(config.ResponseCacheConfig{Enabled: true, TTLSeconds: 60, MaxEntries: 2})
+	ctx := context.Background()
+
+	cache.Put(ctx, "a", "m", []byte(`{"a":1}`))
+	cache.Put(ctx, "b", "m", []byte(`{"b":1}`))
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatal("expected a hit for a")
+	}
+	cache.Put(ctx, "c", "m", []byte(`{"c":1}`))
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	if payload, ok := cache.Get(ctx, "a"); !ok || string(payload) != `{"a":1}` {
+		t.Fatalf("expected a to survive eviction, got %q", payload)
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, ok := cache.Get(ctx, "c"); ok {
+		t.Fatal("expected the entry to expire after the TTL")
+	}
+}
+
+func TestCache_DiskBackendSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.ResponseCacheConfig{Enabled: true, Backend: config.ResponseCacheBackendDisk, Dir: dir}
+	ctx := context.Background()
+
+	first := NewCache()
+	first.SetConfig(cfg)
+	first.Put(ctx, "key", "m", []byte(`{"answer":42}`))
+
+	second := NewCache()
+	second.SetConfig(cfg)
+	if payload, ok := second.Get(ctx, "key"); !ok || string(payload) != `{"answer":42}` {
+		t.Fatalf("expected the disk entry after a restart, got %q", payload)
+	}
+
+	second.SetConfig(config.ResponseCacheConfig{})
+	if second.Enabled() {
+		t.Fatal("expected the cache to be disabled")
+	}
+	if _, ok := second.Get(ctx, "key"); ok {
+		t.Fatal("a disabled cache must not answer")
+	}
+}
+
+// pruningStore is a shared store backend that records the prunes it receives.
+type pruningStore struct {
+	prunes chan [2]int64
+}
+
+func (s *pruningStore) LoadCachedResponse(context.Context, string) ([]byte, error) { return nil, nil }
+
+func (s *pruningStore) SaveCachedResponse(context.Context, string, []byte, time.Time) error {
+	return nil
+}
+
+func (s *pruningStore) PruneCachedResponses(_ context.Context, maxEntries int, ttl time.Duration) error {
+	s.prunes <- [2]int64{int64(maxEntries), int64(ttl)}
+	return nil
+}
+
+func TestCache_PrunesSharedBackends(t *testing.T) {
+	store := &pruningStore{prunes: make(chan [2]int64, 4)}
+	cache := NewCache()
+	cache.SetStore(store)
+	cache.SetConfig(config.ResponseCacheConfig{Enabled: true, Backend: config.ResponseCacheBackendPostgres, TTLSeconds: 60, MaxEntries: 5})
+	defer cache.SetConfig(config.ResponseCacheConfig{})
+
+	select {
+	case got := <-store.prunes:
+		if got[0] != 5 || time.Duration(got[1]) != time.Minute {
+			t.Fatalf("prune limits = %d entries, %v; want 5 entries, 1m0s", got[0], time.Duration(got[1]))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the shared backend to be pruned when it is selected")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
 )
@@ -37,10 +38,14 @@ func defaultDialer() *net.Dialer {
 }
 
 // newProxyAwareHTTPClient creates an HTTP client with proper proxy configuration priority:
-// 1. Use auth.ProxyURL if configured (highest priority)
+// 0. Use the client set by a pipeline hook through WithHTTPClient, including its timeout
+// 1. Use auth.ProxyURL if configured (highest priority among the proxy settings)
 // 2. Use cfg.ProxyURL if auth proxy is not configured
 // 3. Use RoundTripper from context if neither are configured
 //
+// When the context carries a response observer, the transport reports every upstream
+// response to it.
+//
 // Parameters:
 //   - ctx: The context containing optional RoundTripper
 //   - cfg: The application configuration
@@ -54,7 +59,25 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 	if timeout > 0 {
 		httpClient.Timeout = timeout
 	}
+	if custom := cliproxyexecutor.HTTPClientFromContext(ctx); custom != nil {
+		clone := *custom
+		if clone.Timeout <= 0 {
+			clone.Timeout = httpClient.Timeout
+		}
+		if clone.Transport == nil {
+			clone.Transport = defaultDirectTransport()
+		}
+		httpClient = &clone
+	} else {
+		httpClient.Transport = proxyAwareTransport(ctx, cfg, auth)
+	}
+	if observer := cliproxyexecutor.ResponseObserverFromContext(ctx); observer != nil {
+		httpClient.Transport = &observedTransport{base: httpClient.Transport, observer: observer}
+	}
+	return httpClient
+}
 
+func proxyAwareTransport(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) http.RoundTripper {
 	// Priority 1: Use auth.ProxyURL if configured
 	var proxyURL string
 	if auth != nil {
@@ -70,8 +93,7 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 	if proxyURL != "" {
 		transport := buildProxyTransport(proxyURL)
 		if transport != nil {
-			httpClient.Transport = transport
-			return httpClient
+			return transport
 		}
 		// If proxy setup failed, log and fall through to context RoundTripper
 		log.Debugf("failed to setup proxy from URL: %s, falling back to context transport", proxyURL)
@@ -79,12 +101,25 @@ func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *clip
 
 	// Priority 3: Use RoundTripper from context (typically from RoundTripperFor)
 	if rt, ok := ctx.Value("cliproxy.roundtripper").(http.RoundTripper); ok && rt != nil {
-		httpClient.Transport = rt
-		return httpClient
+		return rt
 	}
 
-	httpClient.Transport = defaultDirectTransport()
-	return httpClient
+	return defaultDirectTransport()
+}
+
+// observedTransport reports upstream responses to the observer installed by the core manager,
+// which learns provider rate limits from their headers.
+type observedTransport struct {
+	base     http.RoundTripper
+	observer cliproxyexecutor.ResponseObserver
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp != nil {
+		t.observer(resp.StatusCode, resp.Header)
+	}
+	return resp, err
 }
 
 // buildProxyTransport creates an HTTP transport configured for the given proxy URL.
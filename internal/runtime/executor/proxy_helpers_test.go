@@ -1,6 +1,15 @@
 package executor
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
 
 func TestDefaultDialer_HasFallbackDelay(t *testing.T) {
 	d := defaultDialer()
@@ -11,3 +20,31 @@ func TestDefaultDialer_HasFallbackDelay(t *testing.T) {
 		t.Fatalf("expected FallbackDelay > 0, got %v", d.FallbackDelay)
 	}
 }
+
+type stubRoundTripper struct{}
+
+func (stubRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func TestNewProxyAwareHTTPClient_PrefersContextClient(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.ProxyURL = "http://proxy.example.com:8080"
+	auth := &cliproxyauth.Auth{ProxyURL: "http://auth-proxy.example.com:8080"}
+	custom := &http.Client{Transport: stubRoundTripper{}, Timeout: 3 * time.Second}
+	ctx := cliproxyexecutor.WithHTTPClient(context.Background(), custom)
+
+	client := newProxyAwareHTTPClient(ctx, cfg, auth, time.Minute)
+	if _, ok := client.Transport.(stubRoundTripper); !ok {
+		t.Fatalf("expected the context client transport, got %T", client.Transport)
+	}
+	if client.Timeout != 3*time.Second {
+		t.Fatalf("expected the context client timeout, got %v", client.Timeout)
+	}
+
+	client = newProxyAwareHTTPClient(cliproxyexecutor.WithHTTPClient(context.Background(), &http.Client{}), cfg, auth, time.Minute)
+	if client.Timeout != time.Minute {
+		t.Fatalf("expected the executor timeout when the context client sets none, got %v", client.Timeout)
+	}
+	if client.Transport == nil {
+		t.Fatalf("expected a default transport")
+	}
+}
@@ -37,6 +37,30 @@ func tokenizerForModel(model string) (tokenizer.Codec, error) {
 	}
 }
 
+// CountOpenAIChatTokens approximates prompt tokens for an OpenAI chat completions payload with
+// the tokenizer of model.
+func CountOpenAIChatTokens(model string, payload []byte) (int64, error) {
+	enc, err := tokenizerForModel(model)
+	if err != nil {
+		return 0, err
+	}
+	return countOpenAIChatTokens(enc, payload)
+}
+
+// CountTextTokens counts the tokens of text with the tokenizer of an OpenAI-style model id.
+// Unknown models use o200k_base.
+func CountTextTokens(model, text string) (int64, error) {
+	enc, err := tokenizerForModel(model)
+	if err != nil {
+		return 0, err
+	}
+	count, err := enc.Count(text)
+	if err != nil {
+		return 0, err
+	}
+	return int64(count), nil
+}
+
 // countOpenAIChatTokens approximates prompt tokens for OpenAI chat completions payloads.
 func countOpenAIChatTokens(enc tokenizer.Codec, payload []byte) (int64, error) {
 	if enc == nil {
@@ -676,6 +676,50 @@ func (s *GitTokenStore) PersistConfig(_ context.Context) error {
 	return s.commitAndPushLocked("Update config", rel)
 }
 
+// PersistAuditLog commits the audit log, with every record appended since the last call, to the
+// git repository.
+func (s *GitTokenStore) PersistAuditLog(_ context.Context, path string, _ [][]byte) error {
+	if err := s.EnsureRepository(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rel, err := s.relativeToRepo(path)
+	if err != nil {
+		return err
+	}
+	return s.commitAndPushLocked("Append audit records", rel)
+}
+
+// LoadRuntimeState reads the cooldown state saved by SaveRuntimeState.
+func (s *GitTokenStore) LoadRuntimeState(context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	path := s.runtimeStatePath()
+	if path == "" {
+		return nil, nil
+	}
+	return cliproxyauth.ReadRuntimeStateFile(path)
+}
+
+// SaveRuntimeState persists cooldown state inside the repository's .git directory. It changes
+// too often to be committed, so it only survives restarts of the same workspace.
+func (s *GitTokenStore) SaveRuntimeState(_ context.Context, states map[string]*cliproxyauth.RuntimeState) error {
+	path := s.runtimeStatePath()
+	if path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cliproxyauth.WriteRuntimeStateFile(path, states)
+}
+
+func (s *GitTokenStore) runtimeStatePath() string {
+	repoDir := s.repoDirSnapshot()
+	if repoDir == "" {
+		return ""
+	}
+	return filepath.Join(repoDir, ".git", "cliproxy-runtime-state.json")
+}
+
 func ensureEmptyFile(path string) error {
 	if _, err := os.Stat(path); err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -3,6 +3,8 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,20 +13,26 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
-	objectStoreConfigKey  = "config/config.yaml"
-	objectStoreAuthPrefix = "auths"
+	objectStoreConfigKey   = "config/config.yaml"
+	objectStoreAuthPrefix  = "auths"
+	objectStoreAuditKey    = "audit/" + audit.FileName
+	objectStoreStatePrefix = "state/runtime"
+	objectStoreLockPrefix  = "locks/refresh"
+	objectStoreCachePrefix = "response-cache"
 )
 
 // ObjectStoreConfig captures configuration for the object storage-backed token store.
@@ -49,6 +57,11 @@ type ObjectTokenStore struct {
 	configPath string
 	authDir    string
 	mu         sync.Mutex
+
+	// stateMu guards stateObjects, the runtime state objects this process uploaded, keyed by
+	// auth ID.
+	stateMu      sync.Mutex
+	stateObjects map[string]writtenState
 }
 
 // NewObjectTokenStore initializes an object storage backed token store.
@@ -152,6 +165,9 @@ func (s *ObjectTokenStore) Bootstrap(ctx context.Context, exampleConfigPath stri
 	if err := s.syncAuthFromBucket(ctx); err != nil {
 		return err
 	}
+	if err := s.syncAuditFromBucket(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -324,6 +340,311 @@ func (s *ObjectTokenStore) PersistConfig(ctx context.Context) error {
 	return s.putObject(ctx, objectStoreConfigKey, data, "application/x-yaml")
 }
 
+// PersistAuditLog uploads the local audit log, with every record appended since the last call,
+// to the object storage backend.
+func (s *ObjectTokenStore) PersistAuditLog(ctx context.Context, path string, _ [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("object store: read audit log: %w", err)
+	}
+	return s.putObject(ctx, objectStoreAuditKey, data, "application/x-ndjson")
+}
+
+// objectRuntimeState is the content of the runtime state object of one auth.
+type objectRuntimeState struct {
+	ID    string                     `json:"id"`
+	State *cliproxyauth.RuntimeState `json:"state"`
+}
+
+// writtenState remembers a runtime state object this process uploaded.
+type writtenState struct {
+	etag string
+	data []byte
+}
+
+// LoadRuntimeState fetches the cooldown state saved by SaveRuntimeState, one object per auth.
+func (s *ObjectTokenStore) LoadRuntimeState(ctx context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	prefix := s.prefixedKey(objectStoreStatePrefix + "/")
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	for info := range s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("object store: list runtime state: %w", info.Err)
+		}
+		object, err := s.client.GetObject(ctx, s.cfg.Bucket, info.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("object store: fetch runtime state %s: %w", info.Key, err)
+		}
+		data, err := io.ReadAll(object)
+		_ = object.Close()
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("object store: read runtime state %s: %w", info.Key, err)
+		}
+		var entry objectRuntimeState
+		if err = json.Unmarshal(data, &entry); err != nil || entry.ID == "" || entry.State == nil {
+			log.Warnf("object store: skipping unreadable runtime state %s", info.Key)
+			continue
+		}
+		states[entry.ID] = entry.State
+	}
+	return states, nil
+}
+
+// SaveRuntimeState uploads the cooldown state of every auth as its own object, skipping states
+// that did not change since this process wrote them. Objects of auths missing from states are
+// only removed when this process wrote them and no other replica replaced them since, so
+// replicas sharing the bucket never drop each other's cooldowns.
+func (s *ObjectTokenStore) SaveRuntimeState(ctx context.Context, states map[string]*cliproxyauth.RuntimeState) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.stateObjects == nil {
+		s.stateObjects = make(map[string]writtenState)
+	}
+	for id, written := range s.stateObjects {
+		if _, ok := states[id]; ok {
+			continue
+		}
+		key := s.stateObjectKey(id)
+		info, err := s.client.StatObject(ctx, s.cfg.Bucket, key, minio.StatObjectOptions{})
+		switch {
+		case err == nil:
+			if info.ETag == written.etag {
+				if err = s.client.RemoveObject(ctx, s.cfg.Bucket, key, minio.RemoveObjectOptions{}); err != nil && !isObjectNotFound(err) {
+					return fmt.Errorf("object store: delete runtime state %s: %w", key, err)
+				}
+			}
+		case !isObjectNotFound(err):
+			return fmt.Errorf("object store: stat runtime state %s: %w", key, err)
+		}
+		delete(s.stateObjects, id)
+	}
+	for id, state := range states {
+		data, err := json.Marshal(objectRuntimeState{ID: id, State: state})
+		if err != nil {
+			return fmt.Errorf("object store: marshal runtime state: %w", err)
+		}
+		if written, ok := s.stateObjects[id]; ok && bytes.Equal(written.data, data) {
+			continue
+		}
+		key := s.stateObjectKey(id)
+		info, err := s.client.PutObject(ctx, s.cfg.Bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+		if err != nil {
+			return fmt.Errorf("object store: put runtime state %s: %w", key, err)
+		}
+		s.stateObjects[id] = writtenState{etag: info.ETag, data: data}
+	}
+	return nil
+}
+
+// stateObjectKey returns the full key of the runtime state object of authID.
+func (s *ObjectTokenStore) stateObjectKey(authID string) string {
+	return s.prefixedKey(objectStoreStatePrefix + "/" + normalizeAuthID(authID) + ".json")
+}
+
+// LoadCachedResponse fetches the response cache entry stored under key. Expired entries are
+// left to the caller to ignore until PruneCachedResponses removes them.
+func (s *ObjectTokenStore) LoadCachedResponse(ctx context.Context, key string) ([]byte, error) {
+	fullKey := s.prefixedKey(objectStoreCachePrefix + "/" + key + ".json")
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, fullKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: fetch cached response: %w", err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: read cached response: %w", err)
+	}
+	return data, nil
+}
+
+// SaveCachedResponse uploads a response cache entry under the response-cache prefix.
+func (s *ObjectTokenStore) SaveCachedResponse(ctx context.Context, key string, data []byte, expiresAt time.Time) error {
+	fullKey := s.prefixedKey(objectStoreCachePrefix + "/" + key + ".json")
+	_, err := s.client.PutObject(ctx, s.cfg.Bucket, fullKey, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+		Expires:     expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("object store: put cached response: %w", err)
+	}
+	return nil
+}
+
+// PruneCachedResponses removes response cache entries uploaded more than ttl ago, then the
+// oldest entries beyond maxEntries, in batch deletes.
+func (s *ObjectTokenStore) PruneCachedResponses(ctx context.Context, maxEntries int, ttl time.Duration) error {
+	prefix := s.prefixedKey(objectStoreCachePrefix + "/")
+	var entries []minio.ObjectInfo
+	for object := range s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("object store: list cached responses: %w", object.Err)
+		}
+		entries = append(entries, object)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastModified.After(entries[j].LastModified) })
+	cutoff := time.Now().Add(-ttl)
+	keep := len(entries)
+	for keep > 0 && ttl > 0 && !entries[keep-1].LastModified.After(cutoff) {
+		keep--
+	}
+	if maxEntries > 0 && keep > maxEntries {
+		keep = maxEntries
+	}
+	if keep == len(entries) {
+		return nil
+	}
+	stale := make(chan minio.ObjectInfo, len(entries)-keep)
+	for _, object := range entries[keep:] {
+		stale <- object
+	}
+	close(stale)
+	for result := range s.client.RemoveObjects(ctx, s.cfg.Bucket, stale, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil && !isObjectNotFound(result.Err) {
+			return fmt.Errorf("object store: delete cached response %s: %w", result.ObjectName, result.Err)
+		}
+	}
+	return nil
+}
+
+// objectLease is the content of a refresh lock object.
+type objectLease struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoadAuth downloads the current auth object of id, refreshes the local mirror with it and
+// returns the parsed record.
+func (s *ObjectTokenStore) LoadAuth(ctx context.Context, id string) (*cliproxyauth.Auth, error) {
+	path, err := s.resolveDeletePath(id)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(s.authDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("object store: resolve auth relative path: %w", err)
+	}
+	key := s.prefixedKey(objectStoreAuthPrefix + "/" + filepath.ToSlash(rel))
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: fetch auth %s: %w", key, err)
+	}
+	data, err := io.ReadAll(object)
+	_ = object.Close()
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: read auth %s: %w", key, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("object store: create auth directory: %w", err)
+	}
+	if existing, errRead := os.ReadFile(path); errRead != nil || !jsonEqual(existing, data) {
+		tmp := path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err != nil {
+			return nil, fmt.Errorf("object store: write temp auth file: %w", err)
+		}
+		if err = os.Rename(tmp, path); err != nil {
+			return nil, fmt.Errorf("object store: rename auth file: %w", err)
+		}
+	}
+	return s.readAuthFile(path, s.authDir)
+}
+
+// AcquireRefreshLease takes the refresh lease of an auth by writing a lock object that names the
+// owner and expiry. A free lease is created with If-None-Match: * and an expired one is only
+// replaced if its ETag did not change since it was read, so of two replicas racing for the lease
+// the object store lets exactly one write through. Releasing overwrites the lock with an expired
+// lease, again only if it still carries the ETag written here.
+func (s *ObjectTokenStore) AcquireRefreshLease(ctx context.Context, authID string, ttl time.Duration) (func(), bool, error) {
+	key := s.prefixedKey(objectStoreLockPrefix + "/" + normalizeAuthID(authID) + ".lock")
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	info, err := s.client.StatObject(ctx, s.cfg.Bucket, key, minio.StatObjectOptions{})
+	switch {
+	case err == nil:
+		lease, errLease := s.readLease(ctx, key)
+		if errLease != nil {
+			return nil, false, errLease
+		}
+		if lease != nil && time.Now().Before(lease.ExpiresAt) {
+			return nil, false, nil
+		}
+		opts.SetMatchETag(info.ETag)
+	case isObjectNotFound(err):
+		opts.SetMatchETagExcept("*")
+	default:
+		return nil, false, fmt.Errorf("object store: stat refresh lease: %w", err)
+	}
+
+	token := make([]byte, 8)
+	if _, err = rand.Read(token); err != nil {
+		return nil, false, fmt.Errorf("object store: generate lease owner: %w", err)
+	}
+	owner := hex.EncodeToString(token)
+	data, err := json.Marshal(objectLease{Owner: owner, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return nil, false, fmt.Errorf("object store: marshal refresh lease: %w", err)
+	}
+	written, err := s.client.PutObject(ctx, s.cfg.Bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	if err != nil {
+		if isPreconditionFailed(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("object store: put refresh lease: %w", err)
+	}
+	release := func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		expired, errMarshal := json.Marshal(objectLease{Owner: owner})
+		if errMarshal != nil {
+			return
+		}
+		releaseOpts := minio.PutObjectOptions{ContentType: "application/json"}
+		releaseOpts.SetMatchETag(written.ETag)
+		_, errPut := s.client.PutObject(releaseCtx, s.cfg.Bucket, key, bytes.NewReader(expired), int64(len(expired)), releaseOpts)
+		if errPut != nil && !isPreconditionFailed(errPut) && !isObjectNotFound(errPut) {
+			log.WithError(errPut).Warnf("object store: release refresh lease %s", key)
+		}
+	}
+	return release, true, nil
+}
+
+// readLease returns the lease stored under the full object key, or nil when there is none.
+func (s *ObjectTokenStore) readLease(ctx context.Context, key string) (*objectLease, error) {
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("object store: fetch refresh lease: %w", err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("object store: read refresh lease: %w", err)
+	}
+	lease := &objectLease{}
+	if err = json.Unmarshal(data, lease); err != nil {
+		// A damaged lock object must not block refreshes forever.
+		return &objectLease{}, nil
+	}
+	return lease, nil
+}
+
 func (s *ObjectTokenStore) ensureBucket(ctx context.Context) error {
 	exists, err := s.client.BucketExists(ctx, s.cfg.Bucket)
 	if err != nil {
@@ -385,6 +706,27 @@ func (s *ObjectTokenStore) syncConfigFromBucket(ctx context.Context, example str
 	return nil
 }
 
+func (s *ObjectTokenStore) syncAuditFromBucket(ctx context.Context) error {
+	key := s.prefixedKey(objectStoreAuditKey)
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("object store: fetch audit log: %w", err)
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("object store: read audit log: %w", err)
+	}
+	path := filepath.Join(filepath.Dir(s.configPath), audit.FileName)
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("object store: write audit log: %w", err)
+	}
+	return nil
+}
+
 func (s *ObjectTokenStore) syncAuthFromBucket(ctx context.Context) error {
 	if err := os.RemoveAll(s.authDir); err != nil {
 		return fmt.Errorf("object store: reset auth directory: %w", err)
@@ -602,6 +944,19 @@ func normalizeLineEndingsBytes(data []byte) []byte {
 	return bytes.ReplaceAll(replaced, []byte{'\r'}, []byte{'\n'})
 }
 
+// isPreconditionFailed reports whether a conditional write was rejected because the object
+// changed (or, for If-None-Match, already existed).
+func isPreconditionFailed(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed, resp.StatusCode == http.StatusConflict:
+		return true
+	case resp.Code == "PreconditionFailed", resp.Code == "ConditionalRequestConflict":
+		return true
+	}
+	return false
+}
+
 func isObjectNotFound(err error) bool {
 	if err == nil {
 		return false
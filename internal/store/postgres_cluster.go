@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultClusterStateTable = "cluster_state_store"
+	defaultClusterLoadTable  = "cluster_load_store"
+	// clusterLoadTTL is how long a replica's load report counts after its last update.
+	clusterLoadTTL = 10 * time.Second
+	// clusterListenMaxBackoff caps the delay between LISTEN reconnect attempts.
+	clusterListenMaxBackoff = 30 * time.Second
+)
+
+// PostgresClusterState shares credential cooldowns and in-flight counts between replicas that
+// use the same PostgreSQL database. State changes are written to a table and announced with
+// NOTIFY; each replica keeps a LISTEN connection and reads changed rows back.
+type PostgresClusterState struct {
+	db         *sql.DB
+	dsn        string
+	replica    string
+	stateTable string
+	loadTable  string
+	channel    string
+}
+
+type clusterNotification struct {
+	Replica string `json:"replica"`
+	AuthID  string `json:"auth_id"`
+}
+
+// ClusterState creates the cluster tables when missing and returns the shared state backend for
+// the replica identified by replicaID.
+func (s *PostgresStore) ClusterState(replicaID string) (cliproxyauth.ClusterState, error) {
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("postgres store: not initialized")
+	}
+	replicaID = strings.TrimSpace(replicaID)
+	if replicaID == "" {
+		return nil, fmt.Errorf("postgres store: replica id is required")
+	}
+	channel := defaultClusterStateTable + "_events"
+	if schema := strings.TrimSpace(s.cfg.Schema); schema != "" {
+		channel = schema + "_" + channel
+	}
+	cluster := &PostgresClusterState{
+		db:         s.db,
+		dsn:        s.cfg.DSN,
+		replica:    replicaID,
+		stateTable: s.fullTableName(defaultClusterStateTable),
+		loadTable:  s.fullTableName(defaultClusterLoadTable),
+		channel:    channel,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			auth_id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			replica TEXT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)
+	`, cluster.stateTable)); err != nil {
+		return nil, fmt.Errorf("postgres store: create cluster state table: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			replica TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, cluster.loadTable)); err != nil {
+		return nil, fmt.Errorf("postgres store: create cluster load table: %w", err)
+	}
+	return cluster, nil
+}
+
+// ReplicaID returns the identifier of this replica.
+func (c *PostgresClusterState) ReplicaID() string {
+	return c.replica
+}
+
+// PublishState stores the state of an auth unless a newer one is already stored, then notifies
+// the other replicas.
+func (c *PostgresClusterState) PublishState(ctx context.Context, authID string, state *cliproxyauth.RuntimeState) error {
+	if state == nil {
+		state = &cliproxyauth.RuntimeState{UpdatedAt: time.Now()}
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("postgres cluster: marshal state: %w", err)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS cur (auth_id, content, replica, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (auth_id)
+		DO UPDATE SET content = EXCLUDED.content, replica = EXCLUDED.replica, updated_at = EXCLUDED.updated_at
+		WHERE cur.updated_at <= EXCLUDED.updated_at
+	`, c.stateTable)
+	if _, err = c.db.ExecContext(ctx, query, authID, json.RawMessage(payload), c.replica, state.UpdatedAt); err != nil {
+		return fmt.Errorf("postgres cluster: upsert state: %w", err)
+	}
+	note, err := json.Marshal(clusterNotification{Replica: c.replica, AuthID: authID})
+	if err != nil {
+		return fmt.Errorf("postgres cluster: marshal notification: %w", err)
+	}
+	if _, err = c.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", c.channel, string(note)); err != nil {
+		return fmt.Errorf("postgres cluster: notify: %w", err)
+	}
+	return nil
+}
+
+// SyncLoad stores this replica's in-flight counts and sums the counts of the other replicas
+// that reported recently.
+func (c *PostgresClusterState) SyncLoad(ctx context.Context, inFlight map[string]int) (map[string]int, error) {
+	if inFlight == nil {
+		inFlight = map[string]int{}
+	}
+	payload, err := json.Marshal(inFlight)
+	if err != nil {
+		return nil, fmt.Errorf("postgres cluster: marshal load: %w", err)
+	}
+	upsert := fmt.Sprintf(`
+		INSERT INTO %s (replica, content, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (replica)
+		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+	`, c.loadTable)
+	if _, err = c.db.ExecContext(ctx, upsert, c.replica, json.RawMessage(payload)); err != nil {
+		return nil, fmt.Errorf("postgres cluster: upsert load: %w", err)
+	}
+	query := fmt.Sprintf("SELECT content FROM %s WHERE replica <> $1 AND updated_at > NOW() - make_interval(secs => $2)", c.loadTable)
+	rows, err := c.db.QueryContext(ctx, query, c.replica, clusterLoadTTL.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("postgres cluster: query load: %w", err)
+	}
+	defer rows.Close()
+	totals := make(map[string]int)
+	for rows.Next() {
+		var raw []byte
+		if err = rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("postgres cluster: scan load: %w", err)
+		}
+		var counts map[string]int
+		if err = json.Unmarshal(raw, &counts); err != nil {
+			continue
+		}
+		for id, count := range counts {
+			totals[id] += count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres cluster: iterate load: %w", err)
+	}
+	return totals, nil
+}
+
+// Subscribe listens for state changes of other replicas, reconnecting with backoff when the
+// LISTEN connection drops. After each (re)connect the full shared state is delivered so
+// changes missed while disconnected are caught up.
+func (c *PostgresClusterState) Subscribe(ctx context.Context, fn func(authID string, state *cliproxyauth.RuntimeState)) error {
+	backoff := time.Second
+	for {
+		err := c.listen(ctx, fn, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Warnf("postgres cluster: listen connection lost, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > clusterListenMaxBackoff {
+			backoff = clusterListenMaxBackoff
+		}
+	}
+}
+
+func (c *PostgresClusterState) listen(ctx context.Context, fn func(string, *cliproxyauth.RuntimeState), connected func()) error {
+	conn, err := pgx.Connect(ctx, c.dsn)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{c.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	connected()
+	if err = c.deliverAll(ctx, fn); err != nil {
+		return err
+	}
+	for {
+		notification, errWait := conn.WaitForNotification(ctx)
+		if errWait != nil {
+			return errWait
+		}
+		var note clusterNotification
+		if errJSON := json.Unmarshal([]byte(notification.Payload), &note); errJSON != nil || note.AuthID == "" || note.Replica == c.replica {
+			continue
+		}
+		state, errLoad := c.loadState(ctx, note.AuthID)
+		if errLoad != nil {
+			log.Debugf("postgres cluster: load state for %s: %v", note.AuthID, errLoad)
+			continue
+		}
+		if state != nil {
+			fn(note.AuthID, state)
+		}
+	}
+}
+
+func (c *PostgresClusterState) deliverAll(ctx context.Context, fn func(string, *cliproxyauth.RuntimeState)) error {
+	query := fmt.Sprintf("SELECT auth_id, content FROM %s WHERE replica <> $1", c.stateTable)
+	rows, err := c.db.QueryContext(ctx, query, c.replica)
+	if err != nil {
+		return fmt.Errorf("query state: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			authID string
+			raw    []byte
+		)
+		if err = rows.Scan(&authID, &raw); err != nil {
+			return fmt.Errorf("scan state: %w", err)
+		}
+		state := &cliproxyauth.RuntimeState{}
+		if err = json.Unmarshal(raw, state); err != nil {
+			continue
+		}
+		// Rows whose cooldowns ended carry nothing to catch up on and would only clear.
+		if state.Expired(time.Now()) {
+			continue
+		}
+		fn(authID, state)
+	}
+	return rows.Err()
+}
+
+func (c *PostgresClusterState) loadState(ctx context.Context, authID string) (*cliproxyauth.RuntimeState, error) {
+	query := fmt.Sprintf("SELECT content FROM %s WHERE auth_id = $1", c.stateTable)
+	var raw []byte
+	if err := c.db.QueryRowContext(ctx, query, authID).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state := &cliproxyauth.RuntimeState{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Close removes this replica's load report. The shared database handle stays open for the store.
+func (c *PostgresClusterState) Close(ctx context.Context) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE replica = $1", c.loadTable)
+	if _, err := c.db.ExecContext(ctx, query, c.replica); err != nil {
+		return fmt.Errorf("postgres cluster: delete load: %w", err)
+	}
+	return nil
+}
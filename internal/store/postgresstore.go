@@ -14,6 +14,7 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -22,6 +23,9 @@ import (
 const (
 	defaultConfigTable = "config_store"
 	defaultAuthTable   = "auth_store"
+	defaultAuditTable  = "audit_store"
+	defaultStateTable  = "runtime_state_store"
+	defaultCacheTable  = "response_cache_store"
 	defaultConfigKey   = "config"
 )
 
@@ -31,6 +35,9 @@ type PostgresStoreConfig struct {
 	Schema      string
 	ConfigTable string
 	AuthTable   string
+	AuditTable  string
+	StateTable  string
+	CacheTable  string
 	SpoolDir    string
 }
 
@@ -43,6 +50,11 @@ type PostgresStore struct {
 	configPath string
 	authDir    string
 	mu         sync.Mutex
+
+	// stateMu guards stateRows, the runtime state rows this process wrote, keyed by auth ID
+	// with the updated_at of the write.
+	stateMu   sync.Mutex
+	stateRows map[string]time.Time
 }
 
 // NewPostgresStore establishes a connection to PostgreSQL and prepares the local workspace.
@@ -58,6 +70,15 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	if cfg.AuthTable == "" {
 		cfg.AuthTable = defaultAuthTable
 	}
+	if cfg.AuditTable == "" {
+		cfg.AuditTable = defaultAuditTable
+	}
+	if cfg.StateTable == "" {
+		cfg.StateTable = defaultStateTable
+	}
+	if cfg.CacheTable == "" {
+		cfg.CacheTable = defaultCacheTable
+	}
 
 	spoolRoot := strings.TrimSpace(cfg.SpoolDir)
 	if spoolRoot == "" {
@@ -140,6 +161,40 @@ func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	`, authTable)); err != nil {
 		return fmt.Errorf("postgres store: create auth table: %w", err)
 	}
+	auditTable := s.fullTableName(s.cfg.AuditTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			seq BIGSERIAL PRIMARY KEY,
+			content JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, auditTable)); err != nil {
+		return fmt.Errorf("postgres store: create audit table: %w", err)
+	}
+	stateTable := s.fullTableName(s.cfg.StateTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, stateTable)); err != nil {
+		return fmt.Errorf("postgres store: create runtime state table: %w", err)
+	}
+	cacheTable := s.fullTableName(s.cfg.CacheTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content BYTEA NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		)
+	`, cacheTable)); err != nil {
+		return fmt.Errorf("postgres store: create response cache table: %w", err)
+	}
+	cacheIndex := quoteIdentifier(s.cfg.CacheTable + "_expires_at_idx")
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (expires_at)", cacheIndex, cacheTable)); err != nil {
+		return fmt.Errorf("postgres store: create response cache index: %w", err)
+	}
 	return nil
 }
 
@@ -154,6 +209,9 @@ func (s *PostgresStore) Bootstrap(ctx context.Context, exampleConfigPath string)
 	if err := s.syncAuthFromDatabase(ctx); err != nil {
 		return err
 	}
+	if err := s.syncAuditFromDatabase(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -279,37 +337,11 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 		if err = rows.Scan(&id, &payload, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("postgres store: scan auth row: %w", err)
 		}
-		path, errPath := s.absoluteAuthPath(id)
-		if errPath != nil {
-			log.WithError(errPath).Warnf("postgres store: skipping auth %s outside spool", id)
-			continue
-		}
-		metadata := make(map[string]any)
-		if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
-			log.WithError(err).Warnf("postgres store: skipping auth %s with invalid json", id)
+		auth, errAuth := s.authFromRecord(id, payload, createdAt, updatedAt)
+		if errAuth != nil {
+			log.WithError(errAuth).Warnf("postgres store: skipping auth %s", id)
 			continue
 		}
-		provider := strings.TrimSpace(valueAsString(metadata["type"]))
-		if provider == "" {
-			provider = "unknown"
-		}
-		attr := map[string]string{"path": path}
-		if email := strings.TrimSpace(valueAsString(metadata["email"])); email != "" {
-			attr["email"] = email
-		}
-		auth := &cliproxyauth.Auth{
-			ID:               normalizeAuthID(id),
-			Provider:         provider,
-			FileName:         normalizeAuthID(id),
-			Label:            labelFor(metadata),
-			Status:           cliproxyauth.StatusActive,
-			Attributes:       attr,
-			Metadata:         metadata,
-			CreatedAt:        createdAt,
-			UpdatedAt:        updatedAt,
-			LastRefreshedAt:  time.Time{},
-			NextRefreshAfter: time.Time{},
-		}
 		auths = append(auths, auth)
 	}
 	if err = rows.Err(); err != nil {
@@ -318,6 +350,97 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 	return auths, nil
 }
 
+// LoadAuth reads the current record of id from the database, bypassing the local spool.
+func (s *PostgresStore) LoadAuth(ctx context.Context, id string) (*cliproxyauth.Auth, error) {
+	query := fmt.Sprintf("SELECT id, content, created_at, updated_at FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
+	var (
+		relID     string
+		payload   string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx, query, normalizeAuthID(id)).Scan(&relID, &payload, &createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres store: load auth: %w", err)
+	}
+	return s.authFromRecord(relID, payload, createdAt, updatedAt)
+}
+
+// AcquireRefreshLease takes a session-level advisory lock keyed by the auth table and id on a
+// dedicated connection. The lock is released by release, after ttl, or when the connection dies
+// with the replica holding it.
+func (s *PostgresStore) AcquireRefreshLease(ctx context.Context, authID string, ttl time.Duration) (func(), bool, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("postgres store: acquire lease connection: %w", err)
+	}
+	table := s.fullTableName(s.cfg.AuthTable)
+	authID = normalizeAuthID(authID)
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1), hashtext($2))", table, authID).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("postgres store: try advisory lock: %w", err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, errUnlock := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock(hashtext($1), hashtext($2))", table, authID); errUnlock != nil {
+				log.WithError(errUnlock).Warnf("postgres store: release refresh lease for %s", authID)
+			}
+			_ = conn.Close()
+		})
+	}
+	if ttl > 0 {
+		timer := time.AfterFunc(ttl, release)
+		return func() {
+			timer.Stop()
+			release()
+		}, true, nil
+	}
+	return release, true, nil
+}
+
+func (s *PostgresStore) authFromRecord(id, payload string, createdAt, updatedAt time.Time) (*cliproxyauth.Auth, error) {
+	path, err := s.absoluteAuthPath(id)
+	if err != nil {
+		return nil, fmt.Errorf("auth outside spool: %w", err)
+	}
+	metadata := make(map[string]any)
+	if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
+		return nil, fmt.Errorf("invalid auth json: %w", err)
+	}
+	provider := strings.TrimSpace(valueAsString(metadata["type"]))
+	if provider == "" {
+		provider = "unknown"
+	}
+	attr := map[string]string{"path": path}
+	if email := strings.TrimSpace(valueAsString(metadata["email"])); email != "" {
+		attr["email"] = email
+	}
+	return &cliproxyauth.Auth{
+		ID:               normalizeAuthID(id),
+		Provider:         provider,
+		FileName:         normalizeAuthID(id),
+		Label:            labelFor(metadata),
+		Status:           cliproxyauth.StatusActive,
+		Attributes:       attr,
+		Metadata:         metadata,
+		CreatedAt:        createdAt,
+		UpdatedAt:        updatedAt,
+		LastRefreshedAt:  time.Time{},
+		NextRefreshAfter: time.Time{},
+	}, nil
+}
+
 // Delete removes an auth file and the corresponding database record.
 func (s *PostgresStore) Delete(ctx context.Context, id string) error {
 	id = strings.TrimSpace(id)
@@ -391,6 +514,25 @@ func (s *PostgresStore) PersistConfig(ctx context.Context) error {
 	return s.persistConfig(ctx, data)
 }
 
+// PersistAuditLog appends audit records to PostgreSQL in one transaction.
+func (s *PostgresStore) PersistAuditLog(ctx context.Context, _ string, records [][]byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin audit records: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	query := fmt.Sprintf("INSERT INTO %s (content, created_at) VALUES ($1, NOW())", s.fullTableName(s.cfg.AuditTable))
+	for _, record := range records {
+		if _, err = tx.ExecContext(ctx, query, json.RawMessage(record)); err != nil {
+			return fmt.Errorf("postgres store: insert audit record: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit audit records: %w", err)
+	}
+	return nil
+}
+
 // syncConfigFromDatabase writes the database-stored config to disk or seeds the database from template.
 func (s *PostgresStore) syncConfigFromDatabase(ctx context.Context, exampleConfigPath string) error {
 	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.ConfigTable))
@@ -475,6 +617,34 @@ func (s *PostgresStore) syncAuthFromDatabase(ctx context.Context) error {
 	return nil
 }
 
+// syncAuditFromDatabase rebuilds the local audit log from PostgreSQL data.
+func (s *PostgresStore) syncAuditFromDatabase(ctx context.Context) error {
+	query := fmt.Sprintf("SELECT content FROM %s ORDER BY seq", s.fullTableName(s.cfg.AuditTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("postgres store: load audit log from database: %w", err)
+	}
+	defer rows.Close()
+
+	var buf strings.Builder
+	for rows.Next() {
+		var payload string
+		if err = rows.Scan(&payload); err != nil {
+			return fmt.Errorf("postgres store: scan audit row: %w", err)
+		}
+		buf.WriteString(payload)
+		buf.WriteByte('\n')
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("postgres store: iterate audit rows: %w", err)
+	}
+	path := filepath.Join(filepath.Dir(s.configPath), audit.FileName)
+	if err = os.WriteFile(path, []byte(buf.String()), 0o600); err != nil {
+		return fmt.Errorf("postgres store: write audit log to spool: %w", err)
+	}
+	return nil
+}
+
 func (s *PostgresStore) syncAuthFile(ctx context.Context, relID, path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -514,6 +684,130 @@ func (s *PostgresStore) persistAuth(ctx context.Context, relID string, data []by
 	return nil
 }
 
+// LoadRuntimeState reads the cooldown state saved by SaveRuntimeState, keyed by auth ID.
+func (s *PostgresStore) LoadRuntimeState(ctx context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	query := fmt.Sprintf("SELECT id, content FROM %s", s.fullTableName(s.cfg.StateTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: load runtime state: %w", err)
+	}
+	defer rows.Close()
+	states := make(map[string]*cliproxyauth.RuntimeState)
+	for rows.Next() {
+		var (
+			id      string
+			payload []byte
+		)
+		if err = rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("postgres store: scan runtime state: %w", err)
+		}
+		state := &cliproxyauth.RuntimeState{}
+		if err = json.Unmarshal(payload, state); err != nil {
+			log.WithError(err).Warnf("postgres store: skipping runtime state %s", id)
+			continue
+		}
+		states[id] = state
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate runtime state: %w", err)
+	}
+	return states, nil
+}
+
+// SaveRuntimeState upserts one row per auth in states. Replicas share the table, so rows are
+// only deleted when this process wrote them and no other replica has overwritten them since.
+func (s *PostgresStore) SaveRuntimeState(ctx context.Context, states map[string]*cliproxyauth.RuntimeState) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres store: begin runtime state: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	table := s.fullTableName(s.cfg.StateTable)
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND updated_at = $2", table)
+	for id, writtenAt := range s.stateRows {
+		if _, ok := states[id]; ok {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, deleteQuery, id, writtenAt); err != nil {
+			return fmt.Errorf("postgres store: delete runtime state: %w", err)
+		}
+	}
+	upsertQuery := fmt.Sprintf(`
+		INSERT INTO %s (id, content, updated_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, table)
+	rows := make(map[string]time.Time, len(states))
+	for id, state := range states {
+		payload, errMarshal := json.Marshal(state)
+		if errMarshal != nil {
+			return fmt.Errorf("postgres store: marshal runtime state: %w", errMarshal)
+		}
+		var writtenAt time.Time
+		if err = tx.QueryRowContext(ctx, upsertQuery, id, json.RawMessage(payload)).Scan(&writtenAt); err != nil {
+			return fmt.Errorf("postgres store: upsert runtime state: %w", err)
+		}
+		rows[id] = writtenAt
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres store: commit runtime state: %w", err)
+	}
+	s.stateRows = rows
+	return nil
+}
+
+// LoadCachedResponse returns the response cache entry stored under key unless it expired.
+func (s *PostgresStore) LoadCachedResponse(ctx context.Context, key string) ([]byte, error) {
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1 AND expires_at > NOW()", s.fullTableName(s.cfg.CacheTable))
+	var content []byte
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&content); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("postgres store: load cached response: %w", err)
+	}
+	return content, nil
+}
+
+// SaveCachedResponse upserts a response cache entry. Expired entries are removed by
+// PruneCachedResponses.
+func (s *PostgresStore) SaveCachedResponse(ctx context.Context, key string, data []byte, expiresAt time.Time) error {
+	table := s.fullTableName(s.cfg.CacheTable)
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id)
+		DO UPDATE SET content = EXCLUDED.content, expires_at = EXCLUDED.expires_at
+	`, table)
+	if _, err := s.db.ExecContext(ctx, query, key, data, expiresAt); err != nil {
+		return fmt.Errorf("postgres store: save cached response: %w", err)
+	}
+	return nil
+}
+
+// PruneCachedResponses removes expired response cache entries, then the entries beyond
+// maxEntries that expire first. All entries share one TTL, so those are the oldest.
+func (s *PostgresStore) PruneCachedResponses(ctx context.Context, maxEntries int, _ time.Duration) error {
+	table := s.fullTableName(s.cfg.CacheTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= NOW()", table)); err != nil {
+		return fmt.Errorf("postgres store: purge cached responses: %w", err)
+	}
+	if maxEntries <= 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s WHERE id IN (
+			SELECT id FROM %[1]s ORDER BY expires_at DESC OFFSET $1
+		)
+	`, table)
+	if _, err := s.db.ExecContext(ctx, query, maxEntries); err != nil {
+		return fmt.Errorf("postgres store: evict cached responses: %w", err)
+	}
+	return nil
+}
+
 func (s *PostgresStore) deleteAuthRecord(ctx context.Context, relID string) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
 	if _, err := s.db.ExecContext(ctx, query, relID); err != nil {
@@ -94,6 +94,8 @@ type RequestDetail struct {
 	AuthIndex string     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	Hedged    bool       `json:"hedged,omitempty"`
+	Cached    bool       `json:"cached,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -202,6 +204,8 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		AuthIndex: record.AuthIndex,
 		Tokens:    detail,
 		Failed:    failed,
+		Hedged:    record.Hedged,
+		Cached:    record.Cached,
 	})
 
 	s.requestsByDay[dayKey]++
@@ -63,12 +63,63 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 		changes = append(changes, fmt.Sprintf("quota-exceeded.switch-preview-model: %t -> %t", oldCfg.QuotaExceeded.SwitchPreviewModel, newCfg.QuotaExceeded.SwitchPreviewModel))
 	}
 
+	// Routing
+	if strings.TrimSpace(oldCfg.Routing.Strategy) != strings.TrimSpace(newCfg.Routing.Strategy) {
+		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", strings.TrimSpace(oldCfg.Routing.Strategy), strings.TrimSpace(newCfg.Routing.Strategy)))
+	}
+	if oldCfg.Routing.SessionAffinity != newCfg.Routing.SessionAffinity {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity: %t -> %t", oldCfg.Routing.SessionAffinity, newCfg.Routing.SessionAffinity))
+	}
+	if oldCfg.Routing.SessionAffinityTTL != newCfg.Routing.SessionAffinityTTL {
+		changes = append(changes, fmt.Sprintf("routing.session-affinity-ttl: %d -> %d", oldCfg.Routing.SessionAffinityTTL, newCfg.Routing.SessionAffinityTTL))
+	}
+
+	// Circuit breaker
+	if oldCfg.CircuitBreaker != newCfg.CircuitBreaker {
+		changes = append(changes, fmt.Sprintf("circuit-breaker: enabled=%t threshold=%d open=%ds max-open=%ds -> enabled=%t threshold=%d open=%ds max-open=%ds",
+			oldCfg.CircuitBreaker.Enabled, oldCfg.CircuitBreaker.FailureThreshold, oldCfg.CircuitBreaker.OpenSeconds, oldCfg.CircuitBreaker.MaxOpenSeconds,
+			newCfg.CircuitBreaker.Enabled, newCfg.CircuitBreaker.FailureThreshold, newCfg.CircuitBreaker.OpenSeconds, newCfg.CircuitBreaker.MaxOpenSeconds))
+	}
+	if oldCfg.Cluster != newCfg.Cluster {
+		changes = append(changes, fmt.Sprintf("cluster: enabled=%t replica-id=%q -> enabled=%t replica-id=%q (restart required)",
+			oldCfg.Cluster.Enabled, oldCfg.Cluster.ReplicaID, newCfg.Cluster.Enabled, newCfg.Cluster.ReplicaID))
+	}
+	if oldCfg.RequestQueue != newCfg.RequestQueue {
+		changes = append(changes, fmt.Sprintf("request-queue: enabled=%t opt-in=%t depth=%d per-client=%d timeout=%ds -> enabled=%t opt-in=%t depth=%d per-client=%d timeout=%ds",
+			oldCfg.RequestQueue.Enabled, oldCfg.RequestQueue.OptIn, oldCfg.RequestQueue.MaxDepth, oldCfg.RequestQueue.MaxPerClient, oldCfg.RequestQueue.TimeoutSeconds,
+			newCfg.RequestQueue.Enabled, newCfg.RequestQueue.OptIn, newCfg.RequestQueue.MaxDepth, newCfg.RequestQueue.MaxPerClient, newCfg.RequestQueue.TimeoutSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.Hedging, newCfg.Hedging) {
+		changes = append(changes, fmt.Sprintf("hedging: models=%d max-extra=%d%% -> models=%d max-extra=%d%%",
+			len(oldCfg.Hedging.Models), oldCfg.Hedging.MaxExtraPercent, len(newCfg.Hedging.Models), newCfg.Hedging.MaxExtraPercent))
+	}
+	if oldCfg.ResponseCache != newCfg.ResponseCache {
+		changes = append(changes, fmt.Sprintf("response-cache: enabled=%t backend=%s ttl=%ds max-entries=%d -> enabled=%t backend=%s ttl=%ds max-entries=%d",
+			oldCfg.ResponseCache.Enabled, oldCfg.ResponseCache.Backend, oldCfg.ResponseCache.TTLSeconds, oldCfg.ResponseCache.MaxEntries,
+			newCfg.ResponseCache.Enabled, newCfg.ResponseCache.Backend, newCfg.ResponseCache.TTLSeconds, newCfg.ResponseCache.MaxEntries))
+	}
+	if !reflect.DeepEqual(oldCfg.ContextWindow, newCfg.ContextWindow) {
+		changes = append(changes, fmt.Sprintf("context-window: strategy=%q models=%d keys=%d -> strategy=%q models=%d keys=%d",
+			oldCfg.ContextWindow.Default.Strategy, len(oldCfg.ContextWindow.Models), len(oldCfg.ContextWindow.Keys),
+			newCfg.ContextWindow.Default.Strategy, len(newCfg.ContextWindow.Models), len(newCfg.ContextWindow.Keys)))
+	}
+	if !reflect.DeepEqual(oldCfg.HealthProbe, newCfg.HealthProbe) {
+		changes = append(changes, fmt.Sprintf("health-probe: enabled=%t interval=%ds timeout=%ds providers=%d -> enabled=%t interval=%ds timeout=%ds providers=%d",
+			oldCfg.HealthProbe.Enabled, oldCfg.HealthProbe.IntervalSeconds, oldCfg.HealthProbe.TimeoutSeconds, len(oldCfg.HealthProbe.Providers),
+			newCfg.HealthProbe.Enabled, newCfg.HealthProbe.IntervalSeconds, newCfg.HealthProbe.TimeoutSeconds, len(newCfg.HealthProbe.Providers)))
+	}
+
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
 		changes = append(changes, fmt.Sprintf("api-keys count: %d -> %d", len(oldCfg.APIKeys), len(newCfg.APIKeys)))
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if len(oldCfg.ClientKeys) != len(newCfg.ClientKeys) {
+		changes = append(changes, fmt.Sprintf("client-keys count: %d -> %d", len(oldCfg.ClientKeys), len(newCfg.ClientKeys)))
+	} else if !reflect.DeepEqual(oldCfg.ClientKeys, newCfg.ClientKeys) {
+		changes = append(changes, "client-keys: entries updated (count unchanged, redacted)")
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -84,6 +135,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("gemini[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("gemini[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.Limits != n.Limits || !reflect.DeepEqual(o.ModelLimits, n.ModelLimits) {
+				changes = append(changes, fmt.Sprintf("gemini[%d].limits: updated", i))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].api-key: updated", i))
 			}
@@ -119,6 +179,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("claude[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("claude[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("claude[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.Limits != n.Limits || !reflect.DeepEqual(o.ModelLimits, n.ModelLimits) {
+				changes = append(changes, fmt.Sprintf("claude[%d].limits: updated", i))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("claude[%d].api-key: updated", i))
 			}
@@ -154,6 +223,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("codex[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("codex[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("codex[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
+			if o.Limits != n.Limits || !reflect.DeepEqual(o.ModelLimits, n.ModelLimits) {
+				changes = append(changes, fmt.Sprintf("codex[%d].limits: updated", i))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("codex[%d].api-key: updated", i))
 			}
@@ -212,6 +290,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if entries, _ := DiffOAuthModelMappingChanges(oldCfg.OAuthModelMappings, newCfg.OAuthModelMappings); len(entries) > 0 {
 		changes = append(changes, entries...)
 	}
+	if !reflect.DeepEqual(oldCfg.Fallbacks, newCfg.Fallbacks) {
+		changes = append(changes, fmt.Sprintf("fallbacks: updated (%d -> %d chains)", len(oldCfg.Fallbacks), len(newCfg.Fallbacks)))
+	}
 
 	// Remote management (never print the key)
 	if oldCfg.RemoteManagement.AllowRemote != newCfg.RemoteManagement.AllowRemote {
@@ -235,6 +316,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			changes = append(changes, "remote-management.secret-key: updated")
 		}
 	}
+	if !reflect.DeepEqual(oldCfg.RemoteManagement.Keys, newCfg.RemoteManagement.Keys) {
+		changes = append(changes, fmt.Sprintf("remote-management.keys: updated (%d -> %d, redacted)", len(oldCfg.RemoteManagement.Keys), len(newCfg.RemoteManagement.Keys)))
+	}
 
 	// OpenAI compatibility providers (summarized)
 	if compat := DiffOpenAICompatibility(oldCfg.OpenAICompatibility, newCfg.OpenAICompatibility); len(compat) > 0 {
@@ -260,6 +344,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.Prefix) != strings.TrimSpace(n.Prefix) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].prefix: %s -> %s", i, strings.TrimSpace(o.Prefix), strings.TrimSpace(n.Prefix)))
 			}
+			if o.Priority != n.Priority {
+				changes = append(changes, fmt.Sprintf("vertex[%d].priority: %d -> %d", i, o.Priority, n.Priority))
+			}
+			if o.Weight != n.Weight {
+				changes = append(changes, fmt.Sprintf("vertex[%d].weight: %d -> %d", i, o.Weight, n.Weight))
+			}
 			if strings.TrimSpace(o.APIKey) != strings.TrimSpace(n.APIKey) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].api-key: updated", i))
 			}
@@ -66,6 +66,8 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(entry.Headers, attrs)
+		addRoutingAttrs(attrs, entry.Priority, entry.Weight)
+		addRateLimitAttrs(attrs, entry.Limits, entry.ModelLimits)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "gemini",
@@ -110,6 +112,8 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addRoutingAttrs(attrs, ck.Priority, ck.Weight)
+		addRateLimitAttrs(attrs, ck.Limits, ck.ModelLimits)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -154,6 +158,8 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addRoutingAttrs(attrs, ck.Priority, ck.Weight)
+		addRateLimitAttrs(attrs, ck.Limits, ck.ModelLimits)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -209,6 +215,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 				attrs["models_hash"] = hash
 			}
 			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addRoutingAttrs(attrs, entry.Priority, entry.Weight)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
@@ -282,6 +289,7 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(compat.Headers, attrs)
+		addRoutingAttrs(attrs, compat.Priority, compat.Weight)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   providerName,
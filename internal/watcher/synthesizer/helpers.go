@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -108,3 +109,40 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 		attrs["header:"+key] = val
 	}
 }
+
+// addRoutingAttrs records non-default priority and weight for the weighted routing strategy.
+func addRoutingAttrs(attrs map[string]string, priority, weight int) {
+	if attrs == nil {
+		return
+	}
+	if priority != 0 {
+		attrs["priority"] = strconv.Itoa(priority)
+	}
+	if weight > 0 {
+		attrs["weight"] = strconv.Itoa(weight)
+	}
+}
+
+// addRateLimitAttrs records declared provider limits for client-side rate shaping. Model limits
+// are stored under the limit name suffixed with ":" and the upstream model.
+func addRateLimitAttrs(attrs map[string]string, limits config.RateLimit, modelLimits []config.ModelRateLimit) {
+	if attrs == nil {
+		return
+	}
+	setRateLimitAttrs(attrs, "", limits)
+	for _, entry := range modelLimits {
+		setRateLimitAttrs(attrs, ":"+entry.Model, entry.RateLimit)
+	}
+}
+
+func setRateLimitAttrs(attrs map[string]string, suffix string, limits config.RateLimit) {
+	if limits.RequestsPerMinute > 0 {
+		attrs["rpm"+suffix] = strconv.Itoa(limits.RequestsPerMinute)
+	}
+	if limits.TokensPerMinute > 0 {
+		attrs["tpm"+suffix] = strconv.Itoa(limits.TokensPerMinute)
+	}
+	if limits.MaxConcurrent > 0 && suffix == "" {
+		attrs["max_concurrent"] = strconv.Itoa(limits.MaxConcurrent)
+	}
+}
@@ -0,0 +1,157 @@
+package access
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Policy describes per-client restrictions attached to an authenticated request.
+// A nil Policy places no restrictions on the caller.
+type Policy struct {
+	// AllowedModels lists model name patterns ('*' wildcards) the client may request.
+	AllowedModels []string
+	// AllowedProviders lists provider keys the client may execute against.
+	AllowedProviders []string
+	// Prefix binds the client to credentials registered under this model prefix.
+	Prefix string
+	// AllowedEndpoints lists request path patterns ('*' wildcards) the client may call.
+	AllowedEndpoints []string
+}
+
+// PolicyFromConfig converts a configured API key policy into its runtime form.
+// It returns nil when the entry carries no restriction.
+func PolicyFromConfig(entry *config.APIKeyPolicy) *Policy {
+	if entry == nil {
+		return nil
+	}
+	policy := &Policy{
+		AllowedModels:    append([]string(nil), entry.AllowedModels...),
+		AllowedProviders: append([]string(nil), entry.AllowedProviders...),
+		Prefix:           strings.Trim(strings.TrimSpace(entry.Prefix), "/"),
+		AllowedEndpoints: append([]string(nil), entry.AllowedEndpoints...),
+	}
+	if policy.IsEmpty() {
+		return nil
+	}
+	return policy
+}
+
+// IsEmpty reports whether the policy imposes no restriction.
+func (p *Policy) IsEmpty() bool {
+	if p == nil {
+		return true
+	}
+	return len(p.AllowedModels) == 0 && len(p.AllowedProviders) == 0 && p.Prefix == "" && len(p.AllowedEndpoints) == 0
+}
+
+// AllowsModel reports whether the client may request the given model.
+// A leading policy prefix ("<prefix>/") is ignored when matching.
+func (p *Policy) AllowsModel(model string) bool {
+	if p == nil || len(p.AllowedModels) == 0 {
+		return true
+	}
+	model = strings.TrimSpace(model)
+	if p.Prefix != "" {
+		model = strings.TrimPrefix(model, p.Prefix+"/")
+	}
+	lower := strings.ToLower(model)
+	for _, pattern := range p.AllowedModels {
+		if matchPattern(strings.ToLower(pattern), lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProvider reports whether the client may execute against the given provider key.
+func (p *Policy) AllowsProvider(provider string) bool {
+	if p == nil || len(p.AllowedProviders) == 0 {
+		return true
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	for _, allowed := range p.AllowedProviders {
+		if strings.EqualFold(strings.TrimSpace(allowed), provider) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsEndpoint reports whether the client may call the given request path.
+func (p *Policy) AllowsEndpoint(path string) bool {
+	if p == nil || len(p.AllowedEndpoints) == 0 {
+		return true
+	}
+	path = strings.TrimSpace(path)
+	for _, pattern := range p.AllowedEndpoints {
+		if matchPattern(strings.TrimSpace(pattern), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterProviders returns the subset of providers the client may execute against, preserving order.
+func (p *Policy) FilterProviders(providers []string) []string {
+	if p == nil || len(p.AllowedProviders) == 0 {
+		return providers
+	}
+	out := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if p.AllowsProvider(provider) {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// ApplyPrefix rewrites model to "<prefix>/<model>" when the policy binds a prefix
+// and the model does not already carry it.
+func (p *Policy) ApplyPrefix(model string) string {
+	if p == nil || p.Prefix == "" || model == "" {
+		return model
+	}
+	needle := p.Prefix + "/"
+	if strings.HasPrefix(model, needle) {
+		return model
+	}
+	return needle + model
+}
+
+// matchPattern performs glob-style matching where '*' matches zero or more characters.
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return false
+	}
+	if pattern == "*" {
+		return true
+	}
+	pi, si := 0, 0
+	starIdx := -1
+	matchIdx := 0
+	for si < len(value) {
+		if pi < len(pattern) && pattern[pi] == value[si] {
+			pi++
+			si++
+			continue
+		}
+		if pi < len(pattern) && pattern[pi] == '*' {
+			starIdx = pi
+			matchIdx = si
+			pi++
+			continue
+		}
+		if starIdx != -1 {
+			pi = starIdx + 1
+			matchIdx++
+			si = matchIdx
+			continue
+		}
+		return false
+	}
+	for pi < len(pattern) && pattern[pi] == '*' {
+		pi++
+	}
+	return pi == len(pattern)
+}
@@ -0,0 +1,80 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestPolicyFromConfig_EmptyEntryReturnsNil(t *testing.T) {
+	t.Parallel()
+
+	if got := PolicyFromConfig(&config.APIKeyPolicy{APIKey: "k"}); got != nil {
+		t.Fatalf("PolicyFromConfig() = %+v, want nil", got)
+	}
+}
+
+func TestPolicyAllowsModel(t *testing.T) {
+	t.Parallel()
+
+	policy := PolicyFromConfig(&config.APIKeyPolicy{
+		APIKey:        "k",
+		AllowedModels: []string{"gemini-2.5-*", "claude-sonnet-4"},
+		Prefix:        "team-a",
+	})
+	cases := []struct {
+		model string
+		want  bool
+	}{
+		{"gemini-2.5-pro", true},
+		{"team-a/gemini-2.5-flash", true},
+		{"Claude-Sonnet-4", true},
+		{"claude-opus-4", false},
+		{"gpt-5", false},
+	}
+	for _, tc := range cases {
+		if got := policy.AllowsModel(tc.model); got != tc.want {
+			t.Fatalf("AllowsModel(%q) = %v, want %v", tc.model, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyFilterProvidersAndPrefix(t *testing.T) {
+	t.Parallel()
+
+	policy := PolicyFromConfig(&config.APIKeyPolicy{
+		APIKey:           "k",
+		AllowedProviders: []string{"claude"},
+		Prefix:           "team-a",
+		AllowedEndpoints: []string{"/v1/messages*"},
+	})
+
+	got := policy.FilterProviders([]string{"gemini", "claude", "codex"})
+	if len(got) != 1 || got[0] != "claude" {
+		t.Fatalf("FilterProviders() = %v, want [claude]", got)
+	}
+	if model := policy.ApplyPrefix("claude-sonnet-4"); model != "team-a/claude-sonnet-4" {
+		t.Fatalf("ApplyPrefix() = %q, want %q", model, "team-a/claude-sonnet-4")
+	}
+	if model := policy.ApplyPrefix("team-a/claude-sonnet-4"); model != "team-a/claude-sonnet-4" {
+		t.Fatalf("ApplyPrefix() = %q, want unchanged", model)
+	}
+	if !policy.AllowsEndpoint("/v1/messages/count_tokens") {
+		t.Fatalf("AllowsEndpoint(/v1/messages/count_tokens) = false, want true")
+	}
+	if policy.AllowsEndpoint("/v1/chat/completions") {
+		t.Fatalf("AllowsEndpoint(/v1/chat/completions) = true, want false")
+	}
+}
+
+func TestNilPolicyAllowsEverything(t *testing.T) {
+	t.Parallel()
+
+	var policy *Policy
+	if !policy.AllowsModel("anything") || !policy.AllowsProvider("any") || !policy.AllowsEndpoint("/v1/x") {
+		t.Fatalf("nil policy should not restrict access")
+	}
+	if got := policy.ApplyPrefix("m"); got != "m" {
+		t.Fatalf("ApplyPrefix() = %q, want %q", got, "m")
+	}
+}
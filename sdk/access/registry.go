@@ -20,6 +20,8 @@ type Result struct {
 	Provider  string
 	Principal string
 	Metadata  map[string]string
+	// Policy optionally restricts what the authenticated principal may access.
+	Policy *Policy
 }
 
 // ProviderFactory builds a provider from configuration data.
@@ -62,7 +64,23 @@ func BuildProviders(root *config.SDKConfig) ([]Provider, error) {
 	if root == nil {
 		return nil, nil
 	}
-	providers := make([]Provider, 0, len(root.Access.Providers))
+	providers := make([]Provider, 0, len(root.Access.Providers)+1)
+	hasInline := false
+	for i := range root.Access.Providers {
+		if root.Access.Providers[i].Type == config.AccessProviderTypeConfigAPIKey {
+			hasInline = true
+			break
+		}
+	}
+	if !hasInline {
+		if inline := root.InlineAPIKeyProvider(); inline != nil {
+			provider, err := BuildProvider(inline, root)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		}
+	}
 	for i := range root.Access.Providers {
 		providerCfg := &root.Access.Providers[i]
 		if providerCfg.Type == "" {
@@ -74,14 +92,5 @@ func BuildProviders(root *config.SDKConfig) ([]Provider, error) {
 		}
 		providers = append(providers, provider)
 	}
-	if len(providers) == 0 {
-		if inline := config.MakeInlineAPIKeyProvider(root.APIKeys); inline != nil {
-			provider, err := BuildProvider(inline, root)
-			if err != nil {
-				return nil, err
-			}
-			providers = append(providers, provider)
-		}
-	}
 	return providers, nil
 }
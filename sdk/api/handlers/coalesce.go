@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"golang.org/x/net/context"
+)
+
+// Request kinds that can be coalesced.
+const (
+	coalesceCount     = "count"
+	coalesceExecute   = "execute"
+	coalesceStreaming = "stream"
+)
+
+// errCoalescedStreamAbandoned ends an attached stream whose first client went away after chunks
+// of it had been forwarded.
+var errCoalescedStreamAbandoned = errors.New("coalesced upstream stream was cancelled by the request that started it")
+
+// inflight holds the coalesced calls of all handlers.
+var inflight = &coalescer{
+	calls:   make(map[string]*coalescedCall),
+	streams: make(map[string]*coalescedStream),
+}
+
+// coalesceKey returns the fingerprint identical in-flight requests share, or "" when requests of
+// this kind are not coalesced. The fingerprint covers the client API key, the endpoint and the
+// raw request body.
+func coalesceKey(cfg *config.SDKConfig, ctx context.Context, kind, handlerType, modelName string, rawJSON []byte, alt string) string {
+	if cfg == nil || ctx == nil {
+		return ""
+	}
+	switch kind {
+	case coalesceCount:
+		if !cfg.Coalescing.CountTokens {
+			return ""
+		}
+	case coalesceExecute:
+		if !cfg.Coalescing.NonStreaming {
+			return ""
+		}
+	case coalesceStreaming:
+		if !cfg.Coalescing.Streaming {
+			return ""
+		}
+	default:
+		return ""
+	}
+	scope, path := "", ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		scope = ginCtx.GetString("apiKey")
+		path = ginCtx.Request.URL.Path
+	}
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(scope), []byte(path), []byte(kind), []byte(handlerType), []byte(modelName), []byte(alt), rawJSON} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// markCoalesced reports in the X-CPA-Coalesced response header that the request was attached to
+// another one.
+func markCoalesced(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header("X-CPA-Coalesced", "true")
+	}
+}
+
+// coalescer attaches identical requests to the call already in flight for them. The call stays
+// bound to the request that started it; when that request goes away, attached requests that did
+// not receive anything yet run their own call.
+type coalescer struct {
+	mu      sync.Mutex
+	calls   map[string]*coalescedCall
+	streams map[string]*coalescedStream
+}
+
+type coalescedCall struct {
+	done      chan struct{}
+	payload   []byte
+	errMsg    *interfaces.ErrorMessage
+	abandoned bool
+}
+
+// do runs fn for key, or waits for the identical call in flight and returns its result.
+func (c *coalescer) do(ctx context.Context, key string, fn func() ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: ctx.Err()}
+		}
+		if call.abandoned {
+			return fn()
+		}
+		markCoalesced(ctx)
+		return cloneBytes(call.payload), call.errMsg
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.payload, call.errMsg = fn()
+	call.abandoned = call.errMsg != nil && ctx.Err() != nil
+	return call.payload, call.errMsg
+}
+
+// coalescedStream buffers the chunks of a stream so that attached clients can catch up.
+type coalescedStream struct {
+	mu        sync.Mutex
+	chunks    [][]byte
+	errMsg    *interfaces.ErrorMessage
+	done      bool
+	abandoned bool
+	// updated is closed and replaced whenever the stream changes.
+	updated chan struct{}
+}
+
+func (s *coalescedStream) update(fn func()) {
+	s.mu.Lock()
+	fn()
+	close(s.updated)
+	s.updated = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// stream starts the stream for key, or attaches to the identical stream in flight.
+func (c *coalescer) stream(ctx context.Context, key string, start func() (<-chan []byte, <-chan *interfaces.ErrorMessage)) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	c.mu.Lock()
+	if flight, ok := c.streams[key]; ok {
+		c.mu.Unlock()
+		markCoalesced(ctx)
+		return flight.follow(ctx, start)
+	}
+	flight := &coalescedStream{updated: make(chan struct{})}
+	c.streams[key] = flight
+	c.mu.Unlock()
+
+	dataChan, errChan := start()
+	out := make(chan []byte)
+	outErr := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(out)
+		defer close(outErr)
+		defer func() {
+			c.mu.Lock()
+			delete(c.streams, key)
+			c.mu.Unlock()
+			flight.update(func() {
+				flight.done = true
+				flight.abandoned = flight.errMsg == nil && ctx.Err() != nil
+			})
+		}()
+		leaderGone := false
+		for dataChan != nil || errChan != nil {
+			select {
+			case chunk, ok := <-dataChan:
+				if !ok {
+					dataChan = nil
+					continue
+				}
+				flight.update(func() { flight.chunks = append(flight.chunks, chunk) })
+				if !leaderGone {
+					select {
+					case out <- chunk:
+					case <-ctx.Done():
+						leaderGone = true
+					}
+				}
+			case errMsg, ok := <-errChan:
+				if !ok {
+					errChan = nil
+					continue
+				}
+				if errMsg == nil {
+					continue
+				}
+				flight.update(func() { flight.errMsg = errMsg })
+				if !leaderGone {
+					outErr <- errMsg
+				}
+			}
+		}
+	}()
+	return out, outErr
+}
+
+// follow replays the chunks of the stream sent so far and then forwards the live ones. When the
+// stream was abandoned before anything was forwarded, start runs a stream of its own.
+func (s *coalescedStream) follow(ctx context.Context, start func() (<-chan []byte, <-chan *interfaces.ErrorMessage)) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	out := make(chan []byte)
+	outErr := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(out)
+		defer close(outErr)
+		sent := 0
+		for {
+			s.mu.Lock()
+			pending := s.chunks[sent:]
+			done, abandoned, errMsg, updated := s.done, s.abandoned, s.errMsg, s.updated
+			s.mu.Unlock()
+			for _, chunk := range pending {
+				select {
+				case out <- cloneBytes(chunk):
+					sent++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if done {
+				switch {
+				case abandoned && sent == 0:
+					forwardStream(ctx, out, outErr, start)
+				case abandoned:
+					outErr <- &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errCoalescedStreamAbandoned}
+				case errMsg != nil:
+					outErr <- errMsg
+				}
+				return
+			}
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
+
+// forwardStream copies a stream of its own to out and outErr.
+func forwardStream(ctx context.Context, out chan<- []byte, outErr chan<- *interfaces.ErrorMessage, start func() (<-chan []byte, <-chan *interfaces.ErrorMessage)) {
+	dataChan, errChan := start()
+	for dataChan != nil || errChan != nil {
+		select {
+		case chunk, ok := <-dataChan:
+			if !ok {
+				dataChan = nil
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			if errMsg != nil {
+				outErr <- errMsg
+				return
+			}
+		}
+	}
+}
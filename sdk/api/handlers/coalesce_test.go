@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// gatedExecutor holds every call until release is closed and signals entered on the first one.
+type gatedExecutor struct {
+	mu      sync.Mutex
+	calls   int
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGatedExecutor() *gatedExecutor {
+	return &gatedExecutor{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (e *gatedExecutor) enter() {
+	e.mu.Lock()
+	e.calls++
+	if e.calls == 1 {
+		close(e.entered)
+	}
+	e.mu.Unlock()
+}
+
+func (e *gatedExecutor) Identifier() string { return "codex" }
+
+func (e *gatedExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *gatedExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.enter()
+	ch := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(ch)
+		ch <- coreexecutor.StreamChunk{Payload: []byte("first")}
+		<-e.release
+		ch <- coreexecutor.StreamChunk{Payload: []byte("second")}
+	}()
+	return ch, nil
+}
+
+func (e *gatedExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *gatedExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.enter()
+	<-e.release
+	return coreexecutor.Response{Payload: []byte(`{"input_tokens":12}`)}, nil
+}
+
+func (e *gatedExecutor) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func newCoalescingHandler(t *testing.T, authID, model string, executor *gatedExecutor) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: authID, Provider: "codex", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(authID, "codex", []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Coalescing: sdkconfig.CoalescingConfig{CountTokens: true, Streaming: true},
+	}, manager)
+}
+
+func TestExecuteCountWithAuthManager_CoalescesIdenticalRequests(t *testing.T) {
+	executor := newGatedExecutor()
+	handler := newCoalescingHandler(t, "coalesce-count-auth", "coalesce-count-model", executor)
+	rawJSON := []byte(`{"model":"coalesce-count-model","messages":[{"role":"user","content":"hi"}]}`)
+
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	run := func(i int) {
+		defer wg.Done()
+		resp, errMsg := handler.ExecuteCountWithAuthManager(context.Background(), "claude", "coalesce-count-model", rawJSON, "")
+		if errMsg != nil {
+			t.Errorf("request %d: unexpected error %+v", i, errMsg)
+			return
+		}
+		results[i] = string(resp)
+	}
+	wg.Add(1)
+	go run(0)
+	<-executor.entered
+	wg.Add(2)
+	go run(1)
+	go run(2)
+	time.Sleep(50 * time.Millisecond)
+	close(executor.release)
+	wg.Wait()
+
+	if calls := executor.Calls(); calls != 1 {
+		t.Fatalf("expected one upstream call, got %d", calls)
+	}
+	for i, result := range results {
+		if result != `{"input_tokens":12}` {
+			t.Fatalf("request %d: unexpected response %q", i, result)
+		}
+	}
+}
+
+func TestExecuteStreamWithAuthManager_FansOutCoalescedStream(t *testing.T) {
+	executor := newGatedExecutor()
+	handler := newCoalescingHandler(t, "coalesce-stream-auth", "coalesce-stream-model", executor)
+	rawJSON := []byte(`{"model":"coalesce-stream-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	leader, leaderErrs := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "coalesce-stream-model", rawJSON, "")
+	if chunk := <-leader; string(chunk) != "first" {
+		t.Fatalf("unexpected first chunk %q", chunk)
+	}
+	follower, followerErrs := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "coalesce-stream-model", rawJSON, "")
+	close(executor.release)
+
+	collect := func(data <-chan []byte, errs <-chan *interfaces.ErrorMessage) []string {
+		var chunks []string
+		for chunk := range data {
+			chunks = append(chunks, string(chunk))
+		}
+		for msg := range errs {
+			if msg != nil {
+				t.Fatalf("unexpected error: %+v", msg)
+			}
+		}
+		return chunks
+	}
+	if got := collect(leader, leaderErrs); len(got) != 1 || got[0] != "second" {
+		t.Fatalf("unexpected leader chunks %v", got)
+	}
+	if got := collect(follower, followerErrs); len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Fatalf("expected the attached stream to catch up and follow, got %v", got)
+	}
+	if calls := executor.Calls(); calls != 1 {
+		t.Fatalf("expected one upstream stream, got %d", calls)
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/contextwindow"
+	"golang.org/x/net/context"
+)
+
+// fitContextWindow trims or reroutes a request that exceeds the context window of its model
+// according to the configured policy, and lists the changes in the X-CPA-Context-Trimmed
+// response header.
+func fitContextWindow(ctx context.Context, handlerType, modelName string, rawJSON []byte) (string, []byte) {
+	apiKey := ""
+	var ginCtx *gin.Context
+	if ctx != nil {
+		if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil {
+			ginCtx = c
+			apiKey = c.GetString("apiKey")
+		}
+	}
+	result := contextwindow.Default().Fit(apiKey, handlerType, modelName, rawJSON)
+	if len(result.Trimmed) > 0 && ginCtx != nil {
+		ginCtx.Header("X-CPA-Context-Trimmed", strings.Join(result.Trimmed, ", "))
+	}
+	return result.Model, result.Payload
+}
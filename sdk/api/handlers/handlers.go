@@ -6,20 +6,26 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/budget"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
@@ -128,16 +134,35 @@ func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 func requestExecutionMetadata(ctx context.Context) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
+	// X-Session-Affinity optionally names the session so it stays on one credential.
+	// X-CPA-Queue opts the request in to or out of the request queue; the client API key keeps
+	// the queue fair between clients.
 	key := ""
+	session := ""
+	queue := ""
+	client := ""
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
 			key = strings.TrimSpace(ginCtx.GetHeader("Idempotency-Key"))
+			session = strings.TrimSpace(ginCtx.GetHeader("X-Session-Affinity"))
+			queue = strings.TrimSpace(ginCtx.GetHeader("X-CPA-Queue"))
+			client = ginCtx.GetString("apiKey")
 		}
 	}
 	if key == "" {
 		key = uuid.NewString()
 	}
-	return map[string]any{idempotencyKeyMetadataKey: key}
+	meta := map[string]any{idempotencyKeyMetadataKey: key}
+	if session != "" {
+		meta[coreexecutor.SessionAffinityMetadataKey] = session
+	}
+	if queue != "" {
+		meta[coreexecutor.RequestQueueMetadataKey] = queue
+	}
+	if client != "" {
+		meta[coreexecutor.ClientKeyMetadataKey] = client
+	}
+	return meta
 }
 
 func mergeMetadata(base, overlay map[string]any) map[string]any {
@@ -316,12 +341,28 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 }
 
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Requests exceeding the context window of the
+// model are fitted first, repeated requests are answered from the response cache when it is
+// enabled, and identical requests in flight are coalesced when configured.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	modelName, rawJSON = fitContextWindow(ctx, handlerType, modelName, rawJSON)
+	if key := coalesceKey(h.Cfg, ctx, coalesceExecute, handlerType, modelName, rawJSON, alt); key != "" {
+		return inflight.do(ctx, key, func() ([]byte, *interfaces.ErrorMessage) {
+			return h.execute(ctx, handlerType, modelName, rawJSON, alt)
+		})
+	}
+	return h.execute(ctx, handlerType, modelName, rawJSON, alt)
+}
+
+func (h *BaseAPIHandler) execute(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	cache := responseCachePolicy(ctx, handlerType, normalizedModel, rawJSON, alt)
+	if cached, ok := cache.lookup(ctx, normalizedModel); ok {
+		return cached, nil
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -337,7 +378,7 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	resp, err := h.AuthManager.Execute(servedModelContext(h.fallbackContext(ctx)), providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -353,13 +394,24 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
+	cache.store(ctx, normalizedModel, resp.Payload)
 	return cloneBytes(resp.Payload), nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Identical requests in flight are coalesced
+// when configured.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	if key := coalesceKey(h.Cfg, ctx, coalesceCount, handlerType, modelName, rawJSON, alt); key != "" {
+		return inflight.do(ctx, key, func() ([]byte, *interfaces.ErrorMessage) {
+			return h.executeCount(ctx, handlerType, modelName, rawJSON, alt)
+		})
+	}
+	return h.executeCount(ctx, handlerType, modelName, rawJSON, alt)
+}
+
+func (h *BaseAPIHandler) executeCount(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
@@ -398,15 +450,43 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 }
 
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Requests exceeding the context window of the
+// model are fitted first, and identical streams in flight are coalesced when configured.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(modelName)
+	modelName, rawJSON = fitContextWindow(ctx, handlerType, modelName, rawJSON)
+	if key := coalesceKey(h.Cfg, ctx, coalesceStreaming, handlerType, modelName, rawJSON, alt); key != "" {
+		return inflight.stream(ctx, key, func() (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+			return h.executeStream(ctx, handlerType, modelName, rawJSON, alt)
+		})
+	}
+	return h.executeStream(ctx, handlerType, modelName, rawJSON, alt)
+}
+
+func (h *BaseAPIHandler) executeStream(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, modelName)
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	if canReplayCachedStream(handlerType) {
+		// Only non-streaming responses are stored; streaming clients get them replayed.
+		cache := responseCachePolicy(ctx, handlerType, normalizedModel, rawJSON, alt)
+		cache.write = false
+		if cached, ok := cache.lookup(ctx, normalizedModel); ok {
+			if chunks := replayCachedStream(handlerType, cached); len(chunks) > 0 {
+				dataChan := make(chan []byte, len(chunks))
+				errChan := make(chan *interfaces.ErrorMessage)
+				for _, chunk := range chunks {
+					dataChan <- chunk
+				}
+				close(dataChan)
+				close(errChan)
+				return dataChan, errChan
+			}
+		}
+	}
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -422,7 +502,13 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = mergeMetadata(cloneMetadata(metadata), reqMeta)
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	routedCtx := h.fallbackContext(ctx)
+	failover := newStreamFailover(h.Cfg, handlerType, normalizedModel, providers)
+	if failover != nil {
+		// Continuations must not land on a credential that already streamed for this request.
+		routedCtx = coreauth.WithAuthExclusions(routedCtx, &coreauth.AuthExclusions{})
+	}
+	chunks, err := h.AuthManager.ExecuteStream(servedModelContext(routedCtx), providers, req, opts)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -449,6 +535,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		sentPayload := false
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		failovers := 0
+		maxFailovers := StreamingMaxFailovers(h.Cfg)
 
 		bootstrapEligible := func(err error) bool {
 			status := statusFromError(err)
@@ -488,13 +576,29 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					if !sentPayload {
 						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
 							bootstrapRetries++
-							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+							retryChunks, retryErr := h.AuthManager.ExecuteStream(routedCtx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
 								continue outer
 							}
 							streamErr = retryErr
 						}
+					} else if failovers < maxFailovers && failover.resumable() && bootstrapEligible(streamErr) {
+						// Mid-stream failover: continue the text sent so far on another credential.
+						if payload, ok := failover.continuationRequest(rawJSON); ok {
+							failovers++
+							continueReq := req
+							continueReq.Payload = payload
+							continueOpts := opts
+							continueOpts.OriginalRequest = cloneBytes(payload)
+							retryChunks, retryErr := h.AuthManager.ExecuteStream(routedCtx, failover.providers, continueReq, continueOpts)
+							if retryErr == nil {
+								log.Infof("stream for model %s broke after output was sent (%v), continuing on another credential", normalizedModel, streamErr)
+								failover.resume()
+								chunks = retryChunks
+								continue outer
+							}
+						}
 					}
 
 					status := http.StatusInternalServerError
@@ -513,8 +617,14 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					return
 				}
 				if len(chunk.Payload) > 0 {
+					payload := chunk.Payload
+					if failover != nil {
+						if payload = failover.forward(payload); len(payload) == 0 {
+							continue
+						}
+					}
 					sentPayload = true
-					dataChan <- cloneBytes(chunk.Payload)
+					dataChan <- cloneBytes(payload)
 				}
 			}
 		}
@@ -522,6 +632,37 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// fallbackContext lets the core manager resolve fallback models under the caller's access
+// policy, credential prefix and budget.
+func (h *BaseAPIHandler) fallbackContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	return coreauth.WithModelResolver(ctx, func(model string) ([]string, string, map[string]any, error) {
+		providers, normalizedModel, metadata, errMsg := h.getRequestDetails(ctx, model)
+		if errMsg != nil {
+			return nil, "", nil, errMsg.Error
+		}
+		return providers, normalizedModel, metadata, nil
+	})
+}
+
+// servedModelContext reports the model that answered the request in the X-CPA-Model response
+// header, which differs from the requested model when a fallback chain was used. It must only
+// wrap calls made before response headers can be written.
+func servedModelContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return ctx
+	}
+	return coreauth.WithServedModelCallback(ctx, func(model string) {
+		ginCtx.Header("X-CPA-Model", model)
+	})
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
@@ -534,13 +675,33 @@ func statusFromError(err error) int {
 	return 0
 }
 
-func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) getRequestDetails(ctx context.Context, modelName string) (providers []string, normalizedModel string, metadata map[string]any, err *interfaces.ErrorMessage) {
+	policy := accessPolicyFromContext(ctx)
+	if policy != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			if !policy.AllowsEndpoint(ginCtx.Request.URL.Path) {
+				return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("endpoint %s is not allowed for this API key", ginCtx.Request.URL.Path)}
+			}
+		}
+	}
+	if errMsg := checkBudget(ctx); errMsg != nil {
+		return nil, "", nil, errMsg
+	}
+
 	// Resolve "auto" model to an actual available model first
 	resolvedModelName := util.ResolveAutoModel(modelName)
 
 	// Normalize the model name to handle dynamic thinking suffixes before determining the provider.
 	normalizedModel, metadata = normalizeModelMetadata(resolvedModelName)
 
+	if policy != nil {
+		if !policy.AllowsModel(normalizedModel) && !policy.AllowsModel(resolvedModelName) {
+			return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not allowed for this API key", modelName)}
+		}
+		// Bind the client to credentials registered under its prefix.
+		normalizedModel = policy.ApplyPrefix(normalizedModel)
+	}
+
 	// Use the normalizedModel to get the provider name.
 	providers = util.GetProviderName(normalizedModel)
 	if len(providers) == 0 && metadata != nil {
@@ -561,6 +722,13 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 		return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: fmt.Errorf("unknown provider for model %s", modelName)}
 	}
 
+	if policy != nil {
+		providers = policy.FilterProviders(providers)
+		if len(providers) == 0 {
+			return nil, "", nil, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("no allowed provider for model %s", modelName)}
+		}
+	}
+
 	// If it's a dynamic model, the normalizedModel was already set to extractedModelName.
 	// If it's a non-dynamic model, normalizedModel was set by normalizeModelMetadata.
 	// So, normalizedModel is already correctly set at this point.
@@ -568,6 +736,76 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	return providers, normalizedModel, metadata, nil
 }
 
+// checkBudget rejects the request when the calling client key has exhausted a token budget.
+func checkBudget(ctx context.Context) *interfaces.ErrorMessage {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	err := budget.Default().Check(ginCtx.GetString("apiKey"))
+	if err == nil {
+		return nil
+	}
+	addon := http.Header{}
+	var exceeded *budget.Exceeded
+	if errors.As(err, &exceeded) {
+		if wait := time.Until(exceeded.ResetAt); wait > 0 {
+			addon.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: err, Addon: addon}
+}
+
+// accessPolicyFromContext returns the access policy attached by the authentication middleware, if any.
+func accessPolicyFromContext(ctx context.Context) *sdkaccess.Policy {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok {
+		return nil
+	}
+	return accessPolicyFromGin(ginCtx)
+}
+
+// accessPolicyFromGin returns the access policy the authentication middleware stored on c, if any.
+func accessPolicyFromGin(c *gin.Context) *sdkaccess.Policy {
+	if c == nil {
+		return nil
+	}
+	raw, exists := c.Get("accessPolicy")
+	if !exists {
+		return nil
+	}
+	policy, _ := raw.(*sdkaccess.Policy)
+	return policy
+}
+
+// FilterAllowedModels removes the models the access policy of the request does not allow from a
+// model listing. Models are matched by their "id", or by their "name" without the "models/"
+// prefix Gemini listings use.
+func FilterAllowedModels(c *gin.Context, models []map[string]any) []map[string]any {
+	policy := accessPolicyFromGin(c)
+	if policy == nil || len(policy.AllowedModels) == 0 {
+		return models
+	}
+	out := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			name, _ := model["name"].(string)
+			id = strings.TrimPrefix(name, "models/")
+		}
+		if policy.AllowsModel(id) {
+			out = append(out, model)
+		}
+	}
+	return out
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+func TestFilterAllowedModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	models := []map[string]any{
+		{"id": "gpt-5"},
+		{"id": "claude-sonnet-4"},
+		{"name": "models/gemini-2.5-pro"},
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := FilterAllowedModels(c, models); len(got) != len(models) {
+		t.Fatalf("expected every model without a policy, got %v", got)
+	}
+
+	c.Set("accessPolicy", &sdkaccess.Policy{AllowedModels: []string{"gpt-*", "gemini-2.5-*"}})
+	got := FilterAllowedModels(c, models)
+	if len(got) != 2 || got[0]["id"] != "gpt-5" || got[1]["name"] != "models/gemini-2.5-pro" {
+		t.Fatalf("expected the allowed models only, got %v", got)
+	}
+}
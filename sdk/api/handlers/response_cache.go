@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsecache"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// responseCacheSource is the usage source of requests answered from the response cache.
+const responseCacheSource = "response-cache"
+
+// cachePolicy says whether a request may be answered from and stored in the response cache.
+type cachePolicy struct {
+	key   string
+	read  bool
+	write bool
+}
+
+// responseCachePolicy returns the response cache policy of a request. The client API key scopes
+// the entries; "Cache-Control: no-cache" skips the lookup and "no-store" the cache entirely.
+func responseCachePolicy(ctx context.Context, handlerType, model string, rawJSON []byte, alt string) cachePolicy {
+	cache := responsecache.Default()
+	if !cache.Enabled() || alt != "" {
+		return cachePolicy{}
+	}
+	scope := ""
+	read, write := true, true
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			scope = ginCtx.GetString("apiKey")
+			for _, directive := range strings.Split(ginCtx.GetHeader("Cache-Control"), ",") {
+				switch strings.ToLower(strings.TrimSpace(directive)) {
+				case "no-cache":
+					read = false
+				case "no-store":
+					read, write = false, false
+				}
+			}
+		}
+	}
+	if !read && !write {
+		return cachePolicy{}
+	}
+	key, ok := responsecache.Key(scope, handlerType, model, rawJSON)
+	if !ok {
+		return cachePolicy{}
+	}
+	return cachePolicy{key: key, read: read, write: write}
+}
+
+// lookup returns the cached response of the request and records the hit.
+func (p cachePolicy) lookup(ctx context.Context, model string) ([]byte, bool) {
+	if p.key == "" {
+		return nil, false
+	}
+	if !p.read {
+		setCacheHeader(ctx, p.write, false)
+		return nil, false
+	}
+	payload, ok := responsecache.Default().Get(ctx, p.key)
+	setCacheHeader(ctx, true, ok)
+	if !ok {
+		return nil, false
+	}
+	apiKey := ""
+	if ctx != nil {
+		if ginCtx, okGin := ctx.Value("gin").(*gin.Context); okGin && ginCtx != nil {
+			apiKey = ginCtx.GetString("apiKey")
+		}
+	}
+	// A cache hit costs no upstream tokens but still counts as a request of the client.
+	coreusage.PublishRecord(ctx, coreusage.Record{
+		Model:       model,
+		APIKey:      apiKey,
+		Source:      responseCacheSource,
+		RequestedAt: time.Now(),
+		Cached:      true,
+	})
+	return payload, true
+}
+
+// store saves a successful response of the request.
+func (p cachePolicy) store(ctx context.Context, model string, payload []byte) {
+	if p.key == "" || !p.write {
+		return
+	}
+	responsecache.Default().Put(ctx, p.key, model, cloneBytes(payload))
+}
+
+// setCacheHeader reports the cache outcome in the X-CPA-Cache response header.
+func setCacheHeader(ctx context.Context, enabled, hit bool) {
+	if ctx == nil || !enabled {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	if hit {
+		ginCtx.Header("X-CPA-Cache", "HIT")
+	} else {
+		ginCtx.Header("X-CPA-Cache", "MISS")
+	}
+}
+
+// canReplayCachedStream reports whether a cached response in handlerType format can be replayed
+// as a stream.
+func canReplayCachedStream(handlerType string) bool {
+	switch handlerType {
+	case constant.OpenAI, constant.Claude, constant.Gemini:
+		return true
+	default:
+		return false
+	}
+}
+
+// replayCachedStream converts a cached non-streaming response into the chunks a stream in
+// handlerType format would have delivered.
+func replayCachedStream(handlerType string, payload []byte) [][]byte {
+	switch handlerType {
+	case constant.OpenAI:
+		return openAIChunksFromResponse(payload)
+	case constant.Claude:
+		return claudeEventsFromResponse(payload)
+	case constant.Gemini:
+		// A Gemini stream is a sequence of complete responses, so the cached one is a valid stream.
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, payload); err != nil {
+			return nil
+		}
+		return [][]byte{compact.Bytes()}
+	default:
+		return nil
+	}
+}
+
+func openAIChunksFromResponse(payload []byte) [][]byte {
+	root := gjson.ParseBytes(payload)
+	base := `{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`
+	base, _ = sjson.Set(base, "id", root.Get("id").String())
+	base, _ = sjson.Set(base, "created", root.Get("created").Int())
+	base, _ = sjson.Set(base, "model", root.Get("model").String())
+	if fingerprint := root.Get("system_fingerprint"); fingerprint.Exists() {
+		base, _ = sjson.SetRaw(base, "system_fingerprint", fingerprint.Raw)
+	}
+
+	var chunks [][]byte
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		message := choice.Get("message")
+		delta := `{"role":"assistant"}`
+		for _, field := range []string{"content", "reasoning_content", "refusal"} {
+			if value := message.Get(field); value.Exists() && value.Type != gjson.Null {
+				delta, _ = sjson.SetRaw(delta, field, value.Raw)
+			}
+		}
+		message.Get("tool_calls").ForEach(func(index, call gjson.Result) bool {
+			indexed, _ := sjson.Set(call.Raw, "index", index.Int())
+			delta, _ = sjson.SetRaw(delta, "tool_calls.-1", indexed)
+			return true
+		})
+		chunk, _ := sjson.SetRaw(base, "choices.-1", `{"index":0,"delta":{},"finish_reason":null}`)
+		chunk, _ = sjson.Set(chunk, "choices.0.index", choice.Get("index").Int())
+		chunk, _ = sjson.SetRaw(chunk, "choices.0.delta", delta)
+		chunks = append(chunks, []byte(chunk))
+
+		finish, _ := sjson.SetRaw(base, "choices.-1", `{"index":0,"delta":{},"finish_reason":null}`)
+		finish, _ = sjson.Set(finish, "choices.0.index", choice.Get("index").Int())
+		if reason := choice.Get("finish_reason"); reason.Exists() {
+			finish, _ = sjson.SetRaw(finish, "choices.0.finish_reason", reason.Raw)
+		}
+		chunks = append(chunks, []byte(finish))
+		return true
+	})
+	if len(chunks) == 0 {
+		return nil
+	}
+	if usage := root.Get("usage"); usage.Exists() {
+		last, _ := sjson.SetRaw(string(chunks[len(chunks)-1]), "usage", usage.Raw)
+		chunks[len(chunks)-1] = []byte(last)
+	}
+	return chunks
+}
+
+func claudeEventsFromResponse(payload []byte) [][]byte {
+	root := gjson.ParseBytes(payload)
+	if root.Get("type").String() != "message" {
+		return nil
+	}
+	event := func(name, data string) []byte {
+		return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+	}
+
+	message, _ := sjson.SetRaw(root.Raw, "content", `[]`)
+	message, _ = sjson.SetRaw(message, "stop_reason", "null")
+	message, _ = sjson.SetRaw(message, "stop_sequence", "null")
+	message, _ = sjson.Set(message, "usage.output_tokens", 0)
+	start, _ := sjson.SetRaw(`{"type":"message_start"}`, "message", message)
+	events := [][]byte{event("message_start", start)}
+
+	root.Get("content").ForEach(func(index, block gjson.Result) bool {
+		i := index.Int()
+		blockStart := block.Raw
+		var deltas []string
+		switch block.Get("type").String() {
+		case "text":
+			blockStart = `{"type":"text","text":""}`
+			delta, _ := sjson.Set(`{"type":"text_delta","text":""}`, "text", block.Get("text").String())
+			deltas = append(deltas, delta)
+		case "thinking":
+			blockStart = `{"type":"thinking","thinking":""}`
+			delta, _ := sjson.Set(`{"type":"thinking_delta","thinking":""}`, "thinking", block.Get("thinking").String())
+			deltas = append(deltas, delta)
+			if signature := block.Get("signature"); signature.Exists() {
+				delta, _ = sjson.Set(`{"type":"signature_delta","signature":""}`, "signature", signature.String())
+				deltas = append(deltas, delta)
+			}
+		case "tool_use", "server_tool_use":
+			blockStart, _ = sjson.SetRaw(block.Raw, "input", `{}`)
+			input := block.Get("input").Raw
+			if input == "" {
+				input = "{}"
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(input)); err == nil {
+				input = compact.String()
+			}
+			delta, _ := sjson.Set(`{"type":"input_json_delta","partial_json":""}`, "partial_json", input)
+			deltas = append(deltas, delta)
+		}
+		data, _ := sjson.Set(`{"type":"content_block_start","index":0}`, "index", i)
+		data, _ = sjson.SetRaw(data, "content_block", blockStart)
+		events = append(events, event("content_block_start", data))
+		for _, delta := range deltas {
+			data, _ = sjson.Set(`{"type":"content_block_delta","index":0}`, "index", i)
+			data, _ = sjson.SetRaw(data, "delta", delta)
+			events = append(events, event("content_block_delta", data))
+		}
+		data, _ = sjson.Set(`{"type":"content_block_stop","index":0}`, "index", i)
+		events = append(events, event("content_block_stop", data))
+		return true
+	})
+
+	messageDelta := `{"type":"message_delta","delta":{"stop_reason":null,"stop_sequence":null},"usage":{"output_tokens":0}}`
+	if reason := root.Get("stop_reason"); reason.Exists() {
+		messageDelta, _ = sjson.SetRaw(messageDelta, "delta.stop_reason", reason.Raw)
+	}
+	if sequence := root.Get("stop_sequence"); sequence.Exists() {
+		messageDelta, _ = sjson.SetRaw(messageDelta, "delta.stop_sequence", sequence.Raw)
+	}
+	messageDelta, _ = sjson.Set(messageDelta, "usage.output_tokens", root.Get("usage.output_tokens").Int())
+	events = append(events, event("message_delta", messageDelta))
+	events = append(events, event("message_stop", `{"type":"message_stop"}`))
+	return events
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsecache"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type countingExecutor struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *countingExecutor) Identifier() string { return "codex" }
+
+func (e *countingExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"cache-model","choices":[{"index":0,"message":{"role":"assistant","content":"cached answer"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)}, nil
+}
+
+func (e *countingExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *countingExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *countingExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *countingExecutor) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+type cachedUsagePlugin struct{ records chan coreusage.Record }
+
+func (p *cachedUsagePlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	if record.Model != "cache-model" {
+		return
+	}
+	select {
+	case p.records <- record:
+	default:
+	}
+}
+
+func TestExecuteWithAuthManager_ServesRepeatedRequestsFromCache(t *testing.T) {
+	responsecache.Default().SetConfig(sdkconfig.ResponseCacheConfig{Enabled: true})
+	t.Cleanup(func() { responsecache.Default().SetConfig(sdkconfig.ResponseCacheConfig{}) })
+	plugin := &cachedUsagePlugin{records: make(chan coreusage.Record, 4)}
+	coreusage.RegisterPlugin(plugin)
+
+	executor := &countingExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "cache-auth", Provider: "codex", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient("cache-auth", "codex", []*registry.ModelInfo{{ID: "cache-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("cache-auth") })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager)
+	first, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"model":"cache-model","temperature":0,"messages":[{"role":"user","content":"hi"}]}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	second, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"messages":[{"role":"user","content":"hi"}],"temperature":0,"model":"cache-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if string(first) != string(second) {
+		t.Fatalf("expected the cached answer, got %s", second)
+	}
+	if calls := executor.Calls(); calls != 1 {
+		t.Fatalf("expected one upstream call, got %d", calls)
+	}
+
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"model":"cache-model","stream":true,"temperature":0,"messages":[{"role":"user","content":"hi"}]}`), "")
+	var text strings.Builder
+	var finish string
+	for chunk := range dataChan {
+		if gjson.GetBytes(chunk, "object").String() != "chat.completion.chunk" {
+			t.Fatalf("unexpected replayed chunk %s", chunk)
+		}
+		text.WriteString(gjson.GetBytes(chunk, "choices.0.delta.content").String())
+		if reason := gjson.GetBytes(chunk, "choices.0.finish_reason").String(); reason != "" {
+			finish = reason
+		}
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %+v", msg)
+		}
+	}
+	if text.String() != "cached answer" || finish != "stop" {
+		t.Fatalf("unexpected replayed stream: text %q finish %q", text.String(), finish)
+	}
+	if calls := executor.Calls(); calls != 1 {
+		t.Fatalf("expected the stream to be replayed from the cache, got %d upstream calls", calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case record := <-plugin.records:
+			if !record.Cached || record.Detail.TotalTokens != 0 || record.Source != responseCacheSource {
+				t.Fatalf("unexpected cache hit usage record %+v", record)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected a usage record for cache hit %d", i+1)
+		}
+	}
+}
+
+func TestReplayCachedStream_Claude(t *testing.T) {
+	payload := []byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"Hi"},{"type":"tool_use","id":"tu_1","name":"lookup","input":{"q":"x"}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":4,"output_tokens":7}}`)
+	events := replayCachedStream("claude", payload)
+	var names []string
+	for _, event := range events {
+		names = append(names, strings.TrimPrefix(strings.SplitN(string(event), "\n", 2)[0], "event: "))
+	}
+	want := "message_start,content_block_start,content_block_delta,content_block_stop,content_block_start,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(names, ",") != want {
+		t.Fatalf("unexpected events %v", names)
+	}
+	toolDelta := sseData(events[5])
+	if gjson.GetBytes(toolDelta, "delta.partial_json").String() != `{"q":"x"}` {
+		t.Fatalf("unexpected tool input delta %s", toolDelta)
+	}
+	if gjson.GetBytes(sseData(events[7]), "usage.output_tokens").Int() != 7 {
+		t.Fatalf("unexpected message_delta %s", events[7])
+	}
+}
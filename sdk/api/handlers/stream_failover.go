@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultStreamingMaxFailovers = 1
+
+// prefillProviders are the providers whose upstreams continue a trailing assistant message.
+// OpenAI-native upstreams (codex, qwen, iflow, OpenAI compatibility) answer it with a new
+// message instead, so continuations are only sent to these.
+var prefillProviders = map[string]struct{}{
+	"claude":      {},
+	"gemini":      {},
+	"gemini-cli":  {},
+	"vertex":      {},
+	"aistudio":    {},
+	"antigravity": {},
+}
+
+// StreamingMaxFailovers returns how many times a broken stream may be continued on another credential.
+func StreamingMaxFailovers(cfg *config.SDKConfig) int {
+	if cfg == nil || cfg.Streaming.MaxFailovers <= 0 {
+		return defaultStreamingMaxFailovers
+	}
+	return cfg.Streaming.MaxFailovers
+}
+
+// streamFailover follows what a stream already delivered to the client, in the client's format,
+// so that a stream breaking halfway can be continued on another credential: the request is
+// replayed with the partial answer as assistant prefill and the new stream is stitched onto the
+// client stream without repeating its start events. Only text output can be continued.
+//
+// It lives in ExecuteStreamWithAuthManager rather than ForwardStream because continuing needs
+// the original request, the providers of the model and the auth manager, none of which
+// ForwardStream has, and because the chunks there are still in the client format, before
+// ForwardStream frames them and sends them to the client.
+type streamFailover struct {
+	format string
+	// providers are the providers of the model that can serve a continuation.
+	providers []string
+	text      strings.Builder
+	// textOnly is cleared once the stream carries anything but text; finished is set once it
+	// reported a stop reason.
+	textOnly bool
+	finished bool
+
+	// completionID is the OpenAI completion ID the client saw first.
+	completionID string
+
+	// blockOpen, openIndex and nextIndex track the Claude content blocks sent so far.
+	blockOpen bool
+	openIndex int
+	nextIndex int
+
+	// resumed is set while a continuation is stitched: Claude block indices are shifted by
+	// indexOffset, mergeBlock drops the first text block start so its text continues the open
+	// block, and trimLeading drops whitespace the prefill had to leave out.
+	resumed     bool
+	indexOffset int
+	mergeBlock  bool
+	trimLeading bool
+}
+
+// newStreamFailover returns the failover state of a stream of model in handlerType format, or
+// nil when the model did not opt in, the format cannot be continued or none of its providers
+// supports assistant prefill.
+func newStreamFailover(cfg *config.SDKConfig, handlerType, model string, providers []string) *streamFailover {
+	if cfg == nil || len(cfg.Streaming.FailoverModels) == 0 {
+		return nil
+	}
+	switch handlerType {
+	case constant.OpenAI, constant.Claude, constant.Gemini:
+	default:
+		return nil
+	}
+	var capable []string
+	for _, provider := range providers {
+		if _, ok := prefillProviders[strings.ToLower(provider)]; ok {
+			capable = append(capable, provider)
+		}
+	}
+	if len(capable) == 0 {
+		return nil
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	unprefixed := model
+	if idx := strings.Index(model, "/"); idx >= 0 {
+		unprefixed = model[idx+1:]
+	}
+	for _, pattern := range cfg.Streaming.FailoverModels {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if matchFailoverPattern(pattern, model) || matchFailoverPattern(pattern, unprefixed) {
+			return &streamFailover{format: handlerType, providers: capable, textOnly: true}
+		}
+	}
+	return nil
+}
+
+// matchFailoverPattern matches value against a pattern where '*' stands for any run of characters.
+func matchFailoverPattern(pattern, value string) bool {
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+// resumable reports whether the stream delivered text, and nothing else, and has not finished.
+func (f *streamFailover) resumable() bool {
+	return f != nil && f.textOnly && !f.finished && f.text.Len() > 0
+}
+
+// forward stitches chunk onto the client stream when a continuation is running and records
+// what it delivers. It returns nil when the chunk must be dropped.
+func (f *streamFailover) forward(chunk []byte) []byte {
+	if f.resumed {
+		switch f.format {
+		case constant.OpenAI:
+			chunk = f.stitchOpenAI(chunk)
+		case constant.Claude:
+			chunk = f.stitchClaude(chunk)
+		case constant.Gemini:
+			chunk = f.stitchGemini(chunk)
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+	}
+	switch f.format {
+	case constant.OpenAI:
+		f.observeOpenAI(chunk)
+	case constant.Claude:
+		f.observeClaude(chunk)
+	case constant.Gemini:
+		f.observeGemini(chunk)
+	}
+	return chunk
+}
+
+// continuationRequest returns rawJSON with the text delivered so far appended as assistant
+// prefill, and prepares stitching the stream it starts. Trailing whitespace is left out of the
+// prefill, as providers reject it, and dropped from the start of the continuation instead.
+func (f *streamFailover) continuationRequest(rawJSON []byte) ([]byte, bool) {
+	if !f.resumable() {
+		return nil, false
+	}
+	text := f.text.String()
+	prefill := strings.TrimRightFunc(text, unicode.IsSpace)
+	if prefill == "" {
+		return nil, false
+	}
+	var (
+		out []byte
+		err error
+	)
+	switch f.format {
+	case constant.OpenAI:
+		out, err = appendPrefill(rawJSON, "messages", "assistant", "content", func(last gjson.Result) (string, any) {
+			if last.Type == gjson.String {
+				return "content", last.String() + prefill
+			}
+			return "", nil
+		}, map[string]any{"role": "assistant", "content": prefill})
+	case constant.Claude:
+		block := map[string]any{"type": "text", "text": prefill}
+		out, err = appendPrefill(rawJSON, "messages", "assistant", "content", func(last gjson.Result) (string, any) {
+			if last.Type == gjson.String {
+				return "content", last.String() + prefill
+			}
+			if last.IsArray() {
+				return "content.-1", block
+			}
+			return "", nil
+		}, map[string]any{"role": "assistant", "content": []any{block}})
+	case constant.Gemini:
+		part := map[string]any{"text": prefill}
+		out, err = appendPrefill(rawJSON, "contents", "model", "parts", func(last gjson.Result) (string, any) {
+			if last.IsArray() {
+				return "parts.-1", part
+			}
+			return "", nil
+		}, map[string]any{"role": "model", "parts": []any{part}})
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return nil, false
+	}
+	f.trimLeading = len(prefill) < len(text)
+	return out, true
+}
+
+// appendPrefill appends message to the list at listPath, or extends the last entry when the
+// client already ends with a message of role: extend receives its contentField and returns the
+// path below the entry to set and the value, or an empty path to append message anyway.
+func appendPrefill(rawJSON []byte, listPath, role, contentField string, extend func(last gjson.Result) (string, any), message map[string]any) ([]byte, error) {
+	if entries := gjson.GetBytes(rawJSON, listPath).Array(); len(entries) > 0 {
+		last := entries[len(entries)-1]
+		if last.Get("role").String() == role {
+			if path, value := extend(last.Get(contentField)); path != "" {
+				return sjson.SetBytes(rawJSON, listPath+"."+strconv.Itoa(len(entries)-1)+"."+path, value)
+			}
+		}
+	}
+	return sjson.SetBytes(rawJSON, listPath+".-1", message)
+}
+
+// resume starts stitching the continuation stream.
+func (f *streamFailover) resume() {
+	f.resumed = true
+	f.mergeBlock = f.blockOpen
+	f.indexOffset = f.nextIndex
+	if f.blockOpen {
+		f.indexOffset = f.openIndex
+	}
+}
+
+// trimContinuation drops the leading whitespace of the first continuation text.
+func (f *streamFailover) trimContinuation(text string) string {
+	if !f.trimLeading {
+		return text
+	}
+	trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+	if trimmed != "" {
+		f.trimLeading = false
+	}
+	return trimmed
+}
+
+func present(result gjson.Result) bool {
+	return result.Exists() && result.Type != gjson.Null
+}
+
+// observeOpenAI records an OpenAI chat completion chunk.
+func (f *streamFailover) observeOpenAI(chunk []byte) {
+	if !gjson.ValidBytes(chunk) {
+		f.textOnly = false
+		return
+	}
+	root := gjson.ParseBytes(chunk)
+	if f.completionID == "" {
+		f.completionID = root.Get("id").String()
+	}
+	for _, choice := range root.Get("choices").Array() {
+		delta := choice.Get("delta")
+		if choice.Get("index").Int() != 0 || present(delta.Get("tool_calls")) || present(delta.Get("function_call")) || delta.Get("reasoning_content").String() != "" {
+			f.textOnly = false
+		}
+		f.text.WriteString(delta.Get("content").String())
+		if choice.Get("finish_reason").String() != "" {
+			f.finished = true
+		}
+	}
+}
+
+// stitchOpenAI keeps the completion ID the client saw and drops the assistant role announcement
+// of the continuation.
+func (f *streamFailover) stitchOpenAI(chunk []byte) []byte {
+	if !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	if f.completionID != "" && gjson.GetBytes(chunk, "id").Exists() {
+		chunk, _ = sjson.SetBytes(chunk, "id", f.completionID)
+	}
+	if gjson.GetBytes(chunk, "choices.0.delta.role").Exists() {
+		chunk, _ = sjson.DeleteBytes(chunk, "choices.0.delta.role")
+	}
+	if content := gjson.GetBytes(chunk, "choices.0.delta.content"); content.Type == gjson.String && f.trimLeading {
+		chunk, _ = sjson.SetBytes(chunk, "choices.0.delta.content", f.trimContinuation(content.String()))
+	}
+	root := gjson.ParseBytes(chunk)
+	choices := root.Get("choices").Array()
+	if len(choices) != 1 || present(root.Get("usage")) || choices[0].Get("finish_reason").String() != "" {
+		return chunk
+	}
+	for key, value := range choices[0].Get("delta").Map() {
+		if key != "content" || value.String() != "" {
+			return chunk
+		}
+	}
+	return nil
+}
+
+// observeClaude records the events of a Claude messages stream chunk.
+func (f *streamFailover) observeClaude(chunk []byte) {
+	for _, event := range splitSSEEvents(chunk) {
+		data := sseData(event)
+		if data == nil {
+			continue
+		}
+		root := gjson.ParseBytes(data)
+		switch root.Get("type").String() {
+		case "content_block_start":
+			if root.Get("content_block.type").String() != "text" {
+				f.textOnly = false
+			}
+			f.blockOpen, f.openIndex = true, int(root.Get("index").Int())
+		case "content_block_delta":
+			if root.Get("delta.type").String() != "text_delta" {
+				f.textOnly = false
+			}
+			f.text.WriteString(root.Get("delta.text").String())
+		case "content_block_stop":
+			f.blockOpen = false
+			f.nextIndex = int(root.Get("index").Int()) + 1
+		case "message_delta":
+			if root.Get("delta.stop_reason").String() != "" {
+				f.finished = true
+			}
+		case "message_stop":
+			f.finished = true
+		}
+	}
+}
+
+// stitchClaude drops the message start of the continuation, continues the open text block and
+// shifts the block indices after the blocks the client already received.
+func (f *streamFailover) stitchClaude(chunk []byte) []byte {
+	var out [][]byte
+	for _, event := range splitSSEEvents(chunk) {
+		data := sseData(event)
+		if data == nil {
+			out = append(out, event)
+			continue
+		}
+		eventType := gjson.GetBytes(data, "type").String()
+		switch eventType {
+		case "message_start", "ping":
+			continue
+		case "content_block_start":
+			if f.mergeBlock {
+				f.mergeBlock = false
+				if gjson.GetBytes(data, "index").Int() == 0 && gjson.GetBytes(data, "content_block.type").String() == "text" {
+					continue
+				}
+				// The continuation opens with another kind of block: close the open one first.
+				stop := []byte(`{"type":"content_block_stop","index":0}`)
+				stop, _ = sjson.SetBytes(stop, "index", f.indexOffset)
+				out = append(out, []byte("event: content_block_stop\ndata: "+string(stop)))
+				f.indexOffset++
+			}
+		}
+		if index := gjson.GetBytes(data, "index"); index.Exists() && strings.HasPrefix(eventType, "content_block_") {
+			data, _ = sjson.SetBytes(data, "index", int(index.Int())+f.indexOffset)
+		}
+		if eventType == "content_block_delta" && f.trimLeading {
+			if text := gjson.GetBytes(data, "delta.text"); text.Type == gjson.String {
+				data, _ = sjson.SetBytes(data, "delta.text", f.trimContinuation(text.String()))
+			}
+		}
+		out = append(out, replaceSSEData(event, data))
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return append(bytes.Join(out, []byte("\n\n")), '\n', '\n')
+}
+
+// observeGemini records a Gemini generateContent stream chunk.
+func (f *streamFailover) observeGemini(chunk []byte) {
+	if !gjson.ValidBytes(chunk) {
+		f.textOnly = false
+		return
+	}
+	candidates := gjson.GetBytes(chunk, "candidates").Array()
+	if len(candidates) > 1 {
+		f.textOnly = false
+	}
+	for _, candidate := range candidates {
+		for _, part := range candidate.Get("content.parts").Array() {
+			if part.Get("thought").Bool() || !part.Get("text").Exists() {
+				f.textOnly = false
+				continue
+			}
+			f.text.WriteString(part.Get("text").String())
+		}
+		if candidate.Get("finishReason").String() != "" {
+			f.finished = true
+		}
+	}
+}
+
+// stitchGemini drops the whitespace the prefill left out; Gemini streams carry no start events.
+func (f *streamFailover) stitchGemini(chunk []byte) []byte {
+	if !f.trimLeading || !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	if text := gjson.GetBytes(chunk, "candidates.0.content.parts.0.text"); text.Type == gjson.String {
+		chunk, _ = sjson.SetBytes(chunk, "candidates.0.content.parts.0.text", f.trimContinuation(text.String()))
+	}
+	return chunk
+}
+
+// splitSSEEvents splits an SSE chunk into its events, without the blank separator lines.
+func splitSSEEvents(chunk []byte) [][]byte {
+	var events [][]byte
+	for _, event := range bytes.Split(bytes.ReplaceAll(chunk, []byte("\r\n"), []byte("\n")), []byte("\n\n")) {
+		if len(bytes.TrimSpace(event)) > 0 {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// sseData returns the data of an SSE event, or nil when it has none.
+func sseData(event []byte) []byte {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("data:")) {
+			return bytes.TrimSpace(line[len("data:"):])
+		}
+	}
+	return nil
+}
+
+// replaceSSEData returns event with its data line replaced by data.
+func replaceSSEData(event, data []byte) []byte {
+	lines := bytes.Split(event, []byte("\n"))
+	for i, line := range lines {
+		if bytes.HasPrefix(line, []byte("data:")) {
+			lines[i] = append([]byte("data: "), data...)
+			break
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
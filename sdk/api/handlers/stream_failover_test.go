@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// breakOnceStreamExecutor breaks its first stream after some text and answers the
+// continuation from another credential.
+type breakOnceStreamExecutor struct {
+	provider string
+	mu       sync.Mutex
+	auths    []string
+	payloads [][]byte
+}
+
+func (e *breakOnceStreamExecutor) Identifier() string { return e.provider }
+
+func (e *breakOnceStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *breakOnceStreamExecutor) ExecuteStream(_ context.Context, auth *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.auths = append(e.auths, auth.ID)
+	e.payloads = append(e.payloads, req.Payload)
+	call := len(e.auths)
+	e.mu.Unlock()
+
+	ch := make(chan coreexecutor.StreamChunk, 4)
+	if call == 1 {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"first","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello "}}]}`)}
+		ch <- coreexecutor.StreamChunk{Err: &coreauth.Error{Code: "overloaded", Message: "overloaded", HTTPStatus: http.StatusServiceUnavailable}}
+	} else {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"second","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`)}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"second","choices":[{"index":0,"delta":{"content":" world"}}]}`)}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"second","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *breakOnceStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *breakOnceStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+// streamWithFailover streams an OpenAI chat request for model from two credentials of
+// provider, the first of which breaks after some text, and returns the chunks and errors.
+func streamWithFailover(t *testing.T, provider, model string) (*breakOnceStreamExecutor, []string, []*interfaces.ErrorMessage) {
+	t.Helper()
+	executor := &breakOnceStreamExecutor{provider: provider}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	for _, id := range []string{model + "-auth1", model + "-auth2"} {
+		if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: id, Provider: provider, Status: coreauth.StatusActive}); err != nil {
+			t.Fatalf("manager.Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: model}})
+	}
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(model + "-auth1")
+		registry.GetGlobalRegistry().UnregisterClient(model + "-auth2")
+	})
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{FailoverModels: []string{"failover-*"}},
+	}, manager)
+	rawJSON := []byte(`{"model":"` + model + `","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", model, rawJSON, "")
+
+	var chunks []string
+	for chunk := range dataChan {
+		chunks = append(chunks, string(chunk))
+	}
+	var errs []*interfaces.ErrorMessage
+	for msg := range errChan {
+		if msg != nil {
+			errs = append(errs, msg)
+		}
+	}
+	return executor, chunks, errs
+}
+
+func TestExecuteStreamWithAuthManager_FailsOverMidStream(t *testing.T) {
+	executor, chunks, errs := streamWithFailover(t, "claude", "failover-model")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error: %+v", errs[0])
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected the role-only continuation chunk to be dropped, got %v", chunks)
+	}
+	var text strings.Builder
+	for _, chunk := range chunks {
+		if id := gjson.Get(chunk, "id").String(); id != "first" {
+			t.Fatalf("continuation chunks should keep the first completion ID, got %s", chunk)
+		}
+		if gjson.Get(chunk, "choices.0.delta.role").Exists() && text.Len() > 0 {
+			t.Fatalf("continuation repeated the role announcement: %s", chunk)
+		}
+		text.WriteString(gjson.Get(chunk, "choices.0.delta.content").String())
+	}
+	if text.String() != "Hello world" {
+		t.Fatalf("expected stitched text %q, got %q", "Hello world", text.String())
+	}
+
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	if len(executor.auths) != 2 || executor.auths[0] == executor.auths[1] {
+		t.Fatalf("expected the continuation on another credential, got %v", executor.auths)
+	}
+	prefill := gjson.GetBytes(executor.payloads[1], "messages.1")
+	if prefill.Get("role").String() != "assistant" || prefill.Get("content").String() != "Hello" {
+		t.Fatalf("unexpected continuation prefill %s", prefill.Raw)
+	}
+}
+
+func TestStreamFailover_StitchesClaudeEvents(t *testing.T) {
+	f := &streamFailover{format: "claude", textOnly: true}
+	first := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Once upon \"}}\n\n"
+	f.forward([]byte(first))
+	payload, ok := f.continuationRequest([]byte(`{"messages":[{"role":"user","content":"story"}]}`))
+	if !ok {
+		t.Fatal("a text-only stream should be resumable")
+	}
+	if got := gjson.GetBytes(payload, "messages.1.content.0.text").String(); got != "Once upon" {
+		t.Fatalf("unexpected prefill %q", got)
+	}
+	f.resume()
+
+	continuation := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" a time\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"
+	out := string(f.forward([]byte(continuation)))
+	if strings.Contains(out, "message_start") || strings.Contains(out, "content_block_start") {
+		t.Fatalf("continuation start events should be dropped, got %q", out)
+	}
+	if !strings.Contains(out, `"text":"a time"`) {
+		t.Fatalf("leading whitespace left out of the prefill should be dropped, got %q", out)
+	}
+	if f.text.String() != "Once upon a time" {
+		t.Fatalf("unexpected accumulated text %q", f.text.String())
+	}
+}
+
+func TestExecuteStreamWithAuthManager_NoFailoverWithoutPrefillSupport(t *testing.T) {
+	executor, chunks, errs := streamWithFailover(t, "codex", "failover-openai-model")
+	if len(errs) != 1 {
+		t.Fatalf("expected the broken stream to fail, got chunks %v and errors %v", chunks, errs)
+	}
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	if len(executor.auths) != 1 {
+		t.Fatalf("expected no continuation on a provider without prefill support, got %v", executor.auths)
+	}
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -260,6 +261,60 @@ func (s *FileTokenStore) labelFor(metadata map[string]any) string {
 	return ""
 }
 
+// runtimeStateFileName holds cooldown state next to the auth files. It has no .json suffix so
+// it is neither listed as an auth nor picked up by the auth directory watcher.
+const runtimeStateFileName = ".runtime-state"
+
+// LoadRuntimeState reads the cooldown state saved by SaveRuntimeState.
+func (s *FileTokenStore) LoadRuntimeState(context.Context) (map[string]*cliproxyauth.RuntimeState, error) {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return nil, nil
+	}
+	return cliproxyauth.ReadRuntimeStateFile(filepath.Join(dir, runtimeStateFileName))
+}
+
+// SaveRuntimeState persists cooldown state in a dedicated file inside the auth directory.
+func (s *FileTokenStore) SaveRuntimeState(_ context.Context, states map[string]*cliproxyauth.RuntimeState) error {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cliproxyauth.WriteRuntimeStateFile(filepath.Join(dir, runtimeStateFileName), states)
+}
+
+// refreshLeaseReplacer turns an auth ID into a lock file name inside the auth directory.
+var refreshLeaseReplacer = strings.NewReplacer("/", "_", "\\", "_")
+
+// AcquireRefreshLease takes the refresh lease of an auth with a lock file next to the auth files,
+// so replicas sharing the auth directory do not refresh the same token at once. Like the runtime
+// state file, lock files have no .json suffix and are ignored by List and the watcher.
+func (s *FileTokenStore) AcquireRefreshLease(_ context.Context, authID string, ttl time.Duration) (func(), bool, error) {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return func() {}, true, nil
+	}
+	path := filepath.Join(dir, ".refresh-"+refreshLeaseReplacer.Replace(authID)+".lock")
+	return cliproxyauth.AcquireLeaseFile(path, ttl)
+}
+
+// LoadAuth reads the current auth file of id from disk.
+func (s *FileTokenStore) LoadAuth(_ context.Context, id string) (*cliproxyauth.Auth, error) {
+	path, err := s.resolveDeletePath(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("auth filestore: stat auth file: %w", err)
+	}
+	return s.readAuthFile(path, s.baseDirSnapshot())
+}
+
 func (s *FileTokenStore) baseDirSnapshot() string {
 	s.dirLock.RLock()
 	defer s.dirLock.RUnlock()
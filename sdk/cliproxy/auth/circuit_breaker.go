@@ -0,0 +1,336 @@
+package auth
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a credential circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets all traffic through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen blocks all traffic until the open period ends.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe request through; its outcome closes or reopens the breaker.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenDuration     = 30 * time.Second
+	defaultBreakerMaxOpenDuration  = 10 * time.Minute
+	// breakerProbeTimeout frees a half-open breaker whose probe never reported back.
+	breakerProbeTimeout = 2 * time.Minute
+	// breakerHistorySize bounds the transition history kept for the management API.
+	breakerHistorySize = 200
+)
+
+// BreakerTransition records a circuit breaker state change.
+type BreakerTransition struct {
+	Time    time.Time    `json:"time"`
+	AuthID  string       `json:"auth_id"`
+	Model   string       `json:"model,omitempty"`
+	From    BreakerState `json:"from"`
+	To      BreakerState `json:"to"`
+	Reason  string       `json:"reason,omitempty"`
+	OpenFor string       `json:"open_for,omitempty"`
+}
+
+// BreakerStatus is a snapshot of one circuit breaker.
+type BreakerStatus struct {
+	AuthID              string       `json:"auth_id"`
+	Model               string       `json:"model,omitempty"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Trips               int          `json:"trips"`
+	OpenUntil           *time.Time   `json:"open_until,omitempty"`
+	Probing             bool         `json:"probing,omitempty"`
+	LastFailure         string       `json:"last_failure,omitempty"`
+}
+
+type breaker struct {
+	authID       string
+	model        string
+	state        BreakerState
+	failures     int
+	trips        int
+	openUntil    time.Time
+	probeStarted time.Time
+	lastFailure  string
+}
+
+// CircuitBreakers tracks a breaker per auth and per auth+model. Consecutive transient failures
+// (timeouts and 5xx responses) open a breaker; after the open period a single probe is allowed
+// and only its success closes the breaker again. Quota and authorization errors are left to the
+// cooldown logic and do not count as failures. Breakers are off until enabled through Configure.
+type CircuitBreakers struct {
+	mu         sync.Mutex
+	enabled    bool
+	threshold  int
+	openFor    time.Duration
+	maxOpenFor time.Duration
+	breakers   map[string]*breaker
+	history    []BreakerTransition
+	// pending holds transitions made while admitting a probe, reported with the probe's result.
+	pending map[string][]BreakerTransition
+}
+
+// NewCircuitBreakers creates disabled breakers with default settings.
+func NewCircuitBreakers() *CircuitBreakers {
+	return &CircuitBreakers{
+		threshold:  defaultBreakerFailureThreshold,
+		openFor:    defaultBreakerOpenDuration,
+		maxOpenFor: defaultBreakerMaxOpenDuration,
+		breakers:   make(map[string]*breaker),
+		pending:    make(map[string][]BreakerTransition),
+	}
+}
+
+// Configure updates the breaker settings. Non-positive values select the defaults.
+// Disabling clears all breaker state.
+func (c *CircuitBreakers) Configure(enabled bool, threshold int, openFor, maxOpenFor time.Duration) {
+	if c == nil {
+		return
+	}
+	if threshold <= 0 {
+		threshold = defaultBreakerFailureThreshold
+	}
+	if openFor <= 0 {
+		openFor = defaultBreakerOpenDuration
+	}
+	if maxOpenFor <= 0 {
+		maxOpenFor = defaultBreakerMaxOpenDuration
+	}
+	if maxOpenFor < openFor {
+		maxOpenFor = openFor
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	c.threshold = threshold
+	c.openFor = openFor
+	c.maxOpenFor = maxOpenFor
+	if !enabled {
+		c.breakers = make(map[string]*breaker)
+		c.pending = make(map[string][]BreakerTransition)
+	}
+}
+
+func breakerKey(authID, model string) string {
+	if model == "" {
+		return authID
+	}
+	return authID + "|" + model
+}
+
+// Blocked reports whether traffic for authID and model is currently held back by a breaker.
+func (c *CircuitBreakers) Blocked(authID, model string, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return false
+	}
+	return c.blockedLocked(c.breakers[breakerKey(authID, "")], now) ||
+		(model != "" && c.blockedLocked(c.breakers[breakerKey(authID, model)], now))
+}
+
+func (c *CircuitBreakers) blockedLocked(b *breaker, now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	switch b.state {
+	case BreakerOpen:
+		return now.Before(b.openUntil)
+	case BreakerHalfOpen:
+		return !b.probeStarted.IsZero() && now.Sub(b.probeStarted) < breakerProbeTimeout
+	default:
+		return false
+	}
+}
+
+// Acquire admits a request for authID and model. When a breaker is due for probing, the
+// request becomes its probe and the breaker moves to half-open. It returns false when the
+// request must not be sent.
+func (c *CircuitBreakers) Acquire(authID, model string, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return true
+	}
+	candidates := []*breaker{c.breakers[breakerKey(authID, "")]}
+	if model != "" {
+		candidates = append(candidates, c.breakers[breakerKey(authID, model)])
+	}
+	for _, b := range candidates {
+		if c.blockedLocked(b, now) {
+			return false
+		}
+	}
+	for _, b := range candidates {
+		if b == nil {
+			continue
+		}
+		switch b.state {
+		case BreakerOpen:
+			c.pending[authID] = append(c.pending[authID], c.transitionLocked(b, BreakerHalfOpen, "probing", 0, now))
+			b.probeStarted = now
+		case BreakerHalfOpen:
+			b.probeStarted = now
+		}
+	}
+	return true
+}
+
+// Record applies the outcome of a request and returns the transitions it caused, preceded by
+// any transitions made when the request was admitted as a probe.
+func (c *CircuitBreakers) Record(result Result, now time.Time) []BreakerTransition {
+	if c == nil || result.AuthID == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return nil
+	}
+	failure, neutral := classifyBreakerResult(result)
+	keys := []string{breakerKey(result.AuthID, "")}
+	if result.Model != "" {
+		keys = append(keys, breakerKey(result.AuthID, result.Model))
+	}
+	transitions := c.pending[result.AuthID]
+	delete(c.pending, result.AuthID)
+	for i, key := range keys {
+		b := c.breakers[key]
+		if b == nil {
+			if !failure {
+				continue
+			}
+			b = &breaker{authID: result.AuthID, state: BreakerClosed}
+			if i > 0 {
+				b.model = result.Model
+			}
+			c.breakers[key] = b
+		}
+		switch {
+		case neutral:
+			if b.state == BreakerHalfOpen {
+				// The probe was inconclusive; let the next request probe again.
+				b.probeStarted = time.Time{}
+			}
+		case failure:
+			b.failures++
+			if result.Error != nil {
+				b.lastFailure = result.Error.Message
+			}
+			if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= c.threshold) {
+				transitions = append(transitions, c.openLocked(b, now))
+			}
+		default:
+			if b.state != BreakerClosed {
+				transitions = append(transitions, c.transitionLocked(b, BreakerClosed, "probe succeeded", 0, now))
+			}
+			delete(c.breakers, key)
+		}
+	}
+	return transitions
+}
+
+func (c *CircuitBreakers) openLocked(b *breaker, now time.Time) BreakerTransition {
+	openFor := c.openFor
+	for i := 0; i < b.trips && openFor < c.maxOpenFor; i++ {
+		openFor *= 2
+	}
+	if openFor > c.maxOpenFor {
+		openFor = c.maxOpenFor
+	}
+	reason := "consecutive failures"
+	if b.state == BreakerHalfOpen {
+		reason = "probe failed"
+	}
+	b.trips++
+	b.openUntil = now.Add(openFor)
+	b.probeStarted = time.Time{}
+	return c.transitionLocked(b, BreakerOpen, reason, openFor, now)
+}
+
+func (c *CircuitBreakers) transitionLocked(b *breaker, to BreakerState, reason string, openFor time.Duration, now time.Time) BreakerTransition {
+	transition := BreakerTransition{Time: now, AuthID: b.authID, Model: b.model, From: b.state, To: to, Reason: reason}
+	if openFor > 0 {
+		transition.OpenFor = openFor.String()
+	}
+	b.state = to
+	c.history = append(c.history, transition)
+	if len(c.history) > breakerHistorySize {
+		c.history = c.history[len(c.history)-breakerHistorySize:]
+	}
+	return transition
+}
+
+// classifyBreakerResult reports whether result counts as a breaker failure, or is neutral
+// (neither a failure nor a success, such as quota or authorization errors).
+func classifyBreakerResult(result Result) (failure bool, neutral bool) {
+	if result.Success {
+		return false, false
+	}
+	status := statusCodeFromResult(result.Error)
+	switch {
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return true, false
+	case status == 0:
+		if result.Error != nil && strings.Contains(result.Error.Message, "context canceled") {
+			return false, true
+		}
+		return true, false
+	default:
+		return false, true
+	}
+}
+
+// Snapshot returns every breaker that is not closed or has recorded failures, and the
+// transition history, newest first.
+func (c *CircuitBreakers) Snapshot(now time.Time) ([]BreakerStatus, []BreakerTransition) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statuses := make([]BreakerStatus, 0, len(c.breakers))
+	for _, b := range c.breakers {
+		status := BreakerStatus{
+			AuthID:              b.authID,
+			Model:               b.model,
+			State:               b.state,
+			ConsecutiveFailures: b.failures,
+			Trips:               b.trips,
+			Probing:             c.blockedLocked(b, now) && b.state == BreakerHalfOpen,
+			LastFailure:         b.lastFailure,
+		}
+		if b.state == BreakerOpen {
+			until := b.openUntil
+			status.OpenUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].AuthID != statuses[j].AuthID {
+			return statuses[i].AuthID < statuses[j].AuthID
+		}
+		return statuses[i].Model < statuses[j].Model
+	})
+	history := make([]BreakerTransition, 0, len(c.history))
+	for i := len(c.history) - 1; i >= 0; i-- {
+		history = append(history, c.history[i])
+	}
+	return statuses, history
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakers_OpenHalfOpenClose(t *testing.T) {
+	t.Parallel()
+
+	breakers := NewCircuitBreakers()
+	breakers.Configure(true, 2, time.Minute, 4*time.Minute)
+	now := time.Now()
+	failure := Result{AuthID: "a", Model: "m", Error: &Error{Message: "upstream 502", HTTPStatus: http.StatusBadGateway}}
+
+	breakers.Record(failure, now)
+	if breakers.Blocked("a", "m", now) {
+		t.Fatalf("breaker opened before reaching the threshold")
+	}
+	transitions := breakers.Record(failure, now)
+	if len(transitions) != 2 || transitions[0].To != BreakerOpen || transitions[1].Model != "m" {
+		t.Fatalf("expected auth and model breakers to open, got %+v", transitions)
+	}
+	if !breakers.Blocked("a", "m", now) || !breakers.Blocked("a", "other", now) {
+		t.Fatalf("open breaker should block the auth")
+	}
+
+	later := now.Add(time.Minute)
+	if breakers.Blocked("a", "m", later) {
+		t.Fatalf("breaker should allow probing after the open period")
+	}
+	if !breakers.Acquire("a", "m", later) {
+		t.Fatalf("first request after the open period should be admitted as probe")
+	}
+	if breakers.Acquire("a", "m", later) || !breakers.Blocked("a", "m", later) {
+		t.Fatalf("only one probe may be in flight while half-open")
+	}
+
+	transitions = breakers.Record(failure, later)
+	if len(transitions) != 4 || transitions[0].To != BreakerHalfOpen || transitions[2].To != BreakerOpen || transitions[2].OpenFor != "2m0s" {
+		t.Fatalf("failed probe should reopen with a longer period, got %+v", transitions)
+	}
+
+	probeAt := later.Add(2 * time.Minute)
+	if !breakers.Acquire("a", "m", probeAt) {
+		t.Fatalf("probe should be admitted after the doubled open period")
+	}
+	transitions = breakers.Record(Result{AuthID: "a", Model: "m", Success: true}, probeAt)
+	if len(transitions) != 4 || transitions[3].To != BreakerClosed {
+		t.Fatalf("successful probe should close the breakers, got %+v", transitions)
+	}
+	if breakers.Blocked("a", "m", probeAt) {
+		t.Fatalf("closed breaker should not block")
+	}
+	statuses, history := breakers.Snapshot(probeAt)
+	if len(statuses) != 0 || len(history) != 10 || history[0].To != BreakerClosed {
+		t.Fatalf("unexpected snapshot: statuses=%+v history=%d", statuses, len(history))
+	}
+}
+
+func TestCircuitBreakers_IgnoresQuotaErrors(t *testing.T) {
+	t.Parallel()
+
+	breakers := NewCircuitBreakers()
+	breakers.Configure(true, 1, time.Minute, time.Minute)
+	now := time.Now()
+	breakers.Record(Result{AuthID: "a", Error: &Error{Message: "quota", HTTPStatus: http.StatusTooManyRequests}}, now)
+	if breakers.Blocked("a", "", now) {
+		t.Fatalf("quota errors must not open the breaker")
+	}
+}
+
+func TestCircuitBreakers_OffUntilEnabled(t *testing.T) {
+	t.Parallel()
+
+	breakers := NewCircuitBreakers()
+	now := time.Now()
+	failure := Result{AuthID: "a", Error: &Error{Message: "upstream 502", HTTPStatus: http.StatusBadGateway}}
+	for i := 0; i < 2*defaultBreakerFailureThreshold; i++ {
+		breakers.Record(failure, now)
+	}
+	if breakers.Blocked("a", "", now) {
+		t.Fatalf("breakers must stay off unless enabled")
+	}
+}
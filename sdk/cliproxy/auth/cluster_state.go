@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// clusterLoadSyncInterval is how often in-flight counts are exchanged between replicas.
+	clusterLoadSyncInterval = 2 * time.Second
+	// clusterPublishTimeout bounds publishing a single state change.
+	clusterPublishTimeout = 5 * time.Second
+)
+
+// ClusterState shares credential availability between proxy replicas that use the same backend.
+type ClusterState interface {
+	// ReplicaID identifies this replica. State it publishes is not delivered back to it.
+	ReplicaID() string
+	// PublishState records the cooldown state of an auth after a local result and notifies the
+	// other replicas. A state without cooldowns clears the auth.
+	PublishState(ctx context.Context, authID string, state *RuntimeState) error
+	// SyncLoad reports this replica's in-flight request counts per auth and returns the counts
+	// of all other live replicas, summed per auth.
+	SyncLoad(ctx context.Context, inFlight map[string]int) (map[string]int, error)
+	// Subscribe delivers the current shared state and every later change published by other
+	// replicas to fn. Current state whose cooldowns all ended may be skipped. It blocks until
+	// ctx ends.
+	Subscribe(ctx context.Context, fn func(authID string, state *RuntimeState)) error
+	// Close withdraws this replica's load report and releases its resources.
+	Close(ctx context.Context) error
+}
+
+// ClusterStateProvider is implemented by stores that can share state between replicas.
+type ClusterStateProvider interface {
+	ClusterState(replicaID string) (ClusterState, error)
+}
+
+// clusterSync holds the cluster backend and the freshness of the state applied from it.
+type clusterSync struct {
+	mu      sync.Mutex
+	state   ClusterState
+	cancel  context.CancelFunc
+	applied map[string]time.Time
+	// since is when the subscription started. State published before it is replayed history.
+	since time.Time
+}
+
+// StartClusterSync shares MarkResult outcomes, cooldowns and in-flight counts with the other
+// replicas through state. Starting again replaces the previous backend.
+func (m *Manager) StartClusterSync(parent context.Context, state ClusterState) {
+	if m == nil || state == nil {
+		return
+	}
+	m.StopClusterSync()
+	ctx, cancel := context.WithCancel(parent)
+	m.cluster.mu.Lock()
+	m.cluster.state = state
+	m.cluster.cancel = cancel
+	m.cluster.applied = make(map[string]time.Time)
+	m.cluster.since = time.Now()
+	m.cluster.mu.Unlock()
+
+	go func() {
+		if err := state.Subscribe(ctx, m.applyClusterState); err != nil && ctx.Err() == nil {
+			log.Errorf("cluster state subscription stopped: %v", err)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(clusterLoadSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				remote, err := state.SyncLoad(ctx, m.load.localInFlight())
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Debugf("cluster load sync failed: %v", err)
+					}
+					continue
+				}
+				m.load.setRemoteInFlight(remote)
+			}
+		}
+	}()
+	log.Infof("cluster state sharing enabled (replica %s)", state.ReplicaID())
+}
+
+// StopClusterSync stops sharing state and withdraws this replica's load report.
+func (m *Manager) StopClusterSync() {
+	if m == nil {
+		return
+	}
+	m.cluster.mu.Lock()
+	state, cancel := m.cluster.state, m.cluster.cancel
+	m.cluster.state, m.cluster.cancel = nil, nil
+	m.cluster.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if state != nil {
+		ctx, done := context.WithTimeout(context.Background(), clusterPublishTimeout)
+		defer done()
+		if err := state.Close(ctx); err != nil {
+			log.Debugf("cluster state close failed: %v", err)
+		}
+	}
+	m.load.setRemoteInFlight(nil)
+}
+
+// publishClusterState shares the cooldown state of an auth without blocking the request path.
+func (m *Manager) publishClusterState(authID string, state *RuntimeState) {
+	m.cluster.mu.Lock()
+	cluster := m.cluster.state
+	if cluster != nil && m.cluster.applied != nil {
+		m.cluster.applied[authID] = state.UpdatedAt
+	}
+	m.cluster.mu.Unlock()
+	if cluster == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterPublishTimeout)
+		defer cancel()
+		if err := cluster.PublishState(ctx, authID, state); err != nil {
+			log.Debugf("cluster state publish for %s failed: %v", authID, err)
+		}
+	}()
+}
+
+// applyClusterState replaces the bounded cooldowns of an auth with the shared state published
+// by another replica. Open-ended local errors are kept. State published before the subscription
+// started, such as the rows replayed on startup, only adds the cooldowns still running: clearing
+// from it would undo the cooldowns restored by Load.
+func (m *Manager) applyClusterState(authID string, state *RuntimeState) {
+	if state == nil {
+		return
+	}
+	m.cluster.mu.Lock()
+	if last, ok := m.cluster.applied[authID]; ok && state.UpdatedAt.Before(last) {
+		m.cluster.mu.Unlock()
+		return
+	}
+	if m.cluster.applied != nil {
+		m.cluster.applied[authID] = state.UpdatedAt
+	}
+	replayed := state.UpdatedAt.Before(m.cluster.since)
+	m.cluster.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth := m.auths[authID]
+	if auth == nil || auth.Disabled {
+		return
+	}
+	now := time.Now()
+	if replayed {
+		if applyRuntimeState(auth, state, now) {
+			auth.UpdatedAt = now
+			m.runtimeDirty.Store(true)
+			m.queue.kick()
+		}
+		return
+	}
+	for model, local := range auth.ModelStates {
+		if _, shared := state.ModelStates[model]; !shared && modelCooldownActive(local, now) {
+			resetModelState(local, now)
+		}
+	}
+	for model, remote := range state.ModelStates {
+		if !modelCooldownActive(remote, now) {
+			continue
+		}
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState)
+		}
+		auth.ModelStates[model] = remote.Clone()
+	}
+	if state.Unavailable && state.NextRetryAfter.After(now) {
+		auth.Unavailable = true
+		auth.NextRetryAfter = state.NextRetryAfter
+	} else if auth.Unavailable && auth.NextRetryAfter.After(now) {
+		auth.Unavailable = false
+		auth.NextRetryAfter = time.Time{}
+	}
+	if state.Quota.Exceeded && state.Quota.NextRecoverAt.After(now) {
+		usage := auth.Quota.Usage
+		auth.Quota = state.Quota
+		auth.Quota.Usage = usage
+	} else if auth.Quota.Exceeded && auth.Quota.NextRecoverAt.After(now) {
+		auth.Quota = QuotaState{Usage: auth.Quota.Usage}
+	}
+	updateAggregatedAvailability(auth, now)
+	if runtimeStateOf(auth, now) != nil {
+		auth.Status = StatusError
+		auth.StatusMessage = state.StatusMessage
+		auth.LastError = cloneError(state.LastError)
+	} else if auth.Status == StatusError && !hasModelError(auth, now) {
+		auth.Status = StatusActive
+		auth.StatusMessage = ""
+		auth.LastError = nil
+	}
+	auth.UpdatedAt = now
+	delete(m.restored, authID)
+	m.runtimeDirty.Store(true)
+	m.queue.kick()
+}
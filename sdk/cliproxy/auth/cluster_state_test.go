@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCluster connects the replicas of a test through an in-process broker.
+type memoryCluster struct {
+	mu          sync.Mutex
+	subscribers map[string]func(string, *RuntimeState)
+	load        map[string]map[string]int
+}
+
+type memoryReplica struct {
+	cluster *memoryCluster
+	id      string
+}
+
+func (r *memoryReplica) ReplicaID() string { return r.id }
+
+func (r *memoryReplica) PublishState(_ context.Context, authID string, state *RuntimeState) error {
+	r.cluster.mu.Lock()
+	targets := make([]func(string, *RuntimeState), 0, len(r.cluster.subscribers))
+	for id, fn := range r.cluster.subscribers {
+		if id != r.id {
+			targets = append(targets, fn)
+		}
+	}
+	r.cluster.mu.Unlock()
+	for _, fn := range targets {
+		fn(authID, state)
+	}
+	return nil
+}
+
+func (r *memoryReplica) SyncLoad(_ context.Context, inFlight map[string]int) (map[string]int, error) {
+	r.cluster.mu.Lock()
+	defer r.cluster.mu.Unlock()
+	r.cluster.load[r.id] = inFlight
+	totals := make(map[string]int)
+	for id, counts := range r.cluster.load {
+		if id == r.id {
+			continue
+		}
+		for authID, count := range counts {
+			totals[authID] += count
+		}
+	}
+	return totals, nil
+}
+
+func (r *memoryReplica) Subscribe(ctx context.Context, fn func(string, *RuntimeState)) error {
+	r.cluster.mu.Lock()
+	r.cluster.subscribers[r.id] = fn
+	r.cluster.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+func (r *memoryReplica) Close(context.Context) error {
+	r.cluster.mu.Lock()
+	delete(r.cluster.subscribers, r.id)
+	delete(r.cluster.load, r.id)
+	r.cluster.mu.Unlock()
+	return nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManagerClusterSync_SharesCooldowns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := &memoryCluster{subscribers: make(map[string]func(string, *RuntimeState)), load: make(map[string]map[string]int)}
+	replicas := make([]*Manager, 2)
+	for i := range replicas {
+		m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+		if _, err := m.Register(ctx, &Auth{ID: "shared", Provider: "claude"}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		m.StartClusterSync(ctx, &memoryReplica{cluster: cluster, id: []string{"r1", "r2"}[i]})
+		defer m.StopClusterSync()
+		replicas[i] = m
+	}
+	waitFor(t, "subscriptions", func() bool {
+		cluster.mu.Lock()
+		defer cluster.mu.Unlock()
+		return len(cluster.subscribers) == 2
+	})
+
+	retryAfter := 5 * time.Minute
+	replicas[0].MarkResult(ctx, Result{AuthID: "shared", Provider: "claude", Model: "m", Error: &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests}, RetryAfter: &retryAfter})
+	waitFor(t, "cooldown on the other replica", func() bool {
+		auth, _ := replicas[1].GetByID("shared")
+		state := auth.ModelStates["m"]
+		return state != nil && state.Unavailable && state.Quota.Exceeded && auth.Status == StatusError
+	})
+
+	replicas[0].MarkResult(ctx, Result{AuthID: "shared", Provider: "claude", Model: "m", Success: true})
+	waitFor(t, "cooldown cleared on the other replica", func() bool {
+		auth, _ := replicas[1].GetByID("shared")
+		state := auth.ModelStates["m"]
+		return state != nil && !state.Unavailable && auth.Status == StatusActive
+	})
+
+	release := replicas[0].load.Begin("shared")
+	defer release()
+	waitFor(t, "remote in-flight count", func() bool {
+		for _, stat := range replicas[1].LoadStats() {
+			if stat.AuthID == "shared" && stat.RemoteInFlight == 1 && stat.InFlight == 0 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// replayReplica delivers previously published rows before subscribing, like a backend
+// catching up on startup.
+type replayReplica struct {
+	*memoryReplica
+	rows map[string]*RuntimeState
+}
+
+func (r *replayReplica) Subscribe(ctx context.Context, fn func(string, *RuntimeState)) error {
+	for authID, state := range r.rows {
+		fn(authID, state)
+	}
+	return r.memoryReplica.Subscribe(ctx, fn)
+}
+
+func TestManagerClusterSync_ReplayKeepsLocalCooldowns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	local := &ModelState{Status: StatusError, Unavailable: true, NextRetryAfter: now.Add(10 * time.Minute)}
+	if _, err := m.Register(ctx, &Auth{ID: "restored", Provider: "claude", ModelStates: map[string]*ModelState{"m": local}}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "other", Provider: "claude"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	cluster := &memoryCluster{subscribers: make(map[string]func(string, *RuntimeState)), load: make(map[string]map[string]int)}
+	remote := &ModelState{Status: StatusError, Unavailable: true, NextRetryAfter: now.Add(5 * time.Minute)}
+	replica := &replayReplica{
+		memoryReplica: &memoryReplica{cluster: cluster, id: "r1"},
+		rows: map[string]*RuntimeState{
+			// Expired row of the restored auth: replaying it must not clear the local cooldown.
+			"restored": {Unavailable: true, NextRetryAfter: now.Add(-time.Hour), UpdatedAt: now.Add(-2 * time.Hour)},
+			// Old row that is still running on another auth.
+			"other": {ModelStates: map[string]*ModelState{"n": remote}, UpdatedAt: now.Add(-time.Minute)},
+		},
+	}
+	m.StartClusterSync(ctx, replica)
+	defer m.StopClusterSync()
+	waitFor(t, "subscription", func() bool {
+		cluster.mu.Lock()
+		defer cluster.mu.Unlock()
+		return len(cluster.subscribers) == 1
+	})
+
+	auth, _ := m.GetByID("restored")
+	if state := auth.ModelStates["m"]; state == nil || !state.Unavailable || !state.NextRetryAfter.Equal(local.NextRetryAfter) {
+		t.Fatalf("replayed expired state cleared the local cooldown: %+v", state)
+	}
+	other, _ := m.GetByID("other")
+	if state := other.ModelStates["n"]; state == nil || !state.Unavailable {
+		t.Fatalf("replayed running cooldown not applied: %+v", other.ModelStates)
+	}
+}
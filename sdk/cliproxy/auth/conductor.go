@@ -75,6 +75,12 @@ type Result struct {
 	Success bool
 	// RetryAfter carries a provider supplied retry hint (e.g. 429 retryDelay).
 	RetryAfter *time.Duration
+	// Latency is the time to first byte of a successful streaming execution. It is zero for
+	// non-streaming executions, whose duration is not comparable.
+	Latency time.Duration
+	// BreakerTransitions lists circuit breaker state changes caused by this result.
+	// It is filled in by MarkResult before the result reaches Hook.OnResult.
+	BreakerTransitions []BreakerTransition
 	// Error describes the failure when Success is false.
 	Error *Error
 }
@@ -124,9 +130,41 @@ type Manager struct {
 	// modelNameMappings stores global model name alias mappings (alias -> upstream name) keyed by channel.
 	modelNameMappings atomic.Value
 
+	// modelFallbacks stores fallback chains (lowercase model -> ordered fallback models).
+	modelFallbacks atomic.Value
+
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// executorWrapper wraps the executors handed out to serve requests when set.
+	executorWrapper func(ProviderExecutor) ProviderExecutor
+
+	// load tracks in-flight requests and time-to-first-byte per auth for load-aware selection.
+	load *LoadTracker
+
+	// breakers hold back credentials that keep failing with transient errors.
+	breakers *CircuitBreakers
+
+	// probes runs background health probes and keeps their latest results.
+	probes healthProbes
+
+	// restored holds runtime state read by Load, applied once to auths registered later.
+	restored map[string]*RuntimeState
+	// runtimeDirty is set when cooldown state changed since the last SaveRuntimeState.
+	runtimeDirty atomic.Bool
+
+	// cluster shares cooldowns and in-flight counts with other replicas when configured.
+	cluster clusterSync
+
+	// queue parks requests while every credential of their model is cooling down.
+	queue requestQueue
+
+	// shaper paces credentials with token buckets built from declared and learned rate limits.
+	shaper rateShaper
+
+	// hedge duplicates slow streaming requests of opted-in models to a second credential.
+	hedge hedger
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
 }
@@ -139,6 +177,10 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 	if hook == nil {
 		hook = NoopHook{}
 	}
+	load := NewLoadTracker()
+	if aware, ok := selector.(loadTrackerAware); ok {
+		aware.SetLoadTracker(load)
+	}
 	return &Manager{
 		store:           store,
 		executors:       make(map[string]ProviderExecutor),
@@ -146,6 +188,8 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		load:            load,
+		breakers:        NewCircuitBreakers(),
 	}
 }
 
@@ -156,11 +200,40 @@ func (m *Manager) SetSelector(selector Selector) {
 	if selector == nil {
 		selector = &RoundRobinSelector{}
 	}
+	if aware, ok := selector.(loadTrackerAware); ok {
+		aware.SetLoadTracker(m.load)
+	}
 	m.mu.Lock()
 	m.selector = selector
 	m.mu.Unlock()
 }
 
+// SetCircuitBreakerConfig updates the circuit breaker settings. Breakers are off unless enabled;
+// non-positive values select the defaults.
+func (m *Manager) SetCircuitBreakerConfig(enabled bool, failureThreshold int, openFor, maxOpenFor time.Duration) {
+	if m == nil {
+		return
+	}
+	m.breakers.Configure(enabled, failureThreshold, openFor, maxOpenFor)
+}
+
+// CircuitBreakers returns the breakers that are open, half-open or counting failures, and the
+// recent transition history, newest first.
+func (m *Manager) CircuitBreakers() ([]BreakerStatus, []BreakerTransition) {
+	if m == nil {
+		return nil, nil
+	}
+	return m.breakers.Snapshot(time.Now())
+}
+
+// LoadStats returns the in-flight counts and time-to-first-byte averages tracked per auth.
+func (m *Manager) LoadStats() []LoadStat {
+	if m == nil {
+		return nil
+	}
+	return m.load.Snapshot()
+}
+
 // SetStore swaps the underlying persistence store.
 func (m *Manager) SetStore(store Store) {
 	m.mu.Lock()
@@ -200,6 +273,18 @@ func (m *Manager) RegisterExecutor(executor ProviderExecutor) {
 	m.executors[executor.Identifier()] = executor
 }
 
+// SetExecutorWrapper installs wrap around the executors that serve requests, so that callers
+// can observe and adjust every executor call without replacing the executors. The registered
+// executors stay unwrapped; nil removes the wrapper.
+func (m *Manager) SetExecutorWrapper(wrap func(ProviderExecutor) ProviderExecutor) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.executorWrapper = wrap
+	m.mu.Unlock()
+}
+
 // UnregisterExecutor removes the executor associated with the provider key.
 func (m *Manager) UnregisterExecutor(provider string) {
 	provider = strings.ToLower(strings.TrimSpace(provider))
@@ -221,9 +306,11 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	}
 	auth.EnsureIndex()
 	m.mu.Lock()
+	m.restoreRuntimeStateLocked(auth, time.Now())
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
 	_ = m.persist(ctx, auth)
+	m.queue.kick()
 	m.hook.OnAuthRegistered(ctx, auth.Clone())
 	return auth.Clone(), nil
 }
@@ -239,14 +326,18 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		auth.indexAssigned = existing.indexAssigned
 	}
 	auth.EnsureIndex()
+	m.restoreRuntimeStateLocked(auth, time.Now())
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
 	_ = m.persist(ctx, auth)
+	m.queue.kick()
 	m.hook.OnAuthUpdated(ctx, auth.Clone())
 	return auth.Clone(), nil
 }
 
-// Load resets manager state from the backing store.
+// Load resets manager state from the backing store. When the store implements
+// RuntimeStateStore, cooldowns that were still running at the last save are restored, both
+// onto the loaded auths and onto auths registered later (such as API keys from the config).
 func (m *Manager) Load(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -257,20 +348,81 @@ func (m *Manager) Load(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	m.restored = nil
+	if stateStore, ok := m.store.(RuntimeStateStore); ok {
+		states, errState := stateStore.LoadRuntimeState(ctx)
+		if errState != nil {
+			log.Warnf("failed to load runtime auth state: %v", errState)
+		}
+		now := time.Now()
+		for id, state := range states {
+			if state.Expired(now) {
+				continue
+			}
+			if m.restored == nil {
+				m.restored = make(map[string]*RuntimeState)
+			}
+			m.restored[id] = state
+		}
+	}
+	now := time.Now()
 	m.auths = make(map[string]*Auth, len(items))
 	for _, auth := range items {
 		if auth == nil || auth.ID == "" {
 			continue
 		}
 		auth.EnsureIndex()
+		// Keep the restored entry: the synthesized auth registered for the same file replaces this one.
+		applyRuntimeState(auth, m.restored[auth.ID], now)
 		m.auths[auth.ID] = auth.Clone()
 	}
+	if len(m.restored) > 0 {
+		log.Infof("restored runtime cooldown state for %d auth(s)", len(m.restored))
+	}
 	return nil
 }
 
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When every credential of the model is cooling down or out of quota, the configured fallback
+// models are tried in order. If that fails too, the request may wait in the request queue.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	var (
+		resp   cliproxyexecutor.Response
+		served string
+	)
+	run := func() error {
+		var errExec error
+		resp, served, errExec = m.executeWithFallbacks(ctx, providers, req, opts)
+		return errExec
+	}
+	err := m.queueWhileCoolingDown(ctx, providers, req.Model, opts, run(), run)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	notifyServedModel(ctx, served)
+	return resp, nil
+}
+
+func (m *Manager) executeWithFallbacks(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, string, error) {
+	resp, err := m.executeModel(ctx, providers, req, opts)
+	served := req.Model
+	for _, model := range m.fallbackChain(req.Model, opts) {
+		if !isFallbackError(err) {
+			break
+		}
+		fallbackProviders, fallbackReq, ok := resolveFallback(ctx, req, model)
+		if !ok {
+			continue
+		}
+		logEntryWithRequestID(ctx).Infof("model %s unavailable (%v), falling back to %s", req.Model, err, fallbackReq.Model)
+		resp, err = m.executeModel(ctx, fallbackProviders, fallbackReq, opts)
+		served = fallbackReq.Model
+	}
+	return resp, served, err
+}
+
+func (m *Manager) executeModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -349,7 +501,45 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When every credential of the model is cooling down or out of quota, the configured fallback
+// models are tried in order. If that fails too, the request may wait in the request queue.
 func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	var (
+		chunks <-chan cliproxyexecutor.StreamChunk
+		served string
+	)
+	run := func() error {
+		var errStream error
+		chunks, served, errStream = m.executeStreamWithFallbacks(ctx, providers, req, opts)
+		return errStream
+	}
+	err := m.queueWhileCoolingDown(ctx, providers, req.Model, opts, run(), run)
+	if err != nil {
+		return nil, err
+	}
+	notifyServedModel(ctx, served)
+	return chunks, nil
+}
+
+func (m *Manager) executeStreamWithFallbacks(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, string, error) {
+	chunks, err := m.executeStreamModel(ctx, providers, req, opts)
+	served := req.Model
+	for _, model := range m.fallbackChain(req.Model, opts) {
+		if !isFallbackError(err) {
+			break
+		}
+		fallbackProviders, fallbackReq, ok := resolveFallback(ctx, req, model)
+		if !ok {
+			continue
+		}
+		logEntryWithRequestID(ctx).Infof("model %s unavailable (%v), falling back to %s", req.Model, err, fallbackReq.Model)
+		chunks, err = m.executeStreamModel(ctx, fallbackProviders, fallbackReq, opts)
+		served = fallbackReq.Model
+	}
+	return chunks, served, err
+}
+
+func (m *Manager) executeStreamModel(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -365,7 +555,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	var lastErr error
 	for attempt := 0; attempt < attempts; attempt++ {
 		chunks, errStream := m.executeStreamProvidersOnce(ctx, rotated, func(execCtx context.Context, provider string) (<-chan cliproxyexecutor.StreamChunk, error) {
-			return m.executeStreamWithProvider(execCtx, provider, req, opts)
+			return m.executeStreamWithProvider(execCtx, provider, rotated, req, opts)
 		})
 		if errStream == nil {
 			return chunks, nil
@@ -433,7 +623,11 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		execCtx = m.withRateLimitObserver(execCtx, auth.ID, routeModel, execReq.Model)
+		release := m.load.Begin(auth.ID)
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+		release()
+		// Non-streaming durations include the whole generation, so they leave Latency unset.
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			result.Error = &Error{Message: errExec.Error()}
@@ -501,7 +695,11 @@ func (m *Manager) executeWithForcedAuth(ctx context.Context, provider, routeMode
 	execReq := req
 	execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 	execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+	execCtx = m.withRateLimitObserver(execCtx, auth.ID, routeModel, execReq.Model)
+	release := m.load.Begin(auth.ID)
 	resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+	release()
+	// Non-streaming durations include the whole generation, so they leave Latency unset.
 	result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 	if errExec != nil {
 		result.Error = &Error{Message: errExec.Error()}
@@ -532,7 +730,11 @@ func (m *Manager) executeWithSinglePick(ctx context.Context, provider, routeMode
 	execReq := req
 	execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 	execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+	execCtx = m.withRateLimitObserver(execCtx, auth.ID, routeModel, execReq.Model)
+	release := m.load.Begin(auth.ID)
 	resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+	release()
+	// Non-streaming durations include the whole generation, so they leave Latency unset.
 	result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 	if errExec != nil {
 		result.Error = &Error{Message: errExec.Error()}
@@ -570,10 +772,14 @@ func (m *Manager) pickForcedAuth(ctx context.Context, provider, model string, op
 	}
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
+	wrap := m.executorWrapper
 	m.mu.RUnlock()
 	if !okExecutor || executor == nil {
 		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
+	if wrap != nil {
+		executor = wrap(executor)
+	}
 	return auth, executor, nil
 }
 
@@ -627,6 +833,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		execReq := req
 		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
 		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+		execCtx = m.withRateLimitObserver(execCtx, auth.ID, routeModel, execReq.Model)
 		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
@@ -647,83 +854,117 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 	}
 }
 
-func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	if provider == "" {
 		return nil, &Error{Code: "provider_not_found", Message: "provider identifier is empty"}
 	}
 	routeModel := req.Model
-	tried := make(map[string]struct{})
+	if hedgeDelay := m.hedge.delayFor(routeModel, opts); hedgeDelay > 0 {
+		return m.hedgeStream(ctx, provider, providers, routeModel, req, opts, hedgeDelay)
+	}
+	return m.streamFirst(ctx, provider, routeModel, req, opts, newTriedAuths())
+}
+
+// streamFirst opens a stream of req with the first credential of provider that accepts it,
+// moving on to the next untried credential when a start fails.
+func (m *Manager) streamFirst(ctx context.Context, provider, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, tried *triedAuths) (<-chan cliproxyexecutor.StreamChunk, error) {
 	var lastErr error
 	for {
-		auth, executor, errPick := m.pickNext(ctx, provider, routeModel, opts, tried)
+		auth, executor, errPick := m.pickUntried(ctx, provider, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
 				return nil, lastErr
 			}
 			return nil, errPick
 		}
-
-		accountType, accountInfo := auth.AccountInfo()
-		proxyInfo := auth.ProxyInfo()
-		entry := logEntryWithRequestID(ctx)
-		if accountType == "api_key" {
-			if proxyInfo != "" {
-				entry.Debugf("Use API key %s for model %s %s", util.HideAPIKey(accountInfo), req.Model, proxyInfo)
-			} else {
-				entry.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
-			}
-		} else if accountType == "oauth" {
-			if proxyInfo != "" {
-				entry.Debugf("Use OAuth %s for model %s %s", accountInfo, req.Model, proxyInfo)
-			} else {
-				entry.Debugf("Use OAuth %s for model %s", accountInfo, req.Model)
+		chunks, errStream := m.startStream(ctx, provider, routeModel, auth, executor, req, opts)
+		if errStream != nil {
+			if lostHedge(ctx) {
+				return nil, errStream
 			}
+			lastErr = errStream
+			continue
 		}
+		return chunks, nil
+	}
+}
 
-		tried[auth.ID] = struct{}{}
-		execCtx := ctx
-		if rt := m.roundTripperFor(auth); rt != nil {
-			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
-			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+// startStream executes req as a stream with auth. Failed starts and stream outcomes are
+// recorded through MarkResult, except for legs canceled because the other leg of a hedged
+// request won.
+func (m *Manager) startStream(ctx context.Context, provider, routeModel string, auth *Auth, executor ProviderExecutor, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	accountType, accountInfo := auth.AccountInfo()
+	proxyInfo := auth.ProxyInfo()
+	entry := logEntryWithRequestID(ctx)
+	if accountType == "api_key" {
+		if proxyInfo != "" {
+			entry.Debugf("Use API key %s for model %s %s", util.HideAPIKey(accountInfo), req.Model, proxyInfo)
+		} else {
+			entry.Debugf("Use API key %s for model %s", util.HideAPIKey(accountInfo), req.Model)
 		}
-		execReq := req
-		execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
-		execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
-		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
-		if errStream != nil {
-			rerr := &Error{Message: errStream.Error()}
-			var se cliproxyexecutor.StatusError
-			if errors.As(errStream, &se) && se != nil {
-				rerr.HTTPStatus = se.StatusCode()
-			}
+	} else if accountType == "oauth" {
+		if proxyInfo != "" {
+			entry.Debugf("Use OAuth %s for model %s %s", accountInfo, req.Model, proxyInfo)
+		} else {
+			entry.Debugf("Use OAuth %s for model %s", accountInfo, req.Model)
+		}
+	}
+
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execReq := req
+	execReq.Model, execReq.Metadata = rewriteModelForAuth(routeModel, req.Metadata, auth)
+	execReq.Model, execReq.Metadata = m.applyOAuthModelMapping(auth, execReq.Model, execReq.Metadata)
+	execCtx = m.withRateLimitObserver(execCtx, auth.ID, routeModel, execReq.Model)
+	release := m.load.Begin(auth.ID)
+	started := time.Now()
+	chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+	if errStream != nil {
+		release()
+		rerr := &Error{Message: errStream.Error()}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errStream, &se) && se != nil {
+			rerr.HTTPStatus = se.StatusCode()
+		}
+		if !lostHedge(ctx) {
 			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
 			result.RetryAfter = retryAfterFromError(errStream)
 			m.MarkResult(execCtx, result)
-			lastErr = errStream
-			continue
 		}
-		out := make(chan cliproxyexecutor.StreamChunk)
-		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
-			defer close(out)
-			var failed bool
-			for chunk := range streamChunks {
-				if chunk.Err != nil && !failed {
-					failed = true
+		return nil, errStream
+	}
+	authExclusionsFromContext(ctx).Add(auth.ID)
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func(streamCtx context.Context, streamAuth *Auth, streamChunks <-chan cliproxyexecutor.StreamChunk) {
+		defer close(out)
+		defer release()
+		var failed bool
+		var firstByte time.Duration
+		for chunk := range streamChunks {
+			if firstByte == 0 {
+				firstByte = time.Since(started)
+			}
+			if chunk.Err != nil && !failed {
+				failed = true
+				if !lostHedge(streamCtx) {
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
 						rerr.HTTPStatus = se.StatusCode()
 					}
-					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: false, Error: rerr})
+					m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr})
 				}
-				out <- chunk
 			}
-			if !failed {
-				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
-			}
-		}(execCtx, auth.Clone(), provider, chunks)
-		return out, nil
-	}
+			out <- chunk
+		}
+		if !failed && !lostHedge(streamCtx) {
+			m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: provider, Model: routeModel, Success: true, Latency: firstByte})
+		}
+	}(execCtx, auth.Clone(), chunks)
+	return out, nil
 }
 
 func rewriteModelForAuth(model string, metadata map[string]any, auth *Auth) (string, map[string]any) {
@@ -946,7 +1187,13 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	if result.AuthID == "" {
 		return
 	}
+	m.load.Observe(result.AuthID, result.Model, result.Latency, result.Success)
+	result.BreakerTransitions = m.breakers.Record(result, time.Now())
+	for _, transition := range result.BreakerTransitions {
+		logEntryWithRequestID(ctx).Infof("circuit breaker for auth %s model %q: %s -> %s (%s)", transition.AuthID, transition.Model, transition.From, transition.To, transition.Reason)
+	}
 
+	var shared *RuntimeState
 	shouldResumeModel := false
 	shouldSuspendModel := false
 	suspendReason := ""
@@ -956,6 +1203,8 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
+		hadCooldown := runtimeStateOf(auth, now) != nil
+		delete(m.restored, result.AuthID)
 
 		if result.Success {
 			if result.Model != "" {
@@ -1016,11 +1265,16 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 						backoffLevel = nextLevel
 					}
 					state.NextRetryAfter = next
+					usage := state.Quota.Usage
+					if result.RetryAfter != nil {
+						usage = &QuotaUsage{Source: QuotaSourceRetryAfter, Requests: &QuotaWindow{ResetAt: next}, UpdatedAt: now}
+					}
 					state.Quota = QuotaState{
 						Exceeded:      true,
 						Reason:        "quota",
 						NextRecoverAt: next,
 						BackoffLevel:  backoffLevel,
+						Usage:         usage,
 					}
 					suspendReason = "quota"
 					shouldSuspendModel = true
@@ -1040,10 +1294,21 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			}
 		}
 
+		if current := runtimeStateOf(auth, now); hadCooldown || current != nil {
+			m.runtimeDirty.Store(true)
+			if current == nil {
+				current = &RuntimeState{}
+			}
+			current.UpdatedAt = now
+			shared = current
+		}
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
 
+	if shared != nil {
+		m.publishClusterState(result.AuthID, shared)
+	}
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
 	}
@@ -1055,6 +1320,9 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	} else if shouldSuspendModel {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
+	if result.Success {
+		m.queue.kick()
+	}
 
 	m.hook.OnResult(ctx, result)
 }
@@ -1083,7 +1351,7 @@ func resetModelState(state *ModelState, now time.Time) {
 	state.StatusMessage = ""
 	state.NextRetryAfter = time.Time{}
 	state.LastError = nil
-	state.Quota = QuotaState{}
+	state.Quota = QuotaState{Usage: state.Quota.Usage}
 	state.UpdatedAt = now
 }
 
@@ -1340,6 +1608,10 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
 	registryRef := registry.GetGlobalRegistry()
+	now := time.Now()
+	breakerBlocked := false
+	rateShaped := false
+	excluded := authExclusionsFromContext(ctx)
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1347,25 +1619,63 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if _, used := tried[candidate.ID]; used {
 			continue
 		}
+		if excluded.Has(candidate.ID) {
+			continue
+		}
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if m.breakers.Blocked(candidate.ID, modelKey, now) {
+			breakerBlocked = true
+			continue
+		}
+		if m.shaper.blocked(candidate, m.upstreamModelFor(candidate, modelKey), m.load, now) {
+			rateShaped = true
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
-	if len(candidates) == 0 {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
-	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
-	if errPick != nil {
-		m.mu.RUnlock()
-		return nil, nil, errPick
-	}
-	if selected == nil {
-		m.mu.RUnlock()
-		return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+	candidates = preferHeadroom(candidates, modelKey, now)
+	var selected *Auth
+	for selected == nil {
+		if len(candidates) == 0 {
+			m.mu.RUnlock()
+			if rateShaped {
+				return nil, nil, errRateShaped
+			}
+			if breakerBlocked {
+				return nil, nil, &Error{Code: "circuit_open", Message: "all credentials are held back by circuit breakers", Retryable: true, HTTPStatus: http.StatusServiceUnavailable}
+			}
+			return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
+		}
+		picked, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
+		if errPick != nil {
+			m.mu.RUnlock()
+			return nil, nil, errPick
+		}
+		if picked == nil {
+			m.mu.RUnlock()
+			return nil, nil, &Error{Code: "auth_not_found", Message: "selector returned no auth"}
+		}
+		if m.breakers.Acquire(picked.ID, modelKey, now) {
+			selected = picked
+			continue
+		}
+		// A concurrent request claimed the half-open probe of this credential.
+		breakerBlocked = true
+		remaining := candidates[:0:0]
+		for _, candidate := range candidates {
+			if candidate.ID != picked.ID {
+				remaining = append(remaining, candidate)
+			}
+		}
+		candidates = remaining
 	}
+	m.shaper.take(selected, m.upstreamModelFor(selected, modelKey), now)
 	authCopy := selected.Clone()
+	if m.executorWrapper != nil {
+		executor = m.executorWrapper(executor)
+	}
 	m.mu.RUnlock()
 	if !selected.indexAssigned {
 		m.mu.Lock()
@@ -1420,6 +1730,9 @@ func (m *Manager) StartAutoRefresh(parent context.Context, interval time.Duratio
 				return
 			case <-ticker.C:
 				m.checkRefreshes(ctx)
+				if err := m.SaveRuntimeState(ctx); err != nil {
+					log.Warnf("failed to save runtime auth state: %v", err)
+				}
 			}
 		}
 	}()
@@ -1686,7 +1999,27 @@ func (m *Manager) markRefreshPending(id string, now time.Time) bool {
 	return true
 }
 
-func (m *Manager) refreshAuth(ctx context.Context, id string) {
+// RefreshAuth refreshes the credential with the given ID immediately, bypassing the
+// auto-refresh schedule.
+func (m *Manager) RefreshAuth(ctx context.Context, id string) error {
+	m.mu.RLock()
+	auth := m.auths[id]
+	var exec ProviderExecutor
+	if auth != nil {
+		exec = m.executors[auth.Provider]
+	}
+	m.mu.RUnlock()
+	if auth == nil {
+		return &Error{Code: "auth_not_found", Message: "auth not found"}
+	}
+	if exec == nil {
+		return &Error{Code: "executor_not_found", Message: "executor not registered"}
+	}
+	return m.refreshAuth(ctx, id)
+}
+
+// runRefresh refreshes the auth with its provider executor and stores the result.
+func (m *Manager) runRefresh(ctx context.Context, id string) error {
 	m.mu.RLock()
 	auth := m.auths[id]
 	var exec ProviderExecutor
@@ -1695,7 +2028,7 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	}
 	m.mu.RUnlock()
 	if auth == nil || exec == nil {
-		return
+		return nil
 	}
 	cloned := auth.Clone()
 	updated, err := exec.Refresh(ctx, cloned)
@@ -1709,7 +2042,7 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 			m.auths[id] = current
 		}
 		m.mu.Unlock()
-		return
+		return err
 	}
 	if updated == nil {
 		updated = cloned
@@ -1723,7 +2056,8 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	updated.NextRefreshAfter = time.Time{}
 	updated.LastError = nil
 	updated.UpdatedAt = now
-	_, _ = m.Update(ctx, updated)
+	_, err = m.Update(ctx, updated)
+	return err
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type quotaExecutor struct {
+	recordingExecutor
+}
+
+func (e *quotaExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{Code: "quota", Message: "quota exhausted", HTTPStatus: http.StatusTooManyRequests}
+}
+
+func TestManagerExecute_WalksFallbackChain(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("fallback-test-claude", "claude", []*registry.ModelInfo{{ID: "fallback-test-opus"}})
+	reg.RegisterClient("fallback-test-gemini", "gemini", []*registry.ModelInfo{{ID: "fallback-test-pro"}})
+	t.Cleanup(func() {
+		reg.UnregisterClient("fallback-test-claude")
+		reg.UnregisterClient("fallback-test-gemini")
+	})
+
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(&quotaExecutor{recordingExecutor{provider: "claude"}})
+	gemini := &recordingExecutor{provider: "gemini"}
+	m.RegisterExecutor(gemini)
+	if _, err := m.Register(context.Background(), &Auth{ID: "fallback-test-claude", Provider: "claude"}); err != nil {
+		t.Fatalf("register claude auth: %v", err)
+	}
+	if _, err := m.Register(context.Background(), &Auth{ID: "fallback-test-gemini", Provider: "gemini"}); err != nil {
+		t.Fatalf("register gemini auth: %v", err)
+	}
+	m.SetModelFallbacks([]internalconfig.ModelFallback{{From: "fallback-test-opus", To: []string{"unknown-model", "fallback-test-pro"}}})
+
+	served := ""
+	ctx := WithServedModelCallback(context.Background(), func(model string) { served = model })
+	if _, err := m.Execute(ctx, []string{"claude"}, cliproxyexecutor.Request{Model: "fallback-test-opus"}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got := gemini.LastAuthID(); got != "fallback-test-gemini" {
+		t.Fatalf("expected fallback auth %q, got %q", "fallback-test-gemini", got)
+	}
+	if served != "fallback-test-pro" {
+		t.Fatalf("served model = %q, want %q", served, "fallback-test-pro")
+	}
+
+	m.SetModelFallbacks(nil)
+	_, err := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "fallback-test-opus"}, cliproxyexecutor.Options{})
+	if statusCodeFromError(err) != http.StatusTooManyRequests {
+		t.Fatalf("expected quota error without fallbacks, got %v", err)
+	}
+}
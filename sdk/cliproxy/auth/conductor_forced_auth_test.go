@@ -114,3 +114,22 @@ func TestManagerExecute_NoRetry_TriesSingleAuth(t *testing.T) {
 		t.Fatalf("expected 1 call, got %d", got)
 	}
 }
+
+func TestManagerExecute_DoesNotRecordTTFB(t *testing.T) {
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(&recordingExecutor{provider: "gemini"})
+	if _, err := m.Register(context.Background(), &Auth{ID: "a", Provider: "gemini"}); err != nil {
+		t.Fatalf("register auth a: %v", err)
+	}
+
+	if _, err := m.Execute(context.Background(), []string{"gemini"}, cliproxyexecutor.Request{Model: ""}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	for _, stat := range m.load.Snapshot() {
+		for _, model := range stat.Models {
+			if model.Samples != 0 {
+				t.Fatalf("non-streaming execution recorded a ttfb sample: %+v", model)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"sync"
+)
+
+// AuthExclusions collects the credentials that streamed for a request, so that a follow-up
+// request carrying the same exclusions is served by a different credential.
+type AuthExclusions struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+type authExclusionsContextKey struct{}
+
+// WithAuthExclusions returns a context whose streams record their credential in exclusions and
+// whose executions skip the credentials already recorded.
+func WithAuthExclusions(ctx context.Context, exclusions *AuthExclusions) context.Context {
+	if exclusions == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, authExclusionsContextKey{}, exclusions)
+}
+
+func authExclusionsFromContext(ctx context.Context) *AuthExclusions {
+	if ctx == nil {
+		return nil
+	}
+	exclusions, _ := ctx.Value(authExclusionsContextKey{}).(*AuthExclusions)
+	return exclusions
+}
+
+// Add records authID as excluded.
+func (e *AuthExclusions) Add(authID string) {
+	if e == nil || authID == "" {
+		return
+	}
+	e.mu.Lock()
+	if e.ids == nil {
+		e.ids = make(map[string]struct{})
+	}
+	e.ids[authID] = struct{}{}
+	e.mu.Unlock()
+}
+
+// Has reports whether authID is excluded.
+func (e *AuthExclusions) Has(authID string) bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.ids[authID]
+	return ok
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// ModelResolver resolves a fallback model to the providers serving it, the normalized model
+// name and its execution metadata, applying any restrictions of the calling client.
+type ModelResolver func(model string) (providers []string, normalizedModel string, metadata map[string]any, err error)
+
+type modelResolverContextKey struct{}
+
+type servedModelContextKey struct{}
+
+// WithModelResolver returns a context whose fallback models are resolved through resolver
+// instead of the global model registry.
+func WithModelResolver(ctx context.Context, resolver ModelResolver) context.Context {
+	if resolver == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, modelResolverContextKey{}, resolver)
+}
+
+// WithServedModelCallback returns a context that reports the model which answered a request
+// to fn, once Execute or ExecuteStream has succeeded.
+func WithServedModelCallback(ctx context.Context, fn func(model string)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, servedModelContextKey{}, fn)
+}
+
+func notifyServedModel(ctx context.Context, model string) {
+	if ctx == nil {
+		return
+	}
+	if fn, ok := ctx.Value(servedModelContextKey{}).(func(string)); ok && fn != nil {
+		fn(model)
+	}
+}
+
+// SetModelFallbacks updates the fallback chains walked when a model is unavailable.
+func (m *Manager) SetModelFallbacks(fallbacks []internalconfig.ModelFallback) {
+	if m == nil {
+		return
+	}
+	table := make(map[string][]string, len(fallbacks))
+	for _, entry := range fallbacks {
+		from := strings.ToLower(strings.TrimSpace(entry.From))
+		if from == "" || len(entry.To) == 0 {
+			continue
+		}
+		table[from] = append([]string(nil), entry.To...)
+	}
+	m.modelFallbacks.Store(table)
+}
+
+// fallbackChain returns the fallback models configured for model. A prefixed model such as
+// "team-a/claude-opus-4-5" also matches the chain of the unprefixed name.
+func (m *Manager) fallbackChain(model string, opts cliproxyexecutor.Options) []string {
+	if m == nil || forceAuthIDFromOptions(opts.Metadata) != "" {
+		return nil
+	}
+	table, _ := m.modelFallbacks.Load().(map[string][]string)
+	if len(table) == 0 {
+		return nil
+	}
+	key := strings.ToLower(strings.TrimSpace(model))
+	if chain, ok := table[key]; ok {
+		return chain
+	}
+	if idx := strings.Index(key, "/"); idx >= 0 {
+		return table[key[idx+1:]]
+	}
+	return nil
+}
+
+// isFallbackError reports whether err means the model cannot be served right now because all
+// of its credentials are cooling down or out of quota.
+func isFallbackError(err error) bool {
+	return statusCodeFromError(err) == http.StatusTooManyRequests
+}
+
+// resolveFallback prepares the request for a fallback model. Executors translate the original
+// payload from the inbound format into the fallback provider's format on their own.
+func resolveFallback(ctx context.Context, req cliproxyexecutor.Request, model string) ([]string, cliproxyexecutor.Request, bool) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil, req, false
+	}
+	next := req
+	if resolver, ok := ctx.Value(modelResolverContextKey{}).(ModelResolver); ok && resolver != nil {
+		providers, normalized, metadata, err := resolver(model)
+		if err != nil || len(providers) == 0 {
+			logEntryWithRequestID(ctx).Debugf("skipping fallback model %s: %v", model, err)
+			return nil, req, false
+		}
+		next.Model = normalized
+		next.Metadata = metadata
+		return providers, next, true
+	}
+	providers := util.GetProviderName(model)
+	if len(providers) == 0 {
+		return nil, req, false
+	}
+	next.Model = model
+	next.Metadata = stripOriginalModelMetadata(req.Metadata)
+	return providers, next, true
+}
+
+// stripOriginalModelMetadata drops hints that name the originally requested model.
+func stripOriginalModelMetadata(metadata map[string]any) map[string]any {
+	if len(metadata) == 0 {
+		return metadata
+	}
+	out := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		switch k {
+		case util.ThinkingOriginalModelMetadataKey, util.GeminiOriginalModelMetadataKey, util.ModelMappingOriginalModelMetadataKey:
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// HealthProbeMethodCountTokens probes a credential with a token count request.
+	HealthProbeMethodCountTokens = "count-tokens"
+	// HealthProbeMethodGenerate probes a credential with a one-token completion.
+	HealthProbeMethodGenerate = "generate"
+
+	defaultHealthProbeInterval = 10 * time.Minute
+	minHealthProbeInterval     = time.Minute
+	defaultHealthProbeTimeout  = 30 * time.Second
+	// healthProbeStartDelay gives executors and auths time to register before the first round.
+	healthProbeStartDelay = 30 * time.Second
+	// healthProbeConcurrency bounds the number of probes in flight at once.
+	healthProbeConcurrency = 4
+	// healthProbeStatusPrefix marks status messages written by the prober so a later successful
+	// probe only clears errors it reported itself.
+	healthProbeStatusPrefix = "health probe: "
+)
+
+// countTokensProviders are providers whose CountTokens reaches the upstream API. The others
+// count locally, so only a generation proves that their credentials work.
+var countTokensProviders = map[string]struct{}{
+	"gemini":      {},
+	"gemini-cli":  {},
+	"vertex":      {},
+	"aistudio":    {},
+	"antigravity": {},
+	"claude":      {},
+}
+
+// HealthProbeStatus is the outcome of the latest health probe of one auth.
+type HealthProbeStatus struct {
+	AuthID     string    `json:"auth_id"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model,omitempty"`
+	Method     string    `json:"method"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Healthy    bool      `json:"healthy"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	LatencyMs  int64     `json:"latency_ms"`
+	Disabled   bool      `json:"disabled,omitempty"`
+}
+
+type healthProbeTarget struct {
+	model  string
+	method string
+}
+
+// healthProbes holds the prober configuration and the latest result per auth.
+type healthProbes struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	cfg    internalconfig.HealthProbeConfig
+	last   map[string]HealthProbeStatus
+}
+
+// StartHealthProbes launches a background loop that probes every enabled credential through
+// its executor on the configured schedule. Starting again replaces the previous loop; a
+// disabled configuration only stops it.
+func (m *Manager) StartHealthProbes(parent context.Context, cfg internalconfig.HealthProbeConfig) {
+	if m == nil {
+		return
+	}
+	m.StopHealthProbes()
+	m.probes.mu.Lock()
+	m.probes.cfg = cfg
+	if !cfg.Enabled {
+		m.probes.mu.Unlock()
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultHealthProbeInterval
+	} else if interval < minHealthProbeInterval {
+		interval = minHealthProbeInterval
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m.probes.cancel = cancel
+	m.probes.mu.Unlock()
+	go func() {
+		timer := time.NewTimer(healthProbeStartDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				m.runHealthProbes(ctx)
+				timer.Reset(interval)
+			}
+		}
+	}()
+}
+
+// StopHealthProbes cancels the background probe loop, if running.
+func (m *Manager) StopHealthProbes() {
+	if m == nil {
+		return
+	}
+	m.probes.mu.Lock()
+	defer m.probes.mu.Unlock()
+	if m.probes.cancel != nil {
+		m.probes.cancel()
+		m.probes.cancel = nil
+	}
+}
+
+// HealthProbes returns the latest probe result of every probed auth, sorted by auth ID.
+func (m *Manager) HealthProbes() []HealthProbeStatus {
+	if m == nil {
+		return nil
+	}
+	m.probes.mu.Lock()
+	defer m.probes.mu.Unlock()
+	out := make([]HealthProbeStatus, 0, len(m.probes.last))
+	for _, status := range m.probes.last {
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+func (m *Manager) runHealthProbes(ctx context.Context) {
+	sem := make(chan struct{}, healthProbeConcurrency)
+	var wg sync.WaitGroup
+	for _, a := range m.snapshotAuths() {
+		if a.Disabled || m.executorFor(a.Provider) == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := m.ProbeAuth(ctx, id); err != nil {
+				log.Debugf("health probe skipped for %s: %v", id, err)
+			}
+		}(a.ID)
+	}
+	wg.Wait()
+}
+
+// ProbeAuth probes the credential with the given ID immediately. OAuth credentials due for a
+// refresh are refreshed first. A successful probe clears errors reported by earlier probes,
+// an authentication failure (401/403 or a rejected refresh token) disables the credential
+// unless configured otherwise, and any other failure marks it as erroring.
+func (m *Manager) ProbeAuth(ctx context.Context, id string) (HealthProbeStatus, error) {
+	auth, ok := m.GetByID(id)
+	if !ok {
+		return HealthProbeStatus{}, &Error{Code: "auth_not_found", Message: "auth not found"}
+	}
+	exec := m.executorFor(auth.Provider)
+	if exec == nil {
+		return HealthProbeStatus{}, &Error{Code: "executor_not_found", Message: "executor not registered"}
+	}
+	m.probes.mu.Lock()
+	cfg := m.probes.cfg
+	m.probes.mu.Unlock()
+	target := healthProbeTargetFor(cfg, auth)
+	if target.model == "" {
+		return HealthProbeStatus{}, &Error{Code: "probe_model_not_found", Message: "no probe model configured or registered for " + auth.Provider}
+	}
+
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthProbeTimeout
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	started := time.Now()
+	err := m.probeOnce(probeCtx, auth, exec, target)
+	status := HealthProbeStatus{
+		AuthID:    auth.ID,
+		Provider:  auth.Provider,
+		Model:     target.model,
+		Method:    target.method,
+		CheckedAt: time.Now(),
+		Healthy:   err == nil,
+		LatencyMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down or reconfiguring; the outcome says nothing about the credential.
+			return HealthProbeStatus{}, ctx.Err()
+		}
+		status.StatusCode = statusCodeFromError(err)
+		status.Error = err.Error()
+	}
+	status.Disabled = m.applyHealthProbeResult(ctx, auth.ID, status, cfg.KeepOnAuthFailure)
+
+	m.probes.mu.Lock()
+	if m.probes.last == nil {
+		m.probes.last = make(map[string]HealthProbeStatus)
+	}
+	m.probes.last[auth.ID] = status
+	m.probes.mu.Unlock()
+	return status, nil
+}
+
+func (m *Manager) probeOnce(ctx context.Context, auth *Auth, exec ProviderExecutor, target healthProbeTarget) error {
+	now := time.Now()
+	if typ, _ := auth.AccountInfo(); typ != "api_key" && m.shouldRefresh(auth, now) && m.markRefreshPending(auth.ID, now) {
+		// A refresh running on another replica is picked up later; probe with the current token.
+		if err := m.refreshAuth(ctx, auth.ID); err != nil && !errors.Is(err, errRefreshInProgress) {
+			return err
+		}
+		if refreshed, ok := m.GetByID(auth.ID); ok {
+			auth = refreshed
+		}
+	}
+
+	payload, _ := sjson.SetBytes([]byte(`{"messages":[{"role":"user","content":"ping"}],"max_tokens":1}`), "model", target.model)
+	req := cliproxyexecutor.Request{Payload: payload}
+	req.Model, req.Metadata = rewriteModelForAuth(target.model, nil, auth)
+	req.Model, req.Metadata = m.applyOAuthModelMapping(auth, req.Model, req.Metadata)
+	opts := cliproxyexecutor.Options{
+		SourceFormat:    sdktranslator.FromString("openai"),
+		OriginalRequest: payload,
+	}
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	var err error
+	if target.method == HealthProbeMethodGenerate {
+		_, err = exec.Execute(execCtx, auth, req, opts)
+	} else {
+		_, err = exec.CountTokens(execCtx, auth, req, opts)
+	}
+	return err
+}
+
+// applyHealthProbeResult records the probe outcome on the auth and reports whether the auth
+// was disabled.
+func (m *Manager) applyHealthProbeResult(ctx context.Context, id string, status HealthProbeStatus, keepOnAuthFailure bool) bool {
+	m.mu.Lock()
+	auth := m.auths[id]
+	if auth == nil || auth.Disabled {
+		m.mu.Unlock()
+		return false
+	}
+	now := time.Now()
+	authFailure := isHealthProbeAuthFailure(status)
+	disabled := false
+	switch {
+	case status.Healthy:
+		if auth.Status != StatusError || !strings.HasPrefix(auth.StatusMessage, healthProbeStatusPrefix) {
+			m.mu.Unlock()
+			return false
+		}
+		auth.Status = StatusActive
+		auth.StatusMessage = ""
+		auth.LastError = nil
+	case authFailure && !keepOnAuthFailure:
+		auth.Disabled = true
+		auth.Status = StatusDisabled
+		auth.StatusMessage = healthProbeStatusPrefix + "authentication failed: " + status.Error
+		auth.LastError = &Error{Code: "health_probe_failed", Message: status.Error, HTTPStatus: status.StatusCode}
+		disabled = true
+	default:
+		auth.Status = StatusError
+		auth.StatusMessage = healthProbeStatusPrefix + status.Error
+		auth.LastError = &Error{Code: "health_probe_failed", Message: status.Error, HTTPStatus: status.StatusCode}
+	}
+	auth.UpdatedAt = now
+	_ = m.persist(ctx, auth)
+	snapshot := auth.Clone()
+	m.mu.Unlock()
+
+	if disabled {
+		log.Warnf("health probe disabled auth %s (%s): %s", id, snapshot.Provider, status.Error)
+	} else if !status.Healthy {
+		log.Infof("health probe failed for auth %s (%s): %s", id, snapshot.Provider, status.Error)
+	}
+	m.hook.OnAuthUpdated(ctx, snapshot)
+	return disabled
+}
+
+// isHealthProbeAuthFailure reports whether a failed probe means the credential itself was
+// rejected rather than the upstream being unavailable.
+func isHealthProbeAuthFailure(status HealthProbeStatus) bool {
+	if status.Healthy {
+		return false
+	}
+	switch status.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return strings.Contains(status.Error, "invalid_grant")
+}
+
+// healthProbeTargetFor picks the model and method used to probe auth: the configured override
+// for its provider, otherwise the first model registered for the auth and the provider default.
+func healthProbeTargetFor(cfg internalconfig.HealthProbeConfig, auth *Auth) healthProbeTarget {
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	target := healthProbeTarget{method: HealthProbeMethodGenerate}
+	if _, ok := countTokensProviders[provider]; ok {
+		target.method = HealthProbeMethodCountTokens
+	}
+	for _, entry := range cfg.Providers {
+		if strings.EqualFold(entry.Provider, provider) {
+			target.model = strings.TrimSpace(entry.Model)
+			if entry.Method != "" {
+				target.method = entry.Method
+			}
+			break
+		}
+	}
+	if target.model == "" {
+		if models := registry.GetGlobalRegistry().GetModelsForClient(auth.ID); len(models) > 0 && models[0] != nil {
+			target.model = models[0].ID
+		}
+	}
+	return target
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type probeStatusError struct {
+	code int
+}
+
+func (e probeStatusError) Error() string   { return http.StatusText(e.code) }
+func (e probeStatusError) StatusCode() int { return e.code }
+
+type probeExecutor struct {
+	provider string
+	mu       sync.Mutex
+	err      error
+	methods  []string
+	models   []string
+}
+
+func (e *probeExecutor) Identifier() string { return e.provider }
+
+func (e *probeExecutor) record(method string, req cliproxyexecutor.Request) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.methods = append(e.methods, method)
+	e.models = append(e.models, req.Model)
+	return e.err
+}
+
+func (e *probeExecutor) setErr(err error) {
+	e.mu.Lock()
+	e.err = err
+	e.mu.Unlock()
+}
+
+func (e *probeExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, e.record(HealthProbeMethodGenerate, req)
+}
+
+func (e *probeExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (e *probeExecutor) Refresh(context.Context, *Auth) (*Auth, error) { return nil, nil }
+
+func (e *probeExecutor) CountTokens(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, e.record(HealthProbeMethodCountTokens, req)
+}
+
+func TestManagerProbeAuth_UpdatesStatus(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	claude := &probeExecutor{provider: "claude"}
+	codex := &probeExecutor{provider: "codex"}
+	m.RegisterExecutor(claude)
+	m.RegisterExecutor(codex)
+	m.probes.cfg = internalconfig.HealthProbeConfig{
+		Enabled: true,
+		Providers: []internalconfig.HealthProbeProvider{
+			{Provider: "claude", Model: "claude-haiku"},
+			{Provider: "codex", Model: "gpt-mini"},
+		},
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "c", Provider: "claude", Status: StatusActive}); err != nil {
+		t.Fatalf("register claude auth: %v", err)
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "x", Provider: "codex", Status: StatusActive}); err != nil {
+		t.Fatalf("register codex auth: %v", err)
+	}
+
+	claude.setErr(probeStatusError{code: http.StatusServiceUnavailable})
+	status, err := m.ProbeAuth(ctx, "c")
+	if err != nil {
+		t.Fatalf("probe claude: %v", err)
+	}
+	if status.Healthy || status.Method != HealthProbeMethodCountTokens || status.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	auth, _ := m.GetByID("c")
+	if auth.Disabled || auth.Status != StatusError || !strings.HasPrefix(auth.StatusMessage, healthProbeStatusPrefix) {
+		t.Fatalf("transient failure should mark the auth as erroring, got status=%s message=%q disabled=%t", auth.Status, auth.StatusMessage, auth.Disabled)
+	}
+
+	claude.setErr(nil)
+	if status, err = m.ProbeAuth(ctx, "c"); err != nil || !status.Healthy {
+		t.Fatalf("expected healthy probe, got %+v err=%v", status, err)
+	}
+	if auth, _ = m.GetByID("c"); auth.Status != StatusActive || auth.StatusMessage != "" {
+		t.Fatalf("healthy probe should clear the probe error, got status=%s message=%q", auth.Status, auth.StatusMessage)
+	}
+
+	codex.setErr(probeStatusError{code: http.StatusUnauthorized})
+	if status, err = m.ProbeAuth(ctx, "x"); err != nil || !status.Disabled || status.Method != HealthProbeMethodGenerate {
+		t.Fatalf("expected auth failure to disable the codex auth, got %+v err=%v", status, err)
+	}
+	if auth, _ = m.GetByID("x"); !auth.Disabled || auth.Status != StatusDisabled {
+		t.Fatalf("codex auth should be disabled, got status=%s disabled=%t", auth.Status, auth.Disabled)
+	}
+	if len(codex.models) != 1 || codex.models[0] != "gpt-mini" {
+		t.Fatalf("expected probe with configured model, got %v", codex.models)
+	}
+
+	probes := m.HealthProbes()
+	if len(probes) != 2 || probes[0].AuthID != "c" || !probes[0].Healthy || probes[1].AuthID != "x" || probes[1].Healthy {
+		t.Fatalf("unexpected probe snapshot %+v", probes)
+	}
+}
+
+func TestManagerProbeAuth_KeepOnAuthFailure(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	exec := &probeExecutor{provider: "gemini", err: probeStatusError{code: http.StatusForbidden}}
+	m.RegisterExecutor(exec)
+	m.probes.cfg = internalconfig.HealthProbeConfig{
+		Enabled:           true,
+		KeepOnAuthFailure: true,
+		Providers:         []internalconfig.HealthProbeProvider{{Provider: "gemini", Model: "gemini-flash"}},
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "g", Provider: "gemini"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	if _, err := m.ProbeAuth(ctx, "g"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if auth, _ := m.GetByID("g"); auth.Disabled || auth.Status != StatusError {
+		t.Fatalf("auth should stay enabled but erroring, got status=%s disabled=%t", auth.Status, auth.Disabled)
+	}
+
+	if _, err := m.ProbeAuth(ctx, "missing"); err == nil {
+		t.Fatalf("expected error for unknown auth")
+	}
+}
@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	defaultHedgeDelay        = time.Second
+	defaultHedgeExtraPercent = 10
+	// hedgeBudgetBurst caps the hedges saved up while upstreams answer quickly, so that a slow
+	// spell cannot duplicate a large batch of requests at once.
+	hedgeBudgetBurst = 5
+)
+
+// errHedgeLost cancels the stream that lost a hedging race.
+var errHedgeLost = errors.New("hedged request lost the race")
+
+// hedger holds the hedging delays per model and the budget of extra upstream requests. Every
+// hedge-eligible request earns MaxExtraPercent/100 of a duplicate and every duplicate spends
+// a whole one, so duplicates never exceed that share of the eligible requests.
+type hedger struct {
+	mu      sync.Mutex
+	delays  map[string]time.Duration
+	percent int
+	credit  float64
+}
+
+// SetHedgingConfig applies the hedging settings. Requests already in flight are not affected.
+func (m *Manager) SetHedgingConfig(cfg internalconfig.HedgingConfig) {
+	if m == nil {
+		return
+	}
+	delays := make(map[string]time.Duration, len(cfg.Models))
+	for _, entry := range cfg.Models {
+		model := strings.ToLower(strings.TrimSpace(entry.Model))
+		if model == "" {
+			continue
+		}
+		delay := time.Duration(entry.DelayMs) * time.Millisecond
+		if delay <= 0 {
+			delay = defaultHedgeDelay
+		}
+		delays[model] = delay
+	}
+	percent := cfg.MaxExtraPercent
+	if percent <= 0 {
+		percent = defaultHedgeExtraPercent
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	m.hedge.mu.Lock()
+	m.hedge.delays = delays
+	m.hedge.percent = percent
+	m.hedge.mu.Unlock()
+}
+
+// delayFor returns how long a stream of model may go without a first chunk before it is
+// hedged, or zero when the model is not hedged. Requests pinned to a credential are never hedged.
+func (h *hedger) delayFor(model string, opts cliproxyexecutor.Options) time.Duration {
+	if forceAuthIDFromOptions(opts.Metadata) != "" {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.delays) == 0 {
+		return 0
+	}
+	key := strings.ToLower(strings.TrimSpace(model))
+	if delay, ok := h.delays[key]; ok {
+		return delay
+	}
+	if idx := strings.Index(key, "/"); idx >= 0 {
+		return h.delays[key[idx+1:]]
+	}
+	return 0
+}
+
+// earn credits the budget for one hedge-eligible request.
+func (h *hedger) earn() {
+	h.mu.Lock()
+	h.credit = minFloat(h.credit+float64(h.percent)/100, hedgeBudgetBurst)
+	h.mu.Unlock()
+}
+
+// spend takes one duplicate from the budget, reporting false when none is left.
+func (h *hedger) spend() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.credit < 1 {
+		return false
+	}
+	h.credit--
+	return true
+}
+
+// refund returns a duplicate that was never sent upstream.
+func (h *hedger) refund() {
+	h.mu.Lock()
+	h.credit = minFloat(h.credit+1, hedgeBudgetBurst)
+	h.mu.Unlock()
+}
+
+// hedgeLeg is one stream of a hedged request and the cancel function of its context.
+type hedgeLeg struct {
+	chunks <-chan cliproxyexecutor.StreamChunk
+	cancel context.CancelCauseFunc
+}
+
+// finish forwards first, when ok, and the rest of the leg to out.
+func (l *hedgeLeg) finish(out chan<- cliproxyexecutor.StreamChunk, first cliproxyexecutor.StreamChunk, ok bool) {
+	defer l.cancel(nil)
+	if !ok {
+		return
+	}
+	out <- first
+	for chunk := range l.chunks {
+		out <- chunk
+	}
+}
+
+// abandon cancels the leg and discards what it still produces.
+func (l *hedgeLeg) abandon() {
+	l.cancel(errHedgeLost)
+	chunks := l.chunks
+	l.chunks = nil
+	go func() {
+		for range chunks {
+		}
+	}()
+}
+
+// hedgeStart is the outcome of starting a leg of a hedged request. ok is false when no
+// upstream stream was opened.
+type hedgeStart struct {
+	leg hedgeLeg
+	ok  bool
+	err error
+}
+
+// pendingLeg is a leg of a hedged request whose upstream stream is still being opened.
+type pendingLeg struct {
+	started <-chan hedgeStart
+	cancel  context.CancelCauseFunc
+}
+
+// abandon cancels the start of the leg and discards the stream if it opens anyway.
+func (p *pendingLeg) abandon() {
+	p.cancel(errHedgeLost)
+	started := p.started
+	p.started = nil
+	go func() {
+		if s := <-started; s.ok {
+			s.leg.abandon()
+		}
+	}()
+}
+
+// lostHedge reports whether ctx was canceled because another leg of a hedged request won.
+func lostHedge(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+// triedAuths is the set of credentials a streaming request already used. The legs of a hedged
+// request pick from it concurrently.
+type triedAuths struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+func newTriedAuths() *triedAuths {
+	return &triedAuths{ids: make(map[string]struct{})}
+}
+
+// pickUntried selects a credential of provider that was not tried yet and marks it as tried.
+func (m *Manager) pickUntried(ctx context.Context, provider, routeModel string, opts cliproxyexecutor.Options, tried *triedAuths) (*Auth, ProviderExecutor, error) {
+	tried.mu.Lock()
+	defer tried.mu.Unlock()
+	auth, executor, err := m.pickNext(ctx, provider, routeModel, opts, tried.ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	tried.ids[auth.ID] = struct{}{}
+	return auth, executor, nil
+}
+
+// hedgeStream sends req with credentials of provider. When no chunk arrives within delay of
+// dispatch, including the time the upstream takes to accept the stream, the request is
+// duplicated to another credential of provider, or else of the other providers serving the
+// model; the leg that streams first is returned and the other one is canceled. The call returns
+// once either leg opened its stream, or with the error of the primary when none could.
+func (m *Manager) hedgeStream(ctx context.Context, provider string, providers []string, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, delay time.Duration) (<-chan cliproxyexecutor.StreamChunk, error) {
+	m.hedge.earn()
+	timer := time.NewTimer(delay)
+	tried := newTriedAuths()
+	primaryCtx, primaryCancel := context.WithCancelCause(ctx)
+	primaryStarted := make(chan hedgeStart, 1)
+	go func() {
+		chunks, err := m.streamFirst(primaryCtx, provider, routeModel, req, opts, tried)
+		primaryStarted <- hedgeStart{leg: hedgeLeg{chunks: chunks, cancel: primaryCancel}, ok: err == nil, err: err}
+	}()
+
+	out := make(chan cliproxyexecutor.StreamChunk)
+	ready := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer timer.Stop()
+		race := hedgeRace{
+			primaryPending: &pendingLeg{started: primaryStarted, cancel: primaryCancel},
+			ready:          ready,
+		}
+		timerC := timer.C
+		for !race.done {
+			var primaryStart, duplicateStart <-chan hedgeStart
+			if race.primaryPending != nil {
+				primaryStart = race.primaryPending.started
+			}
+			if race.duplicatePending != nil {
+				duplicateStart = race.duplicatePending.started
+			}
+			select {
+			case <-timerC:
+				timerC = nil
+				race.duplicatePending = m.startHedge(ctx, provider, providers, routeModel, req, opts, tried)
+				if race.duplicatePending != nil {
+					logEntryWithRequestID(ctx).Debugf("model %s produced no output within %s, hedged to a second credential", routeModel, delay)
+				}
+			case s := <-primaryStart:
+				race.primaryPending = nil
+				race.started(&race.primary, s)
+			case s := <-duplicateStart:
+				race.duplicatePending = nil
+				race.started(&race.duplicate, s)
+			case chunk, ok := <-race.primary.chunks:
+				race.received(out, &race.primary, &race.duplicate, race.duplicatePending, chunk, ok)
+			case chunk, ok := <-race.duplicate.chunks:
+				race.received(out, &race.duplicate, &race.primary, race.primaryPending, chunk, ok)
+			}
+			race.settle(out)
+		}
+	}()
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// hedgeRace tracks the legs of a hedged request until one of them wins.
+type hedgeRace struct {
+	primary, duplicate               hedgeLeg
+	primaryPending, duplicatePending *pendingLeg
+	err                              error
+	failed                           cliproxyexecutor.StreamChunk
+	hasFailed                        bool
+	ready                            chan<- error
+	signaled                         bool
+	done                             bool
+}
+
+// started records the outcome of opening a leg and releases the caller once a stream is open.
+func (r *hedgeRace) started(leg *hedgeLeg, s hedgeStart) {
+	if !s.ok {
+		if r.err == nil || leg == &r.primary {
+			r.err = s.err
+		}
+		return
+	}
+	*leg = s.leg
+	r.signal(nil)
+}
+
+// received handles a chunk of leg. The first leg producing output wins and every other leg is
+// abandoned; a leg that fails or ends before producing output only wins when no other leg is
+// left.
+func (r *hedgeRace) received(out chan<- cliproxyexecutor.StreamChunk, leg, other *hedgeLeg, otherPending *pendingLeg, chunk cliproxyexecutor.StreamChunk, ok bool) {
+	if (ok && chunk.Err == nil) || (other.chunks == nil && otherPending == nil) {
+		if other.chunks != nil {
+			other.abandon()
+		}
+		if otherPending != nil {
+			otherPending.abandon()
+		}
+		r.done = true
+		leg.finish(out, chunk, ok)
+		return
+	}
+	if ok {
+		r.failed, r.hasFailed = chunk, true
+	}
+	leg.abandon()
+}
+
+// settle ends the race when no leg is streaming or still being opened. Before any stream opened
+// the caller gets the start error; afterwards the last failure is forwarded to out.
+func (r *hedgeRace) settle(out chan<- cliproxyexecutor.StreamChunk) {
+	if r.done || r.primary.chunks != nil || r.duplicate.chunks != nil || r.primaryPending != nil || r.duplicatePending != nil {
+		return
+	}
+	r.done = true
+	if r.err == nil {
+		r.err = &Error{Code: "auth_not_found", Message: "no auth available"}
+	}
+	if !r.signaled {
+		r.signal(r.err)
+		return
+	}
+	if r.hasFailed {
+		out <- r.failed
+		return
+	}
+	out <- cliproxyexecutor.StreamChunk{Err: r.err}
+}
+
+func (r *hedgeRace) signal(err error) {
+	if !r.signaled {
+		r.signaled = true
+		r.ready <- err
+	}
+}
+
+// startHedge opens the duplicate of a hedged request with a credential not tried yet, in the
+// background. Only one upstream attempt is made, and none when the extra spend budget is used
+// up; it returns nil when no duplicate is sent.
+func (m *Manager) startHedge(ctx context.Context, provider string, providers []string, routeModel string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, tried *triedAuths) *pendingLeg {
+	if !m.hedge.spend() {
+		return nil
+	}
+	hedgeCtx := coreusage.WithHedged(ctx)
+	candidates := append([]string{provider}, providers...)
+	for i, candidate := range candidates {
+		if i > 0 && candidate == provider {
+			continue
+		}
+		auth, executor, errPick := m.pickUntried(hedgeCtx, candidate, routeModel, opts, tried)
+		if errPick != nil {
+			continue
+		}
+		streamCtx, cancel := context.WithCancelCause(hedgeCtx)
+		started := make(chan hedgeStart, 1)
+		go func() {
+			chunks, errStream := m.startStream(streamCtx, candidate, routeModel, auth, executor, req, opts)
+			started <- hedgeStart{leg: hedgeLeg{chunks: chunks, cancel: cancel}, ok: errStream == nil, err: errStream}
+		}()
+		return &pendingLeg{started: started, cancel: cancel}
+	}
+	m.hedge.refund()
+	return nil
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// delayedStreamExecutor accepts a stream after the start delay and streams the auth ID after
+// the delay configured for the auth, and records which streams were hedged and which were
+// canceled.
+type delayedStreamExecutor struct {
+	recordingExecutor
+	startDelays map[string]time.Duration
+	delays      map[string]time.Duration
+	mu          sync.Mutex
+	hedged      map[string]bool
+	canceled    map[string]bool
+}
+
+func (e *delayedStreamExecutor) ExecuteStream(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.hedged[auth.ID] = coreusage.IsHedged(ctx)
+	e.mu.Unlock()
+	select {
+	case <-time.After(e.startDelays[auth.ID]):
+	case <-ctx.Done():
+		e.mu.Lock()
+		e.canceled[auth.ID] = true
+		e.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	out := make(chan cliproxyexecutor.StreamChunk, 1)
+	go func() {
+		defer close(out)
+		select {
+		case <-time.After(e.delays[auth.ID]):
+			out <- cliproxyexecutor.StreamChunk{Payload: []byte(auth.ID)}
+		case <-ctx.Done():
+			e.mu.Lock()
+			e.canceled[auth.ID] = true
+			e.mu.Unlock()
+			out <- cliproxyexecutor.StreamChunk{Err: ctx.Err()}
+		}
+	}()
+	return out, nil
+}
+
+func TestManagerExecuteStream_HedgesSlowFirstByte(t *testing.T) {
+	const model = "hedge-test-model"
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"hedge-a-slow", "hedge-b-fast"} {
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: model}})
+	}
+	t.Cleanup(func() {
+		reg.UnregisterClient("hedge-a-slow")
+		reg.UnregisterClient("hedge-b-fast")
+	})
+
+	exec := &delayedStreamExecutor{
+		recordingExecutor: recordingExecutor{provider: "claude"},
+		delays:            map[string]time.Duration{"hedge-a-slow": time.Minute, "hedge-b-fast": 0},
+		hedged:            make(map[string]bool),
+		canceled:          make(map[string]bool),
+	}
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"hedge-a-slow", "hedge-b-fast"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	m.SetHedgingConfig(internalconfig.HedgingConfig{MaxExtraPercent: 100, Models: []internalconfig.HedgedModel{{Model: model, DelayMs: 20}}})
+
+	chunks, err := m.ExecuteStream(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("execute stream: %v", err)
+	}
+	var payloads []string
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		payloads = append(payloads, string(chunk.Payload))
+	}
+	if len(payloads) != 1 || payloads[0] != "hedge-b-fast" {
+		t.Fatalf("expected the hedged credential to win, got %v", payloads)
+	}
+	waitFor(t, "canceled loser", func() bool {
+		exec.mu.Lock()
+		defer exec.mu.Unlock()
+		return exec.canceled["hedge-a-slow"]
+	})
+	exec.mu.Lock()
+	if exec.hedged["hedge-a-slow"] || !exec.hedged["hedge-b-fast"] {
+		t.Fatalf("only the duplicate should be marked as hedged, got %v", exec.hedged)
+	}
+	exec.mu.Unlock()
+	if auth, _ := m.GetByID("hedge-a-slow"); auth.ModelStates[model] != nil && auth.ModelStates[model].Status == StatusError {
+		t.Fatal("the canceled loser should not be marked as failed")
+	}
+}
+
+func TestManagerExecuteStream_HedgesSlowStreamStart(t *testing.T) {
+	const model = "hedge-start-test-model"
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"hedge-start-a-slow", "hedge-start-b-fast"} {
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: model}})
+	}
+	t.Cleanup(func() {
+		reg.UnregisterClient("hedge-start-a-slow")
+		reg.UnregisterClient("hedge-start-b-fast")
+	})
+
+	exec := &delayedStreamExecutor{
+		recordingExecutor: recordingExecutor{provider: "claude"},
+		startDelays:       map[string]time.Duration{"hedge-start-a-slow": time.Minute},
+		delays:            map[string]time.Duration{},
+		hedged:            make(map[string]bool),
+		canceled:          make(map[string]bool),
+	}
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(exec)
+	for _, id := range []string{"hedge-start-a-slow", "hedge-start-b-fast"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+	m.SetHedgingConfig(internalconfig.HedgingConfig{MaxExtraPercent: 100, Models: []internalconfig.HedgedModel{{Model: model, DelayMs: 20}}})
+
+	// The primary upstream does not even accept the stream, so the hedge delay has to count
+	// from dispatch rather than from the opened stream.
+	done := make(chan []string, 1)
+	go func() {
+		chunks, err := m.ExecuteStream(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, cliproxyexecutor.Options{})
+		if err != nil {
+			t.Errorf("execute stream: %v", err)
+			done <- nil
+			return
+		}
+		var payloads []string
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				t.Errorf("unexpected stream error: %v", chunk.Err)
+			}
+			payloads = append(payloads, string(chunk.Payload))
+		}
+		done <- payloads
+	}()
+	select {
+	case payloads := <-done:
+		if len(payloads) != 1 || payloads[0] != "hedge-start-b-fast" {
+			t.Fatalf("expected the hedged credential to win, got %v", payloads)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hedge did not start while the primary stream was still opening")
+	}
+	waitFor(t, "canceled loser", func() bool {
+		exec.mu.Lock()
+		defer exec.mu.Unlock()
+		return exec.canceled["hedge-start-a-slow"]
+	})
+	if auth, _ := m.GetByID("hedge-start-a-slow"); auth.ModelStates[model] != nil && auth.ModelStates[model].Status == StatusError {
+		t.Fatal("the canceled loser should not be marked as failed")
+	}
+}
+
+func TestHedger_CapsExtraSpend(t *testing.T) {
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.SetHedgingConfig(internalconfig.HedgingConfig{MaxExtraPercent: 25, Models: []internalconfig.HedgedModel{{Model: "gpt-5-mini"}}})
+	if delay := m.hedge.delayFor("team/GPT-5-mini", cliproxyexecutor.Options{}); delay != defaultHedgeDelay {
+		t.Fatalf("expected the default delay for a prefixed model, got %v", delay)
+	}
+	if delay := m.hedge.delayFor("gpt-5", cliproxyexecutor.Options{}); delay != 0 {
+		t.Fatalf("models without opt-in should not be hedged, got %v", delay)
+	}
+
+	hedges := 0
+	for i := 0; i < 100; i++ {
+		m.hedge.earn()
+		if m.hedge.spend() {
+			hedges++
+		}
+	}
+	if hedges != 25 {
+		t.Fatalf("expected 25 hedges per 100 requests, got %d", hedges)
+	}
+}
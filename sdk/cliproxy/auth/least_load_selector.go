@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// loadTrackerAware is implemented by selectors that need the manager's load tracker.
+type loadTrackerAware interface {
+	SetLoadTracker(tracker *LoadTracker)
+}
+
+// LeastLoadSelector picks the available credential with the lowest expected wait, scoring
+// each candidate by (in-flight requests + 1) x EWMA time-to-first-byte for the model.
+// Credentials without a fresh latency estimate are scored with the mean of the known
+// estimates so they keep receiving traffic. Ties are broken round-robin.
+type LeastLoadSelector struct {
+	mu      sync.Mutex
+	tracker *LoadTracker
+	cursors map[string]int
+}
+
+// SetLoadTracker attaches the tracker the selector reads from.
+func (s *LeastLoadSelector) SetLoadTracker(tracker *LoadTracker) {
+	s.mu.Lock()
+	s.tracker = tracker
+	s.mu.Unlock()
+}
+
+// Pick selects the least loaded, fastest available auth.
+func (s *LeastLoadSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	key := provider + ":" + model
+	cursor := s.cursors[key]
+	s.cursors[key] = cursor + 1
+	tracker := s.tracker
+	if tracker == nil || len(available) == 1 {
+		return available[cursor%len(available)], nil
+	}
+
+	inFlight := make([]int, len(available))
+	latency := make([]time.Duration, len(available))
+	known := make([]bool, len(available))
+	var sum time.Duration
+	count := 0
+	tracker.mu.Lock()
+	for i, candidate := range available {
+		inFlight[i], latency[i], known[i] = tracker.load(candidate.ID, model, now)
+		if known[i] {
+			sum += latency[i]
+			count++
+		}
+	}
+	tracker.mu.Unlock()
+
+	fallback := time.Second
+	if count > 0 {
+		fallback = sum / time.Duration(count)
+	}
+	var best *Auth
+	var bestScore float64
+	for offset := 0; offset < len(available); offset++ {
+		i := (cursor + offset) % len(available)
+		expected := latency[i]
+		if !known[i] {
+			expected = fallback
+		}
+		score := float64(inFlight[i]+1) * float64(expected)
+		if best == nil || score < bestScore {
+			best, bestScore = available[i], score
+		}
+	}
+	return best, nil
+}
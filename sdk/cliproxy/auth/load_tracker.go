@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// loadEWMAAlpha weights the newest time-to-first-byte sample.
+	loadEWMAAlpha = 0.3
+	// loadSampleStaleAfter is the age after which a latency estimate is ignored so that a
+	// credential that was slow once gets probed again instead of being avoided forever.
+	loadSampleStaleAfter = 5 * time.Minute
+)
+
+// LoadTracker records in-flight requests per auth and an exponentially weighted moving
+// average of time-to-first-byte per auth and model. When state is shared between replicas it
+// also holds the in-flight counts reported by the other replicas.
+type LoadTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	remote   map[string]int
+	latency  map[string]map[string]*latencyStat
+}
+
+type latencyStat struct {
+	ewma     time.Duration
+	samples  int64
+	failures int64
+	lastAt   time.Time
+}
+
+// LoadStat is a snapshot of the load tracked for one auth.
+type LoadStat struct {
+	AuthID         string          `json:"auth_id"`
+	InFlight       int             `json:"in_flight"`
+	RemoteInFlight int             `json:"remote_in_flight,omitempty"`
+	Models         []ModelLoadStat `json:"models,omitempty"`
+}
+
+// ModelLoadStat is a snapshot of the latency tracked for one auth and model.
+type ModelLoadStat struct {
+	Model    string    `json:"model"`
+	EWMATTFB float64   `json:"ewma_ttfb_ms"`
+	Samples  int64     `json:"samples"`
+	Failures int64     `json:"failures"`
+	LastAt   time.Time `json:"last_at"`
+	Stale    bool      `json:"stale"`
+}
+
+// NewLoadTracker creates an empty tracker.
+func NewLoadTracker() *LoadTracker {
+	return &LoadTracker{
+		inFlight: make(map[string]int),
+		latency:  make(map[string]map[string]*latencyStat),
+	}
+}
+
+// Begin marks a request in flight on authID and returns a function that ends it.
+// The returned function is safe to call more than once.
+func (t *LoadTracker) Begin(authID string) func() {
+	if t == nil || authID == "" {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.inFlight[authID]++
+	t.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			if t.inFlight[authID] <= 1 {
+				delete(t.inFlight, authID)
+			} else {
+				t.inFlight[authID]--
+			}
+			t.mu.Unlock()
+		})
+	}
+}
+
+// Observe records the outcome of a request. Successful requests with a positive ttfb update
+// the latency average; failures are only counted.
+func (t *LoadTracker) Observe(authID, model string, ttfb time.Duration, success bool) {
+	if t == nil || authID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	models := t.latency[authID]
+	if models == nil {
+		models = make(map[string]*latencyStat)
+		t.latency[authID] = models
+	}
+	stat := models[model]
+	if stat == nil {
+		stat = &latencyStat{}
+		models[model] = stat
+	}
+	if !success {
+		stat.failures++
+		return
+	}
+	if ttfb <= 0 {
+		return
+	}
+	if stat.samples == 0 || time.Since(stat.lastAt) > loadSampleStaleAfter {
+		stat.ewma = ttfb
+	} else {
+		stat.ewma = time.Duration(loadEWMAAlpha*float64(ttfb) + (1-loadEWMAAlpha)*float64(stat.ewma))
+	}
+	stat.samples++
+	stat.lastAt = time.Now()
+}
+
+// InFlight returns the number of requests in flight on authID across all replicas.
+func (t *LoadTracker) InFlight(authID string) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inFlight[authID] + t.remote[authID]
+}
+
+// localInFlight returns a copy of the in-flight counts of this process.
+func (t *LoadTracker) localInFlight() map[string]int {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]int, len(t.inFlight))
+	for id, count := range t.inFlight {
+		out[id] = count
+	}
+	return out
+}
+
+// setRemoteInFlight replaces the in-flight counts reported by other replicas.
+func (t *LoadTracker) setRemoteInFlight(remote map[string]int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.remote = remote
+	t.mu.Unlock()
+}
+
+// load returns the in-flight count across all replicas and the latency estimate of authID
+// for model. ok is false when no fresh estimate exists.
+func (t *LoadTracker) load(authID, model string, now time.Time) (int, time.Duration, bool) {
+	inFlight := t.inFlight[authID] + t.remote[authID]
+	stat := t.latency[authID][model]
+	if stat == nil || stat.samples == 0 || now.Sub(stat.lastAt) > loadSampleStaleAfter {
+		return inFlight, 0, false
+	}
+	return inFlight, stat.ewma, true
+}
+
+// Snapshot returns the tracked load of every auth, sorted by auth ID.
+func (t *LoadTracker) Snapshot() []LoadStat {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make(map[string]struct{}, len(t.inFlight)+len(t.latency))
+	for id := range t.inFlight {
+		ids[id] = struct{}{}
+	}
+	for id := range t.remote {
+		ids[id] = struct{}{}
+	}
+	for id := range t.latency {
+		ids[id] = struct{}{}
+	}
+	out := make([]LoadStat, 0, len(ids))
+	for id := range ids {
+		stat := LoadStat{AuthID: id, InFlight: t.inFlight[id], RemoteInFlight: t.remote[id]}
+		for model, s := range t.latency[id] {
+			stat.Models = append(stat.Models, ModelLoadStat{
+				Model:    model,
+				EWMATTFB: float64(s.ewma) / float64(time.Millisecond),
+				Samples:  s.samples,
+				Failures: s.failures,
+				LastAt:   s.lastAt,
+				Stale:    s.samples == 0 || now.Sub(s.lastAt) > loadSampleStaleAfter,
+			})
+		}
+		sort.Slice(stat.Models, func(i, j int) bool { return stat.Models[i].Model < stat.Models[j].Model })
+		out = append(out, stat)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// quotaHeadroomShare is the share of a window below which a credential counts as nearly
+	// used up, and quotaHeadroomPercent the matching usage percentage.
+	quotaHeadroomShare   = 0.05
+	quotaHeadroomPercent = 95
+	// quotaUsageStaleAfter is how long a report without a reset time is trusted.
+	quotaUsageStaleAfter = time.Minute
+)
+
+// Quota usage sources.
+const (
+	QuotaSourceAnthropic  = "anthropic"
+	QuotaSourceOpenAI     = "openai"
+	QuotaSourceCodex      = "codex"
+	QuotaSourceRetryAfter = "retry-after"
+)
+
+// QuotaWindow is the capacity left in one provider rate limit window.
+type QuotaWindow struct {
+	// Limit is the size of the window; zero when the provider did not report it.
+	Limit int64 `json:"limit,omitempty"`
+	// Remaining is what is left of the window.
+	Remaining int64 `json:"remaining"`
+	// ResetAt is when the window refills; zero when unknown.
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// QuotaUsage is the remaining capacity a provider last reported for a credential, taken from
+// rate limit response headers or the retry delay of a rate limited request. Values are
+// replaced as a whole and never modified in place.
+type QuotaUsage struct {
+	// Source names the provider convention the values were read from.
+	Source string `json:"source"`
+	// Requests and Tokens hold the request and token windows when reported.
+	Requests *QuotaWindow `json:"requests,omitempty"`
+	Tokens   *QuotaWindow `json:"tokens,omitempty"`
+	// UsedPercent is the highest share of a usage window already consumed (Codex).
+	UsedPercent float64 `json:"used_percent,omitempty"`
+	// UsageResetAt is when the window behind UsedPercent resets.
+	UsageResetAt time.Time `json:"usage_reset_at"`
+	// UpdatedAt is when the values were reported.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// HasHeadroom reports whether the credential may still be used without running into the
+// reported limits. Windows that already reset, and reports without a reset time older than a
+// minute, are ignored.
+func (u *QuotaUsage) HasHeadroom(now time.Time) bool {
+	if u == nil {
+		return true
+	}
+	fresh := now.Sub(u.UpdatedAt) <= quotaUsageStaleAfter
+	if u.Requests.depleted(now, fresh) || u.Tokens.depleted(now, fresh) {
+		return false
+	}
+	if u.UsedPercent >= quotaHeadroomPercent {
+		if u.UsageResetAt.IsZero() {
+			return !fresh
+		}
+		return !u.UsageResetAt.After(now)
+	}
+	return true
+}
+
+func (w *QuotaWindow) depleted(now time.Time, fresh bool) bool {
+	if w == nil {
+		return false
+	}
+	if w.ResetAt.IsZero() {
+		if !fresh {
+			return false
+		}
+	} else if !w.ResetAt.After(now) {
+		return false
+	}
+	if w.Limit > 0 {
+		return float64(w.Remaining) <= float64(w.Limit)*quotaHeadroomShare
+	}
+	return w.Remaining <= 0
+}
+
+// ParseQuotaHeaders reads the rate limit headers of a provider response: anthropic-ratelimit-*
+// (Anthropic), x-ratelimit-* (OpenAI and Codex) and the x-codex-* usage percentages. It returns
+// nil when the response carries none of them.
+func ParseQuotaHeaders(header http.Header, now time.Time) *QuotaUsage {
+	if len(header) == 0 {
+		return nil
+	}
+	usage := &QuotaUsage{UpdatedAt: now}
+	if requests := parseQuotaWindow(header, "anthropic-ratelimit-requests-limit", "anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset", now); requests != nil {
+		usage.Source, usage.Requests = QuotaSourceAnthropic, requests
+	}
+	if tokens := parseQuotaWindow(header, "anthropic-ratelimit-tokens-limit", "anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset", now); tokens != nil {
+		usage.Source, usage.Tokens = QuotaSourceAnthropic, tokens
+	}
+	if usage.Source == "" {
+		if requests := parseQuotaWindow(header, "x-ratelimit-limit-requests", "x-ratelimit-remaining-requests", "x-ratelimit-reset-requests", now); requests != nil {
+			usage.Source, usage.Requests = QuotaSourceOpenAI, requests
+		}
+		if tokens := parseQuotaWindow(header, "x-ratelimit-limit-tokens", "x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens", now); tokens != nil {
+			usage.Source, usage.Tokens = QuotaSourceOpenAI, tokens
+		}
+	}
+	for _, window := range []string{"primary", "secondary"} {
+		percent, ok := headerFloat(header, "x-codex-"+window+"-used-percent")
+		if !ok || percent < usage.UsedPercent {
+			continue
+		}
+		if usage.Source == "" {
+			usage.Source = QuotaSourceCodex
+		}
+		usage.UsedPercent = percent
+		usage.UsageResetAt = time.Time{}
+		if at, okAt := headerInt(header, "x-codex-"+window+"-reset-at"); okAt && at > 0 {
+			usage.UsageResetAt = time.Unix(int64(at), 0)
+		} else if after, okAfter := headerInt(header, "x-codex-"+window+"-reset-after-seconds"); okAfter {
+			usage.UsageResetAt = now.Add(time.Duration(after) * time.Second)
+		}
+	}
+	if usage.Source == "" {
+		return nil
+	}
+	return usage
+}
+
+func parseQuotaWindow(header http.Header, limitName, remainingName, resetName string, now time.Time) *QuotaWindow {
+	remaining, okRemaining := headerInt(header, remainingName)
+	limit, okLimit := headerInt(header, limitName)
+	if !okRemaining && !okLimit {
+		return nil
+	}
+	window := &QuotaWindow{Limit: int64(limit), Remaining: int64(remaining)}
+	if !okRemaining {
+		window.Remaining = window.Limit
+	}
+	window.ResetAt = parseQuotaReset(header.Get(resetName), now)
+	return window
+}
+
+// parseQuotaReset accepts an RFC 3339 timestamp (Anthropic) or a duration such as "6m0s" or
+// "20ms" (OpenAI).
+func parseQuotaReset(raw string, now time.Time) time.Time {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}
+	}
+	if at, err := time.Parse(time.RFC3339, raw); err == nil {
+		return at
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return now.Add(d)
+	}
+	return time.Time{}
+}
+
+func headerInt(header http.Header, name string) (int, bool) {
+	raw := strings.TrimSpace(header.Get(name))
+	if raw == "" {
+		return 0, false
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+func headerFloat(header http.Header, name string) (float64, bool) {
+	raw := strings.TrimSpace(header.Get(name))
+	if raw == "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// authHasHeadroom reports whether the quota last reported for auth leaves headroom. Usage
+// recorded for model takes precedence over the usage of the credential as a whole.
+func authHasHeadroom(auth *Auth, model string, now time.Time) bool {
+	if state := auth.ModelStates[model]; state != nil && state.Quota.Usage != nil {
+		return state.Quota.Usage.HasHeadroom(now)
+	}
+	return auth.Quota.Usage.HasHeadroom(now)
+}
+
+// preferHeadroom drops candidates whose reported quota is nearly used up while other
+// candidates still have headroom.
+func preferHeadroom(candidates []*Auth, model string, now time.Time) []*Auth {
+	roomy := make([]*Auth, 0, len(candidates))
+	for _, candidate := range candidates {
+		if authHasHeadroom(candidate, model, now) {
+			roomy = append(roomy, candidate)
+		}
+	}
+	if len(roomy) == 0 || len(roomy) == len(candidates) {
+		return candidates
+	}
+	return roomy
+}
+
+// recordQuotaUsage stores usage reported for authID on the auth and on its state for model.
+func (m *Manager) recordQuotaUsage(authID, model string, usage *QuotaUsage) {
+	if usage == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth := m.auths[authID]
+	if auth == nil {
+		return
+	}
+	auth.Quota.Usage = usage
+	if state := ensureModelState(auth, model); state != nil {
+		state.Quota.Usage = usage
+	}
+}
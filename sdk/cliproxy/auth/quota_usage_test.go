@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestParseQuotaHeaders(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	anthropic := http.Header{}
+	anthropic.Set("anthropic-ratelimit-requests-limit", "50")
+	anthropic.Set("anthropic-ratelimit-requests-remaining", "1")
+	anthropic.Set("anthropic-ratelimit-requests-reset", "2026-01-02T03:05:00Z")
+	anthropic.Set("anthropic-ratelimit-tokens-limit", "40000")
+	anthropic.Set("anthropic-ratelimit-tokens-remaining", "39000")
+	usage := ParseQuotaHeaders(anthropic, now)
+	if usage == nil || usage.Source != QuotaSourceAnthropic || usage.Requests.Remaining != 1 || usage.Tokens.Limit != 40000 {
+		t.Fatalf("unexpected anthropic usage %+v", usage)
+	}
+	if !usage.Requests.ResetAt.Equal(now.Add(55 * time.Second)) {
+		t.Fatalf("unexpected reset %v", usage.Requests.ResetAt)
+	}
+	if usage.HasHeadroom(now) || !usage.HasHeadroom(now.Add(time.Minute)) {
+		t.Fatal("one request left out of 50 should have no headroom until the window resets")
+	}
+
+	codex := http.Header{}
+	codex.Set("x-ratelimit-limit-requests", "500")
+	codex.Set("x-ratelimit-remaining-requests", "499")
+	codex.Set("x-ratelimit-reset-requests", "120ms")
+	codex.Set("x-codex-primary-used-percent", "40")
+	codex.Set("x-codex-secondary-used-percent", "97.5")
+	codex.Set("x-codex-secondary-reset-after-seconds", "3600")
+	usage = ParseQuotaHeaders(codex, now)
+	if usage == nil || usage.Source != QuotaSourceOpenAI || usage.Requests.Limit != 500 || !usage.Requests.ResetAt.Equal(now.Add(120*time.Millisecond)) {
+		t.Fatalf("unexpected openai usage %+v", usage)
+	}
+	if usage.UsedPercent != 97.5 || !usage.UsageResetAt.Equal(now.Add(time.Hour)) || usage.HasHeadroom(now) {
+		t.Fatalf("unexpected codex usage %+v", usage)
+	}
+
+	if ParseQuotaHeaders(http.Header{"Content-Type": {"application/json"}}, now) != nil {
+		t.Fatal("responses without rate limit headers should not report usage")
+	}
+}
+
+func TestManagerPickNext_PrefersHeadroom(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(&recordingExecutor{provider: "codex"})
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"busy", "idle"} {
+		reg.RegisterClient(id, "codex", []*registry.ModelInfo{{ID: "gpt-5"}})
+		t.Cleanup(func() { reg.UnregisterClient(id) })
+		if _, err := m.Register(ctx, &Auth{ID: id, Provider: "codex"}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	header := http.Header{}
+	header.Set("x-codex-primary-used-percent", "100")
+	header.Set("x-codex-primary-reset-after-seconds", "600")
+	observe := cliproxyexecutor.ResponseObserverFromContext(m.withRateLimitObserver(ctx, "busy", "gpt-5", "gpt-5"))
+	observe(http.StatusOK, header)
+
+	for i := 0; i < 4; i++ {
+		auth, _, err := m.pickNext(ctx, "codex", "gpt-5", cliproxyexecutor.Options{}, map[string]struct{}{})
+		if err != nil {
+			t.Fatalf("pick: %v", err)
+		}
+		if auth.ID != "idle" {
+			t.Fatalf("expected the credential with headroom, got %s", auth.ID)
+		}
+	}
+	if auth, _ := m.GetByID("busy"); auth.Quota.Usage == nil || auth.ModelStates["gpt-5"].Quota.Usage.UsedPercent != 100 {
+		t.Fatalf("expected the reported usage on the auth, got %+v", auth.Quota)
+	}
+
+	// A credential without headroom is still used when it is the only one left.
+	auth, _, err := m.pickNext(ctx, "codex", "gpt-5", cliproxyexecutor.Options{}, map[string]struct{}{"idle": {}})
+	if err != nil || auth.ID != "busy" {
+		t.Fatalf("expected fallback to the busy credential, got %v %v", auth, err)
+	}
+}
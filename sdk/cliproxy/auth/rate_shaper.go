@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// rateShapeWindow is the period the per-minute limits refill over.
+const rateShapeWindow = time.Minute
+
+// errRateShaped is returned when every credential of a model is at its declared or learned limits.
+var errRateShaped = &Error{Code: "rate_limited", Message: "all credentials are at their rate limits", Retryable: true, HTTPStatus: http.StatusTooManyRequests}
+
+// RateLimits returns the limits declared for the credential and, when model is not empty, the
+// limits declared for that upstream model. Config keys carry them as "rpm", "tpm" and
+// "max_concurrent" attributes, model limits as "rpm:<model>" and "tpm:<model>"; auth files use
+// metadata fields of the same names and a "model_limits" object keyed by model.
+func (a *Auth) RateLimits(model string) (internalconfig.RateLimit, internalconfig.RateLimit) {
+	var credential, perModel internalconfig.RateLimit
+	if a == nil {
+		return credential, perModel
+	}
+	credential.RequestsPerMinute, _ = a.routingInt("rpm")
+	credential.TokensPerMinute, _ = a.routingInt("tpm")
+	credential.MaxConcurrent, _ = a.routingInt("max_concurrent")
+	if model == "" {
+		return credential, perModel
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(a.Attributes["rpm:"+model])); err == nil {
+		perModel.RequestsPerMinute = value
+	}
+	if value, err := strconv.Atoi(strings.TrimSpace(a.Attributes["tpm:"+model])); err == nil {
+		perModel.TokensPerMinute = value
+	}
+	if limits, ok := a.Metadata["model_limits"].(map[string]any); ok {
+		if entry, okEntry := limits[model].(map[string]any); okEntry {
+			if value, okValue := intValue(entry["rpm"]); okValue && perModel.RequestsPerMinute == 0 {
+				perModel.RequestsPerMinute = value
+			}
+			if value, okValue := intValue(entry["tpm"]); okValue && perModel.TokensPerMinute == 0 {
+				perModel.TokensPerMinute = value
+			}
+		}
+	}
+	return credential, perModel
+}
+
+// RateShapingStat is a snapshot of one token bucket of the rate shaper. Learned reports whether
+// the provider published limits for the bucket in response headers.
+type RateShapingStat struct {
+	AuthID            string  `json:"auth_id"`
+	Model             string  `json:"model,omitempty"`
+	RequestsPerMinute int     `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int     `json:"tokens_per_minute,omitempty"`
+	Learned           bool    `json:"learned,omitempty"`
+	Requests          float64 `json:"available_requests"`
+	Tokens            float64 `json:"available_tokens"`
+}
+
+// rateShaper paces requests per credential and per credential and model with token buckets, so
+// that provider limits are respected before the provider answers with 429. The limits are
+// declared in the configuration or auth file, or learned from provider response headers.
+type rateShaper struct {
+	mu      sync.Mutex
+	buckets map[shapeKey]*shapeBucket
+	learned map[shapeKey]internalconfig.RateLimit
+}
+
+// shapeKey identifies a bucket; model is empty for the credential-wide bucket.
+type shapeKey struct {
+	authID string
+	model  string
+}
+
+// shapeBucket holds the requests and tokens still available in the current window. Tokens are
+// charged after the fact from usage reports, so they may go negative.
+type shapeBucket struct {
+	requests float64
+	tokens   float64
+	limit    internalconfig.RateLimit
+	updated  time.Time
+}
+
+// refill tops the bucket up for the time passed since the last update.
+func (b *shapeBucket) refill(limit internalconfig.RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		share := elapsed.Seconds() / rateShapeWindow.Seconds()
+		b.requests = minFloat(b.requests+share*float64(limit.RequestsPerMinute), float64(limit.RequestsPerMinute))
+		b.tokens = minFloat(b.tokens+share*float64(limit.TokensPerMinute), float64(limit.TokensPerMinute))
+		b.updated = now
+	}
+	b.limit = limit
+}
+
+func (b *shapeBucket) exhausted() bool {
+	return (b.limit.RequestsPerMinute > 0 && b.requests < 1) || (b.limit.TokensPerMinute > 0 && b.tokens <= 0)
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// limitsLocked returns the credential-wide limits of auth and the limits for model, filling
+// limits not declared for the model with the ones learned from headers. Without a model the
+// learned limits apply to the credential-wide bucket. s.mu must be held.
+func (s *rateShaper) limitsLocked(auth *Auth, model string) (internalconfig.RateLimit, internalconfig.RateLimit) {
+	credential, perModel := auth.RateLimits(model)
+	target := &perModel
+	if model == "" {
+		target = &credential
+	}
+	learned := s.learned[shapeKey{authID: auth.ID, model: model}]
+	if target.RequestsPerMinute <= 0 {
+		target.RequestsPerMinute = learned.RequestsPerMinute
+	}
+	if target.TokensPerMinute <= 0 {
+		target.TokensPerMinute = learned.TokensPerMinute
+	}
+	perModel.MaxConcurrent = 0
+	return credential, perModel
+}
+
+// bucketLocked returns the refilled bucket of key, or nil when limit is unlimited. s.mu must be held.
+func (s *rateShaper) bucketLocked(key shapeKey, limit internalconfig.RateLimit, now time.Time) *shapeBucket {
+	if limit.RequestsPerMinute <= 0 && limit.TokensPerMinute <= 0 {
+		return nil
+	}
+	if s.buckets == nil {
+		s.buckets = make(map[shapeKey]*shapeBucket)
+	}
+	bucket := s.buckets[key]
+	if bucket == nil {
+		bucket = &shapeBucket{
+			requests: float64(limit.RequestsPerMinute),
+			tokens:   float64(limit.TokensPerMinute),
+			updated:  now,
+		}
+		s.buckets[key] = bucket
+	}
+	bucket.refill(limit, now)
+	return bucket
+}
+
+// blocked reports whether sending another request for model with auth would exceed its limits.
+func (s *rateShaper) blocked(auth *Auth, model string, load *LoadTracker, now time.Time) bool {
+	s.mu.Lock()
+	credential, perModel := s.limitsLocked(auth, model)
+	shaped := false
+	if bucket := s.bucketLocked(shapeKey{authID: auth.ID}, credential, now); bucket != nil && bucket.exhausted() {
+		shaped = true
+	}
+	if bucket := s.bucketLocked(shapeKey{authID: auth.ID, model: model}, perModel, now); bucket != nil && bucket.exhausted() {
+		shaped = true
+	}
+	s.mu.Unlock()
+	if !shaped && credential.MaxConcurrent > 0 {
+		shaped = load.InFlight(auth.ID) >= credential.MaxConcurrent
+	}
+	return shaped
+}
+
+// take charges one request for model to the buckets of auth.
+func (s *rateShaper) take(auth *Auth, model string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credential, perModel := s.limitsLocked(auth, model)
+	if bucket := s.bucketLocked(shapeKey{authID: auth.ID}, credential, now); bucket != nil {
+		bucket.requests--
+	}
+	if bucket := s.bucketLocked(shapeKey{authID: auth.ID, model: model}, perModel, now); bucket != nil {
+		bucket.requests--
+	}
+}
+
+// chargeTokens charges tokens reported by usage to the existing buckets of authID and model.
+func (s *rateShaper) chargeTokens(authID, model string, tokens int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []shapeKey{{authID: authID}}
+	if model != "" {
+		keys = append(keys, shapeKey{authID: authID, model: model})
+	}
+	for _, key := range keys {
+		if bucket := s.buckets[key]; bucket != nil && bucket.limit.TokensPerMinute > 0 {
+			bucket.tokens -= float64(tokens)
+		}
+	}
+}
+
+// learn records the limits a provider reported in usage for authID and model and lowers the
+// bucket to the remaining capacity the provider reported. Only windows that refill within
+// rateShapeWindow are learned; longer windows, such as daily quotas, are left to the quota
+// usage checks.
+func (s *rateShaper) learn(authID, model string, usage *QuotaUsage, now time.Time) {
+	if usage == nil {
+		return
+	}
+	requests, tokens := shapeableWindow(usage.Requests, now), shapeableWindow(usage.Tokens, now)
+	if requests == nil && tokens == nil {
+		return
+	}
+	key := shapeKey{authID: authID, model: model}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.learned == nil {
+		s.learned = make(map[shapeKey]internalconfig.RateLimit)
+	}
+	learned := s.learned[key]
+	if requests != nil && requests.Limit > 0 {
+		learned.RequestsPerMinute = int(requests.Limit)
+	}
+	if tokens != nil && tokens.Limit > 0 {
+		learned.TokensPerMinute = int(tokens.Limit)
+	}
+	s.learned[key] = learned
+	bucket := s.buckets[key]
+	if bucket == nil {
+		return
+	}
+	bucket.refill(bucket.limit, now)
+	if requests != nil && float64(requests.Remaining) < bucket.requests {
+		bucket.requests = float64(requests.Remaining)
+	}
+	if tokens != nil && float64(tokens.Remaining) < bucket.tokens {
+		bucket.tokens = float64(tokens.Remaining)
+	}
+}
+
+// shapeableWindow returns w when it can be paced as a per-minute limit: its reset is unknown or
+// at most rateShapeWindow away.
+func shapeableWindow(w *QuotaWindow, now time.Time) *QuotaWindow {
+	if w == nil || (!w.ResetAt.IsZero() && w.ResetAt.Sub(now) > rateShapeWindow) {
+		return nil
+	}
+	return w
+}
+
+// snapshot returns the state of every bucket, sorted by auth and model.
+func (s *rateShaper) snapshot(now time.Time) []RateShapingStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RateShapingStat, 0, len(s.buckets))
+	for key, bucket := range s.buckets {
+		bucket.refill(bucket.limit, now)
+		_, learned := s.learned[key]
+		out = append(out, RateShapingStat{
+			AuthID:            key.authID,
+			Model:             key.model,
+			RequestsPerMinute: bucket.limit.RequestsPerMinute,
+			TokensPerMinute:   bucket.limit.TokensPerMinute,
+			Learned:           learned,
+			Requests:          bucket.requests,
+			Tokens:            bucket.tokens,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AuthID != out[j].AuthID {
+			return out[i].AuthID < out[j].AuthID
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// upstreamModelFor returns the model name auth sends upstream for the routed model, which is
+// the name rate limits are tracked under.
+func (m *Manager) upstreamModelFor(auth *Auth, model string) string {
+	upstream, _ := rewriteModelForAuth(model, nil, auth)
+	if mapped := m.resolveOAuthUpstreamModel(auth, upstream); mapped != "" {
+		return mapped
+	}
+	return upstream
+}
+
+// withRateLimitObserver returns a context whose upstream responses report the quota of authID:
+// it is recorded for the routed model and teaches the rate shaper the limits of the upstream
+// model.
+func (m *Manager) withRateLimitObserver(ctx context.Context, authID, routeModel, upstreamModel string) context.Context {
+	return cliproxyexecutor.WithResponseObserver(ctx, func(_ int, header http.Header) {
+		now := time.Now()
+		usage := ParseQuotaHeaders(header, now)
+		if usage == nil {
+			return
+		}
+		m.shaper.learn(authID, upstreamModel, usage, now)
+		m.recordQuotaUsage(authID, routeModel, usage)
+	})
+}
+
+// HandleUsage implements coreusage.Plugin by charging reported tokens to the rate shaper.
+func (m *Manager) HandleUsage(_ context.Context, record coreusage.Record) {
+	if m == nil || record.AuthID == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens <= 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	m.shaper.chargeTokens(record.AuthID, record.Model, tokens)
+}
+
+// RateShapingStats returns the token buckets the manager paces credentials with.
+func (m *Manager) RateShapingStats() []RateShapingStat {
+	if m == nil {
+		return nil
+	}
+	return m.shaper.snapshot(time.Now())
+}
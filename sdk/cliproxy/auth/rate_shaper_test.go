@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestManagerPickNext_SkipsShapedCredentials(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(&recordingExecutor{provider: "claude"})
+	for _, auth := range []*Auth{
+		{ID: "limited", Provider: "claude", Attributes: map[string]string{"rpm": "1"}},
+		{ID: "metered", Provider: "claude", Metadata: map[string]any{"tpm": float64(100)}},
+	} {
+		if _, err := m.Register(ctx, auth); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+
+	picked := make(map[string]int)
+	for i := 0; i < 3; i++ {
+		auth, _, err := m.pickNext(ctx, "claude", "", cliproxyexecutor.Options{}, map[string]struct{}{})
+		if err != nil {
+			t.Fatalf("pick %d: %v", i, err)
+		}
+		picked[auth.ID]++
+	}
+	if picked["limited"] != 1 || picked["metered"] != 2 {
+		t.Fatalf("expected one request on the 1 rpm credential, got %v", picked)
+	}
+
+	m.HandleUsage(ctx, coreusage.Record{AuthID: "metered", Detail: coreusage.Detail{TotalTokens: 150}})
+	if _, _, err := m.pickNext(ctx, "claude", "", cliproxyexecutor.Options{}, map[string]struct{}{}); !errors.Is(err, errRateShaped) {
+		t.Fatalf("expected every credential to be shaped, got %v", err)
+	}
+}
+
+func TestRateShaper_LearnsLimitsFromHeaders(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	auth := &Auth{ID: "a", Provider: "claude"}
+	if _, err := m.Register(ctx, auth); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	now := time.Now()
+	if m.shaper.blocked(auth, "claude-model", m.load, now) {
+		t.Fatal("credential without limits should not be shaped")
+	}
+
+	observe := cliproxyexecutor.ResponseObserverFromContext(m.withRateLimitObserver(ctx, "a", "claude-model", "claude-model"))
+	header := http.Header{}
+	header.Set("anthropic-ratelimit-requests-limit", "50")
+	observe(http.StatusOK, header)
+	m.shaper.take(auth, "claude-model", now)
+	header.Set("anthropic-ratelimit-requests-remaining", "0")
+	observe(http.StatusOK, header)
+	if !m.shaper.blocked(auth, "claude-model", m.load, now) {
+		t.Fatal("credential should be shaped once the provider reports no remaining requests")
+	}
+	if m.shaper.blocked(auth, "other-model", m.load, now) {
+		t.Fatal("learned limits should only apply to the model they were reported for")
+	}
+
+	stats := m.RateShapingStats()
+	if len(stats) != 1 || stats[0].Model != "claude-model" || stats[0].RequestsPerMinute != 50 || !stats[0].Learned {
+		t.Fatalf("unexpected rate shaping stats %+v", stats)
+	}
+}
+
+func TestRateShaper_SkipsWindowsLongerThanAMinute(t *testing.T) {
+	var s rateShaper
+	now := time.Now()
+	s.learn("a", "model", &QuotaUsage{
+		Requests: &QuotaWindow{Limit: 1000, Remaining: 900, ResetAt: now.Add(24 * time.Hour)},
+		Tokens:   &QuotaWindow{Limit: 40000, Remaining: 39000, ResetAt: now.Add(30 * time.Second)},
+	}, now)
+	learned := s.learned[shapeKey{authID: "a", model: "model"}]
+	if learned.RequestsPerMinute != 0 || learned.TokensPerMinute != 40000 {
+		t.Fatalf("expected only the per-minute token window to be learned, got %+v", learned)
+	}
+
+	s.learn("b", "model", &QuotaUsage{
+		Requests: &QuotaWindow{Limit: 1000, Remaining: 0, ResetAt: now.Add(time.Hour)},
+	}, now)
+	if _, ok := s.learned[shapeKey{authID: "b", model: "model"}]; ok {
+		t.Fatal("a window resetting in an hour should not be learned")
+	}
+}
+
+func TestAuthRateLimits(t *testing.T) {
+	auth := &Auth{
+		Attributes: map[string]string{"rpm": "10", "tpm:gemini-2.5-pro": "2000"},
+		Metadata: map[string]any{
+			"max_concurrent": float64(2),
+			"model_limits":   map[string]any{"gemini-2.5-pro": map[string]any{"rpm": float64(5), "tpm": float64(9000)}},
+		},
+	}
+	credential, perModel := auth.RateLimits("gemini-2.5-pro")
+	if credential.RequestsPerMinute != 10 || credential.MaxConcurrent != 2 {
+		t.Fatalf("unexpected credential limits %+v", credential)
+	}
+	if perModel.RequestsPerMinute != 5 || perModel.TokensPerMinute != 2000 {
+		t.Fatalf("unexpected model limits %+v", perModel)
+	}
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// refreshLeaseTTL bounds how long a replica holds the refresh lease of an auth, so a replica
+	// that dies mid-refresh does not block the credential forever.
+	refreshLeaseTTL = 2 * time.Minute
+	// refreshLeaseRetry is how long a replica waits before looking at an auth again while another
+	// replica refreshes it.
+	refreshLeaseRetry = 15 * time.Second
+)
+
+// errRefreshInProgress is returned when another replica holds the refresh lease of an auth.
+var errRefreshInProgress = &Error{Code: "refresh_in_progress", Message: "auth is being refreshed by another replica", Retryable: true}
+
+// RefreshLeaser is implemented by stores shared between replicas. It makes sure only one replica
+// refreshes a credential at a time; the others pick up the refreshed record from the store.
+type RefreshLeaser interface {
+	// AcquireRefreshLease takes the refresh lease of authID for at most ttl. It reports false
+	// without error when another replica holds the lease. release gives the lease back.
+	AcquireRefreshLease(ctx context.Context, authID string, ttl time.Duration) (release func(), ok bool, err error)
+	// LoadAuth reads the latest record of authID from the shared backend. It returns nil when
+	// the record does not exist.
+	LoadAuth(ctx context.Context, authID string) (*Auth, error)
+}
+
+// refreshAuth refreshes an auth, coordinating with other replicas when the store implements
+// RefreshLeaser. Without a lease the refresh is deferred; with one, a record another replica
+// refreshed in the meantime is adopted instead of refreshing again.
+func (m *Manager) refreshAuth(ctx context.Context, id string) error {
+	m.mu.RLock()
+	leaser, ok := m.store.(RefreshLeaser)
+	m.mu.RUnlock()
+	if !ok {
+		return m.runRefresh(ctx, id)
+	}
+	release, acquired, err := leaser.AcquireRefreshLease(ctx, id, refreshLeaseTTL)
+	if err != nil {
+		log.Warnf("refresh lease for %s unavailable, refreshing without it: %v", id, err)
+		return m.runRefresh(ctx, id)
+	}
+	if !acquired {
+		log.Debugf("auth %s is being refreshed by another replica", id)
+		m.mu.Lock()
+		if current := m.auths[id]; current != nil {
+			current.NextRefreshAfter = time.Now().Add(refreshLeaseRetry)
+		}
+		m.mu.Unlock()
+		return errRefreshInProgress
+	}
+	defer release()
+	adopted, err := m.adoptStoredAuth(ctx, leaser, id)
+	if err != nil {
+		log.Debugf("failed to reload auth %s before refresh: %v", id, err)
+	}
+	if adopted {
+		return nil
+	}
+	return m.runRefresh(ctx, id)
+}
+
+// adoptStoredAuth replaces the credential metadata of an auth with the stored record when
+// another replica changed it, so a following refresh starts from the latest refresh token. It
+// reports true when the adopted record needs no refresh.
+func (m *Manager) adoptStoredAuth(ctx context.Context, leaser RefreshLeaser, id string) (bool, error) {
+	stored, err := leaser.LoadAuth(ctx, id)
+	if err != nil || stored == nil || stored.Metadata == nil {
+		return false, err
+	}
+	m.mu.RLock()
+	current := m.auths[id]
+	if current != nil {
+		current = current.Clone()
+	}
+	m.mu.RUnlock()
+	if current == nil || metadataEqual(current.Metadata, stored.Metadata) {
+		return false, nil
+	}
+	now := time.Now()
+	current.Metadata = stored.Metadata
+	// The stored record supersedes the token storage of the login that created the auth.
+	current.Storage = nil
+	current.LastRefreshedAt = now
+	current.NextRefreshAfter = time.Time{}
+	current.UpdatedAt = now
+	needsRefresh := m.shouldRefresh(current, now)
+	if !needsRefresh {
+		current.LastError = nil
+	}
+	if _, err = m.Update(ctx, current); err != nil {
+		return false, err
+	}
+	log.Debugf("adopted auth %s refreshed by another replica", id)
+	return !needsRefresh, nil
+}
+
+func metadataEqual(a, b map[string]any) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && bytes.Equal(left, right)
+}
+
+// leaseFile is the content of a lock file written by AcquireLeaseFile.
+type leaseFile struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// leaseGuardTimeout is how long a takeover guard file may exist before it is considered left
+// behind by a crashed process.
+const leaseGuardTimeout = 10 * time.Second
+
+// AcquireLeaseFile takes a lease by exclusively creating the lock file at path. It reports false
+// while another holder's lease has not expired. An expired lock file is taken over while holding
+// a guard file, by renaming a fresh lock file over it and reading the owner back. release
+// removes the lock file unless it was taken over in the meantime.
+func AcquireLeaseFile(path string, ttl time.Duration) (release func(), ok bool, err error) {
+	token := make([]byte, 8)
+	if _, err = rand.Read(token); err != nil {
+		return nil, false, fmt.Errorf("generate lease owner: %w", err)
+	}
+	owner := hex.EncodeToString(token)
+	now := time.Now()
+	data, err := json.Marshal(leaseFile{Owner: owner, ExpiresAt: now.Add(ttl)})
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal lease file: %w", err)
+	}
+	releaseFunc := func() { releaseLeaseFile(path, owner) }
+
+	file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errOpen == nil {
+		_, errWrite := file.Write(data)
+		if errClose := file.Close(); errWrite == nil {
+			errWrite = errClose
+		}
+		if errWrite != nil {
+			_ = os.Remove(path)
+			return nil, false, fmt.Errorf("write lease file: %w", errWrite)
+		}
+		return releaseFunc, true, nil
+	}
+	if !os.IsExist(errOpen) {
+		return nil, false, fmt.Errorf("create lease file: %w", errOpen)
+	}
+	if !leaseFileExpired(path, ttl, now) {
+		return nil, false, nil
+	}
+
+	tookOver := false
+	guarded, err := withLeaseGuard(path, func() error {
+		// Another replica may have taken the lease over while the guard was contended.
+		if !leaseFileExpired(path, ttl, time.Now()) {
+			return nil
+		}
+		tmp := path + "." + owner + ".tmp"
+		if errWrite := os.WriteFile(tmp, data, 0o600); errWrite != nil {
+			return fmt.Errorf("write lease file: %w", errWrite)
+		}
+		if errRename := os.Rename(tmp, path); errRename != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("replace expired lease file: %w", errRename)
+		}
+		tookOver = true
+		return nil
+	})
+	if err != nil || !guarded || !tookOver {
+		return nil, false, err
+	}
+	if leaseFileOwner(path) != owner {
+		return nil, false, nil
+	}
+	return releaseFunc, true, nil
+}
+
+// withLeaseGuard runs fn while exclusively holding the guard file next to the lock file at path.
+// It reports false without running fn when another process holds the guard. A guard older than
+// leaseGuardTimeout is assumed to be left behind by a crashed process and is removed.
+func withLeaseGuard(path string, fn func() error) (bool, error) {
+	guard := path + ".guard"
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_ = file.Close()
+			defer func() { _ = os.Remove(guard) }()
+			return true, fn()
+		}
+		if !os.IsExist(err) {
+			return false, fmt.Errorf("create lease guard: %w", err)
+		}
+		info, errStat := os.Stat(guard)
+		if attempt > 0 || errStat != nil || time.Since(info.ModTime()) <= leaseGuardTimeout {
+			return false, nil
+		}
+		_ = os.Remove(guard)
+	}
+	return false, nil
+}
+
+// leaseFileExpired reports whether the lease in the lock file at path ended before now. A lock
+// file that cannot be parsed yet, because its holder is still writing it, counts from its
+// modification time.
+func leaseFileExpired(path string, ttl time.Duration, now time.Time) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	var lease leaseFile
+	if err = json.Unmarshal(data, &lease); err == nil && !lease.ExpiresAt.IsZero() {
+		return now.After(lease.ExpiresAt)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return now.Sub(info.ModTime()) > ttl
+}
+
+// leaseFileOwner returns the owner named in the lock file at path, or "" when it cannot be read.
+func leaseFileOwner(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var lease leaseFile
+	if err = json.Unmarshal(data, &lease); err != nil {
+		return ""
+	}
+	return lease.Owner
+}
+
+// releaseLeaseFile removes the lock file at path if owner still holds it. The check and removal
+// run under the takeover guard so a replica taking over an expired lease is never undone; when
+// the guard stays contended the lease is left to expire.
+func releaseLeaseFile(path, owner string) {
+	for attempt := 0; attempt < 5; attempt++ {
+		guarded, _ := withLeaseGuard(path, func() error {
+			if leaseFileOwner(path) == owner {
+				_ = os.Remove(path)
+			}
+			return nil
+		})
+		if guarded {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// leaseStore is a shared store whose refresh lease is held by another replica while held is set.
+type leaseStore struct {
+	*memoryStateStore
+	held bool
+}
+
+func (s *leaseStore) AcquireRefreshLease(context.Context, string, time.Duration) (func(), bool, error) {
+	if s.held {
+		return nil, false, nil
+	}
+	return func() {}, true, nil
+}
+
+func (s *leaseStore) LoadAuth(_ context.Context, id string) (*Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if auth := s.auths[id]; auth != nil {
+		return auth.Clone(), nil
+	}
+	return nil, nil
+}
+
+type refreshExecutor struct {
+	refreshes atomic.Int32
+}
+
+func (e *refreshExecutor) Identifier() string { return "claude" }
+
+func (e *refreshExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *refreshExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (e *refreshExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	e.refreshes.Add(1)
+	auth.Metadata = map[string]any{"type": "claude", "access_token": "local"}
+	return auth, nil
+}
+
+func (e *refreshExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func TestManagerRefreshAuth_CoordinatesWithLease(t *testing.T) {
+	ctx := context.Background()
+	store := &leaseStore{memoryStateStore: &memoryStateStore{auths: make(map[string]*Auth)}}
+	exec := &refreshExecutor{}
+	m := NewManager(store, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(exec)
+	if _, err := m.Register(ctx, &Auth{ID: "a", Provider: "claude", Metadata: map[string]any{"type": "claude", "access_token": "old"}}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	store.held = true
+	if err := m.refreshAuth(ctx, "a"); !errors.Is(err, errRefreshInProgress) {
+		t.Fatalf("expected refresh in progress, got %v", err)
+	}
+	if auth, _ := m.GetByID("a"); exec.refreshes.Load() != 0 || auth.NextRefreshAfter.IsZero() {
+		t.Fatalf("held lease should defer the refresh, refreshes=%d next=%v", exec.refreshes.Load(), auth.NextRefreshAfter)
+	}
+
+	// Another replica refreshed the token and saved it while holding the lease.
+	store.held = false
+	store.auths["a"] = &Auth{ID: "a", Provider: "claude", Metadata: map[string]any{"type": "claude", "access_token": "remote"}}
+	if err := m.refreshAuth(ctx, "a"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	auth, _ := m.GetByID("a")
+	if exec.refreshes.Load() != 0 || auth.Metadata["access_token"] != "remote" {
+		t.Fatalf("expected the stored token to be adopted, refreshes=%d metadata=%v", exec.refreshes.Load(), auth.Metadata)
+	}
+
+	if err := m.refreshAuth(ctx, "a"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if auth, _ = m.GetByID("a"); exec.refreshes.Load() != 1 || auth.Metadata["access_token"] != "local" {
+		t.Fatalf("unchanged stored record should be refreshed locally, refreshes=%d metadata=%v", exec.refreshes.Load(), auth.Metadata)
+	}
+}
+
+func TestAcquireLeaseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".refresh-a.lock")
+	release, ok, err := AcquireLeaseFile(path, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first acquire: ok=%v err=%v", ok, err)
+	}
+	if _, ok, err = AcquireLeaseFile(path, time.Minute); err != nil || ok {
+		t.Fatalf("second acquire should fail while held: ok=%v err=%v", ok, err)
+	}
+	release()
+	if _, ok, err = AcquireLeaseFile(path, -time.Second); err != nil || !ok {
+		t.Fatalf("acquire after release: ok=%v err=%v", ok, err)
+	}
+	// The previous lease already expired, so it is taken over.
+	if _, ok, err = AcquireLeaseFile(path, time.Minute); err != nil || !ok {
+		t.Fatalf("expired lease should be taken over: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestAcquireLeaseFile_SingleTakeoverOfExpiredLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".refresh-a.lock")
+	if _, ok, err := AcquireLeaseFile(path, -time.Second); err != nil || !ok {
+		t.Fatalf("seed expired lease: ok=%v err=%v", ok, err)
+	}
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := AcquireLeaseFile(path, time.Minute); err == nil && ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := acquired.Load(); got != 1 {
+		t.Fatalf("expired lease taken over %d times, want 1", got)
+	}
+}
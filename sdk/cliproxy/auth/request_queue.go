@@ -0,0 +1,454 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	defaultRequestQueueDepth   = 100
+	defaultRequestQueueTimeout = time.Minute
+	// requestQueueRecheckInterval bounds how long parked requests wait without a wake-up, so
+	// recoveries the manager is not told about are noticed as well.
+	requestQueueRecheckInterval = 5 * time.Second
+)
+
+var (
+	errRequestQueueFull    = errors.New("request queue is full")
+	errRequestQueueTimeout = errors.New("request queue timeout")
+)
+
+// RequestQueueStats summarizes the request queue for the management API.
+type RequestQueueStats struct {
+	Enabled    bool                      `json:"enabled"`
+	Depth      int                       `json:"depth"`
+	MaxDepth   int                       `json:"max_depth"`
+	Clients    []RequestQueueClientStats `json:"clients,omitempty"`
+	Queued     int64                     `json:"queued"`
+	Dispatched int64                     `json:"dispatched"`
+	TimedOut   int64                     `json:"timed_out"`
+	Rejected   int64                     `json:"rejected"`
+	Canceled   int64                     `json:"canceled"`
+	AvgWaitMs  int64                     `json:"avg_wait_ms"`
+	MaxWaitMs  int64                     `json:"max_wait_ms"`
+}
+
+// RequestQueueClientStats reports the requests one client has waiting.
+type RequestQueueClientStats struct {
+	Client       string `json:"client"`
+	Depth        int    `json:"depth"`
+	OldestWaitMs int64  `json:"oldest_wait_ms"`
+}
+
+// requestQueue parks requests while every credential of their model is cooling down. Waiting
+// requests are kept per client and released round-robin across clients, at most one per
+// available credential and dispatch round.
+type requestQueue struct {
+	mu      sync.Mutex
+	cfg     internalconfig.RequestQueueConfig
+	clients map[string][]*queuedRequest
+	order   []string
+	cursor  int
+	depth   int
+	running bool
+	wake    chan struct{}
+
+	queued, dispatched, timedOut, rejected, canceled int64
+	totalWait, maxWait                               time.Duration
+}
+
+type queuedRequest struct {
+	client    string
+	providers []string
+	model     string
+	enqueued  time.Time
+	ready     chan struct{}
+	released  bool
+}
+
+// SetRequestQueueConfig applies the request queue settings. Requests already waiting keep
+// their deadline.
+func (m *Manager) SetRequestQueueConfig(cfg internalconfig.RequestQueueConfig) {
+	if m == nil {
+		return
+	}
+	m.queue.mu.Lock()
+	m.queue.cfg = cfg
+	m.queue.mu.Unlock()
+	m.queue.kick()
+}
+
+// RequestQueueStats returns the current depth, per-client backlog and wait statistics of the
+// request queue.
+func (m *Manager) RequestQueueStats() RequestQueueStats {
+	if m == nil {
+		return RequestQueueStats{}
+	}
+	q := &m.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	maxDepth, _ := q.limitsLocked()
+	stats := RequestQueueStats{
+		Enabled:    q.cfg.Enabled,
+		Depth:      q.depth,
+		MaxDepth:   maxDepth,
+		Queued:     q.queued,
+		Dispatched: q.dispatched,
+		TimedOut:   q.timedOut,
+		Rejected:   q.rejected,
+		Canceled:   q.canceled,
+		MaxWaitMs:  q.maxWait.Milliseconds(),
+	}
+	if q.dispatched > 0 {
+		stats.AvgWaitMs = (q.totalWait / time.Duration(q.dispatched)).Milliseconds()
+	}
+	now := time.Now()
+	for client, entries := range q.clients {
+		if len(entries) == 0 {
+			continue
+		}
+		name := "anonymous"
+		if client != "" {
+			name = util.HideAPIKey(client)
+		}
+		stats.Clients = append(stats.Clients, RequestQueueClientStats{
+			Client:       name,
+			Depth:        len(entries),
+			OldestWaitMs: now.Sub(entries[0].enqueued).Milliseconds(),
+		})
+	}
+	sort.Slice(stats.Clients, func(i, j int) bool { return stats.Clients[i].Client < stats.Clients[j].Client })
+	return stats
+}
+
+// queueWhileCoolingDown re-runs run as long as err says that every credential of the model is
+// cooling down, parking the request in the queue in between. When the request may not be
+// queued, the queue is full or the queue timeout passes, the last error is returned as is.
+func (m *Manager) queueWhileCoolingDown(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, err error, run func() error) error {
+	if !m.allCoolingDown(err, providers, model) {
+		return err
+	}
+	q := &m.queue
+	q.mu.Lock()
+	cfg := q.cfg
+	q.mu.Unlock()
+	if !queueRequested(cfg, opts.Metadata) {
+		return err
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultRequestQueueTimeout
+	}
+	entry := &queuedRequest{
+		client:    clientKeyFromOptions(opts.Metadata),
+		providers: m.normalizeProviders(providers),
+		model:     model,
+		enqueued:  time.Now(),
+	}
+	deadline := entry.enqueued.Add(timeout)
+	for first := true; m.allCoolingDown(err, providers, model); first = false {
+		if errWait := m.parkRequest(ctx, entry, deadline, first); errWait != nil {
+			if errors.Is(errWait, errRequestQueueFull) || errors.Is(errWait, errRequestQueueTimeout) {
+				logEntryWithRequestID(ctx).Debugf("request for %s not queued further: %v", model, errWait)
+				return err
+			}
+			return errWait
+		}
+		err = run()
+	}
+	q.recordDispatch(time.Since(entry.enqueued))
+	return err
+}
+
+// allCoolingDown reports whether err is a rate limit and no credential of the model is usable.
+func (m *Manager) allCoolingDown(err error, providers []string, model string) bool {
+	if err == nil || statusCodeFromError(err) != http.StatusTooManyRequests {
+		return false
+	}
+	return m.availableAuthCount(m.normalizeProviders(providers), model, time.Now()) == 0
+}
+
+// availableAuthCount counts the credentials of providers that can serve model right now.
+func (m *Manager) availableAuthCount(providers []string, model string, now time.Time) int {
+	providerSet := make(map[string]struct{}, len(providers))
+	for _, provider := range providers {
+		providerSet[provider] = struct{}{}
+	}
+	modelKey := strings.TrimSpace(model)
+	registryRef := registry.GetGlobalRegistry()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	count := 0
+	for _, auth := range m.auths {
+		if _, ok := providerSet[auth.Provider]; !ok || auth.Disabled {
+			continue
+		}
+		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(auth.ID, modelKey) {
+			continue
+		}
+		if blocked, _, _ := isAuthBlockedForModel(auth, model, now); blocked {
+			continue
+		}
+		if m.breakers.Blocked(auth.ID, modelKey, now) {
+			continue
+		}
+		if m.shaper.blocked(auth, m.upstreamModelFor(auth, modelKey), m.load, now) {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+func queueRequested(cfg internalconfig.RequestQueueConfig, meta map[string]any) bool {
+	if !cfg.Enabled {
+		return false
+	}
+	raw, _ := meta[cliproxyexecutor.RequestQueueMetadataKey].(string)
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	}
+	return !cfg.OptIn
+}
+
+func clientKeyFromOptions(meta map[string]any) string {
+	key, _ := meta[cliproxyexecutor.ClientKeyMetadataKey].(string)
+	return strings.TrimSpace(key)
+}
+
+// limitsLocked returns the total and per-client depth limits. q.mu must be held.
+func (q *requestQueue) limitsLocked() (int, int) {
+	maxDepth := q.cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultRequestQueueDepth
+	}
+	perClient := q.cfg.MaxPerClient
+	if perClient <= 0 || perClient > maxDepth {
+		perClient = maxDepth
+	}
+	return maxDepth, perClient
+}
+
+// parkRequest waits until the dispatcher releases entry, the deadline passes or ctx ends. A
+// request coming back after a release is put at the head of its client's line and is not
+// subject to the depth limits again.
+func (m *Manager) parkRequest(ctx context.Context, entry *queuedRequest, deadline time.Time, first bool) error {
+	q := &m.queue
+	wait := time.Until(deadline)
+	q.mu.Lock()
+	if wait <= 0 {
+		q.timedOut++
+		q.mu.Unlock()
+		return errRequestQueueTimeout
+	}
+	if first {
+		maxDepth, perClient := q.limitsLocked()
+		if q.depth >= maxDepth || len(q.clients[entry.client]) >= perClient {
+			q.rejected++
+			q.mu.Unlock()
+			return errRequestQueueFull
+		}
+		q.queued++
+	}
+	entry.ready = make(chan struct{})
+	entry.released = false
+	if q.clients == nil {
+		q.clients = make(map[string][]*queuedRequest)
+	}
+	line := q.clients[entry.client]
+	if len(line) == 0 {
+		q.order = append(q.order, entry.client)
+	}
+	if first {
+		q.clients[entry.client] = append(line, entry)
+	} else {
+		q.clients[entry.client] = append([]*queuedRequest{entry}, line...)
+	}
+	q.depth++
+	startDispatcher := !q.running
+	q.running = true
+	q.mu.Unlock()
+	if startDispatcher {
+		go m.dispatchQueuedRequests()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-entry.ready:
+		return nil
+	case <-ctx.Done():
+		if q.withdraw(entry, false) {
+			return nil
+		}
+		return ctx.Err()
+	case <-timer.C:
+		if q.withdraw(entry, true) {
+			return nil
+		}
+		return errRequestQueueTimeout
+	}
+}
+
+// withdraw removes an entry that stopped waiting. It reports true when the dispatcher released
+// the entry in the meantime, in which case the request should run after all.
+func (q *requestQueue) withdraw(entry *queuedRequest, timedOut bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if entry.released {
+		return true
+	}
+	q.removeLocked(entry)
+	if timedOut {
+		q.timedOut++
+	} else {
+		q.canceled++
+	}
+	return false
+}
+
+func (q *requestQueue) removeLocked(entry *queuedRequest) {
+	line := q.clients[entry.client]
+	for i, candidate := range line {
+		if candidate != entry {
+			continue
+		}
+		line = append(line[:i:i], line[i+1:]...)
+		q.depth--
+		break
+	}
+	if len(line) > 0 {
+		q.clients[entry.client] = line
+		return
+	}
+	delete(q.clients, entry.client)
+	for i, client := range q.order {
+		if client == entry.client {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			if q.cursor > i {
+				q.cursor--
+			}
+			break
+		}
+	}
+}
+
+func (q *requestQueue) recordDispatch(wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dispatched++
+	q.totalWait += wait
+	if wait > q.maxWait {
+		q.maxWait = wait
+	}
+}
+
+// kick wakes the dispatcher after credential state changed.
+func (q *requestQueue) kick() {
+	q.mu.Lock()
+	if q.wake == nil {
+		q.wake = make(chan struct{}, 1)
+	}
+	wake := q.wake
+	q.mu.Unlock()
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// fairOrderLocked lists the waiting entries round-robin across clients, starting with a
+// different client every round. q.mu must be held.
+func (q *requestQueue) fairOrderLocked() []*queuedRequest {
+	out := make([]*queuedRequest, 0, q.depth)
+	if len(q.order) == 0 {
+		return out
+	}
+	start := q.cursor % len(q.order)
+	q.cursor = start + 1
+	for index := 0; len(out) < q.depth; index++ {
+		added := false
+		for i := range q.order {
+			line := q.clients[q.order[(start+i)%len(q.order)]]
+			if index < len(line) {
+				out = append(out, line[index])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return out
+}
+
+// dispatchQueuedRequests releases waiting requests whose model has a usable credential again.
+// It runs while the queue is not empty.
+func (m *Manager) dispatchQueuedRequests() {
+	q := &m.queue
+	for {
+		q.mu.Lock()
+		if q.depth == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		if q.wake == nil {
+			q.wake = make(chan struct{}, 1)
+		}
+		wake := q.wake
+		entries := q.fairOrderLocked()
+		q.mu.Unlock()
+
+		now := time.Now()
+		next := requestQueueRecheckInterval
+		slots := make(map[string]int)
+		var release []*queuedRequest
+		for _, entry := range entries {
+			key := entry.model + "|" + strings.Join(entry.providers, ",")
+			free, ok := slots[key]
+			if !ok {
+				free = m.availableAuthCount(entry.providers, entry.model, now)
+			}
+			if free > 0 {
+				release = append(release, entry)
+				free--
+			} else if wait, found := m.closestCooldownWait(entry.providers, entry.model); found && wait < next {
+				next = wait
+			}
+			slots[key] = free
+		}
+
+		q.mu.Lock()
+		for _, entry := range release {
+			if entry.released {
+				continue
+			}
+			entry.released = true
+			q.removeLocked(entry)
+			close(entry.ready)
+		}
+		q.mu.Unlock()
+
+		// Small margin so that a cooldown ending at next has really passed when re-checked.
+		timer := time.NewTimer(next + 10*time.Millisecond)
+		select {
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
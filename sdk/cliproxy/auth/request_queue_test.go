@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestManagerExecute_QueuesWhileCoolingDown(t *testing.T) {
+	const model = "queue-test-model"
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("queue-test-auth", "claude", []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { reg.UnregisterClient("queue-test-auth") })
+
+	m := NewManager(nil, &RoundRobinSelector{}, NoopHook{})
+	m.RegisterExecutor(&recordingExecutor{provider: "claude"})
+	m.SetRequestQueueConfig(internalconfig.RequestQueueConfig{Enabled: true, TimeoutSeconds: 5})
+	coolDown := func(d time.Duration) {
+		recoverAt := time.Now().Add(d)
+		if _, err := m.Update(context.Background(), &Auth{ID: "queue-test-auth", Provider: "claude", Status: StatusError, ModelStates: map[string]*ModelState{
+			model: {Status: StatusError, Unavailable: true, NextRetryAfter: recoverAt, Quota: QuotaState{Exceeded: true, NextRecoverAt: recoverAt}},
+		}}); err != nil {
+			t.Fatalf("update auth: %v", err)
+		}
+	}
+	req := cliproxyexecutor.Request{Model: model}
+
+	coolDown(time.Minute)
+	optOut := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.RequestQueueMetadataKey: "false"}}
+	if _, err := m.Execute(context.Background(), []string{"claude"}, req, optOut); statusCodeFromError(err) != http.StatusTooManyRequests {
+		t.Fatalf("opted-out request should fail immediately with 429, got %v", err)
+	}
+
+	coolDown(300 * time.Millisecond)
+	start := time.Now()
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.ClientKeyMetadataKey: "client-key"}}
+	resp, err := m.Execute(context.Background(), []string{"claude"}, req, opts)
+	if err != nil {
+		t.Fatalf("queued request failed: %v", err)
+	}
+	if string(resp.Payload) != "ok" || time.Since(start) < 250*time.Millisecond {
+		t.Fatalf("expected the request to wait for the cooldown, payload=%q waited=%v", resp.Payload, time.Since(start))
+	}
+	stats := m.RequestQueueStats()
+	if stats.Queued != 1 || stats.Dispatched != 1 || stats.Depth != 0 || stats.MaxWaitMs < 250 {
+		t.Fatalf("unexpected queue stats %+v", stats)
+	}
+
+	m.SetRequestQueueConfig(internalconfig.RequestQueueConfig{Enabled: true, MaxDepth: 1, TimeoutSeconds: 5})
+	coolDown(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, errExec := m.Execute(ctx, []string{"claude"}, req, opts)
+		done <- errExec
+	}()
+	waitFor(t, "queued request", func() bool { return m.RequestQueueStats().Depth == 1 })
+	if _, err = m.Execute(context.Background(), []string{"claude"}, req, opts); statusCodeFromError(err) != http.StatusTooManyRequests {
+		t.Fatalf("full queue should reject with 429, got %v", err)
+	}
+	cancel()
+	if errExec := <-done; errExec == nil {
+		t.Fatal("canceled queued request should fail")
+	}
+	if stats = m.RequestQueueStats(); stats.Rejected != 1 || stats.Canceled != 1 || stats.Depth != 0 {
+		t.Fatalf("unexpected queue stats %+v", stats)
+	}
+}
+
+func TestRequestQueue_FairOrder(t *testing.T) {
+	q := &requestQueue{}
+	add := func(client string) *queuedRequest {
+		entry := &queuedRequest{client: client}
+		if q.clients == nil {
+			q.clients = make(map[string][]*queuedRequest)
+		}
+		if len(q.clients[client]) == 0 {
+			q.order = append(q.order, client)
+		}
+		q.clients[client] = append(q.clients[client], entry)
+		q.depth++
+		return entry
+	}
+	a1, a2, a3 := add("a"), add("a"), add("a")
+	b1 := add("b")
+	got := q.fairOrderLocked()
+	want := []*queuedRequest{a1, b1, a2, a3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("position %d: got client %s, want %s", i, got[i].client, want[i].client)
+		}
+	}
+	if next := q.fairOrderLocked(); next[0] != b1 {
+		t.Fatalf("next round should start with the other client, got %s", next[0].client)
+	}
+}
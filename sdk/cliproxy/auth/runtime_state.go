@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RuntimeState is the part of an auth's availability state that survives restarts: cooldowns,
+// quota backoff and per-model states. It is stored apart from the credential record so token
+// files only ever contain token metadata.
+type RuntimeState struct {
+	Status         Status                 `json:"status,omitempty"`
+	StatusMessage  string                 `json:"status_message,omitempty"`
+	Unavailable    bool                   `json:"unavailable,omitempty"`
+	NextRetryAfter time.Time              `json:"next_retry_after"`
+	Quota          QuotaState             `json:"quota"`
+	LastError      *Error                 `json:"last_error,omitempty"`
+	ModelStates    map[string]*ModelState `json:"model_states,omitempty"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// runtimeStateOf extracts the cooldowns of auth that are still running at now. It returns nil
+// when nothing is worth persisting.
+func runtimeStateOf(auth *Auth, now time.Time) *RuntimeState {
+	if auth == nil {
+		return nil
+	}
+	state := &RuntimeState{UpdatedAt: auth.UpdatedAt}
+	if auth.Unavailable && auth.NextRetryAfter.After(now) {
+		state.Unavailable = true
+		state.NextRetryAfter = auth.NextRetryAfter
+	}
+	if auth.Quota.Exceeded && auth.Quota.NextRecoverAt.After(now) {
+		state.Quota = auth.Quota
+	}
+	for model, ms := range auth.ModelStates {
+		if !modelCooldownActive(ms, now) {
+			continue
+		}
+		if state.ModelStates == nil {
+			state.ModelStates = make(map[string]*ModelState)
+		}
+		state.ModelStates[model] = ms.Clone()
+	}
+	if !state.Unavailable && !state.Quota.Exceeded && len(state.ModelStates) == 0 {
+		return nil
+	}
+	state.Status = auth.Status
+	state.StatusMessage = auth.StatusMessage
+	state.LastError = cloneError(auth.LastError)
+	return state
+}
+
+// modelCooldownActive reports whether state blocks its model for a bounded period that has not
+// ended yet. Open-ended errors are not persisted; they clear with the next request anyway.
+func modelCooldownActive(state *ModelState, now time.Time) bool {
+	if state == nil {
+		return false
+	}
+	return (state.Unavailable && state.NextRetryAfter.After(now)) ||
+		(state.Quota.Exceeded && state.Quota.NextRecoverAt.After(now))
+}
+
+// Expired reports whether every cooldown in the state ended before now.
+func (s *RuntimeState) Expired(now time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if s.Unavailable && s.NextRetryAfter.After(now) {
+		return false
+	}
+	if s.Quota.Exceeded && s.Quota.NextRecoverAt.After(now) {
+		return false
+	}
+	for _, ms := range s.ModelStates {
+		if modelCooldownActive(ms, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyRuntimeState restores the cooldowns in state onto auth, skipping anything that expired
+// while the proxy was down and models the auth already tracks. It reports whether anything was
+// restored.
+func applyRuntimeState(auth *Auth, state *RuntimeState, now time.Time) bool {
+	if auth == nil || state == nil || auth.Disabled {
+		return false
+	}
+	restored := false
+	for model, ms := range state.ModelStates {
+		if !modelCooldownActive(ms, now) {
+			continue
+		}
+		if _, exists := auth.ModelStates[model]; exists {
+			continue
+		}
+		if auth.ModelStates == nil {
+			auth.ModelStates = make(map[string]*ModelState)
+		}
+		auth.ModelStates[model] = ms.Clone()
+		restored = true
+	}
+	if state.Unavailable && state.NextRetryAfter.After(now) && !auth.Unavailable {
+		auth.Unavailable = true
+		auth.NextRetryAfter = state.NextRetryAfter
+		restored = true
+	}
+	if state.Quota.Exceeded && state.Quota.NextRecoverAt.After(now) && !auth.Quota.Exceeded {
+		auth.Quota = state.Quota
+		restored = true
+	}
+	if !restored {
+		return false
+	}
+	updateAggregatedAvailability(auth, now)
+	auth.Status = StatusError
+	auth.StatusMessage = state.StatusMessage
+	auth.LastError = cloneError(state.LastError)
+	return true
+}
+
+// restoreRuntimeStateLocked applies the runtime state restored by Load to auth, once. m.mu must be held.
+func (m *Manager) restoreRuntimeStateLocked(auth *Auth, now time.Time) {
+	if auth == nil || len(m.restored) == 0 {
+		return
+	}
+	state, ok := m.restored[auth.ID]
+	if !ok {
+		return
+	}
+	delete(m.restored, auth.ID)
+	applyRuntimeState(auth, state, now)
+}
+
+// SaveRuntimeState writes the running cooldowns of all auths to the store when it implements
+// RuntimeStateStore and the state changed since the last save.
+func (m *Manager) SaveRuntimeState(ctx context.Context) error {
+	if m == nil || !m.runtimeDirty.Swap(false) {
+		return nil
+	}
+	m.mu.RLock()
+	stateStore, ok := m.store.(RuntimeStateStore)
+	if !ok {
+		m.mu.RUnlock()
+		return nil
+	}
+	now := time.Now()
+	states := make(map[string]*RuntimeState)
+	for id, auth := range m.auths {
+		if state := runtimeStateOf(auth, now); state != nil {
+			states[id] = state
+		}
+	}
+	m.mu.RUnlock()
+	if err := stateStore.SaveRuntimeState(ctx, states); err != nil {
+		m.runtimeDirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+// ReadRuntimeStateFile reads runtime states written by WriteRuntimeStateFile. A missing file
+// yields no states.
+func ReadRuntimeStateFile(path string) (map[string]*RuntimeState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read runtime state: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	states := make(map[string]*RuntimeState)
+	if err = json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("unmarshal runtime state: %w", err)
+	}
+	return states, nil
+}
+
+// WriteRuntimeStateFile atomically replaces the runtime state file at path. Empty states
+// remove the file.
+func WriteRuntimeStateFile(path string, states map[string]*RuntimeState) error {
+	if len(states) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove runtime state: %w", err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("marshal runtime state: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create runtime state dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write runtime state: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename runtime state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStateStore struct {
+	mu     sync.Mutex
+	auths  map[string]*Auth
+	states map[string]*RuntimeState
+	saves  int
+}
+
+func (s *memoryStateStore) List(context.Context) ([]*Auth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Auth, 0, len(s.auths))
+	for _, auth := range s.auths {
+		out = append(out, auth.Clone())
+	}
+	return out, nil
+}
+
+func (s *memoryStateStore) Save(_ context.Context, auth *Auth) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Like the real stores, only credential data is kept.
+	s.auths[auth.ID] = &Auth{ID: auth.ID, Provider: auth.Provider, Status: StatusActive, Metadata: auth.Metadata}
+	return auth.ID, nil
+}
+
+func (s *memoryStateStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.auths, id)
+	return nil
+}
+
+func (s *memoryStateStore) LoadRuntimeState(context.Context) (map[string]*RuntimeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states, nil
+}
+
+func (s *memoryStateStore) SaveRuntimeState(_ context.Context, states map[string]*RuntimeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = states
+	s.saves++
+	return nil
+}
+
+func TestManagerRuntimeState_SurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStateStore{auths: make(map[string]*Auth)}
+	m := NewManager(store, &RoundRobinSelector{}, NoopHook{})
+	metadata := map[string]any{"type": "claude"}
+	if _, err := m.Register(ctx, &Auth{ID: "a", Provider: "claude", Metadata: metadata}); err != nil {
+		t.Fatalf("register a: %v", err)
+	}
+	if _, err := m.Register(ctx, &Auth{ID: "b", Provider: "claude", Metadata: metadata}); err != nil {
+		t.Fatalf("register b: %v", err)
+	}
+
+	retryAfter := 10 * time.Minute
+	m.MarkResult(ctx, Result{AuthID: "a", Provider: "claude", Model: "m", Error: &Error{Message: "rate limited", HTTPStatus: http.StatusTooManyRequests}, RetryAfter: &retryAfter})
+	m.MarkResult(ctx, Result{AuthID: "b", Provider: "claude", Model: "m", Success: true})
+	if err := m.SaveRuntimeState(ctx); err != nil {
+		t.Fatalf("save runtime state: %v", err)
+	}
+	if err := m.SaveRuntimeState(ctx); err != nil || store.saves != 1 {
+		t.Fatalf("unchanged state should not be saved again, saves=%d err=%v", store.saves, err)
+	}
+	if len(store.states) != 1 || store.states["a"] == nil || store.states["a"].ModelStates["m"].Quota.BackoffLevel != 0 {
+		t.Fatalf("expected only auth a to be saved, got %+v", store.states)
+	}
+	// An entry that already expired must be dropped on load.
+	store.states["b"] = &RuntimeState{Unavailable: true, NextRetryAfter: time.Now().Add(-time.Minute)}
+
+	restarted := NewManager(store, &RoundRobinSelector{}, NoopHook{})
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	auth, _ := restarted.GetByID("a")
+	state := auth.ModelStates["m"]
+	if state == nil || !state.Unavailable || !state.Quota.Exceeded || auth.Status != StatusError {
+		t.Fatalf("expected restored cooldown on a, got %+v", state)
+	}
+	if auth, _ = restarted.GetByID("b"); len(auth.ModelStates) != 0 || auth.Unavailable {
+		t.Fatalf("expired state should not be restored on b, got %+v", auth)
+	}
+
+	// The synthesized auth registered after Load for the same file keeps the cooldown.
+	if _, err := restarted.Update(ctx, &Auth{ID: "a", Provider: "claude", Status: StatusActive, Metadata: metadata}); err != nil {
+		t.Fatalf("update a: %v", err)
+	}
+	if auth, _ = restarted.GetByID("a"); auth.ModelStates["m"] == nil || !auth.ModelStates["m"].Unavailable {
+		t.Fatalf("cooldown lost when the auth was re-registered, got %+v", auth.ModelStates)
+	}
+}
@@ -8,12 +8,50 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 )
 
+// Routing strategy names accepted by routing.strategy.
+const (
+	StrategyRoundRobin = "round-robin"
+	StrategyFillFirst  = "fill-first"
+	StrategyWeighted   = "weighted"
+	StrategyLeastLoad  = "least-load"
+)
+
+// NormalizeStrategy maps a routing.strategy value, including its aliases, to a canonical
+// strategy name. Unknown values fall back to round-robin.
+func NormalizeStrategy(strategy string) string {
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case "fill-first", "fillfirst", "ff":
+		return StrategyFillFirst
+	case "weighted", "priority", "weighted-priority":
+		return StrategyWeighted
+	case "least-load", "least-outstanding", "latency":
+		return StrategyLeastLoad
+	default:
+		return StrategyRoundRobin
+	}
+}
+
+// NewSelector returns a selector implementing the given routing strategy.
+func NewSelector(strategy string) Selector {
+	switch NormalizeStrategy(strategy) {
+	case StrategyFillFirst:
+		return &FillFirstSelector{}
+	case StrategyWeighted:
+		return &WeightedSelector{}
+	case StrategyLeastLoad:
+		return &LeastLoadSelector{}
+	default:
+		return &RoundRobinSelector{}
+	}
+}
+
 // RoundRobinSelector provides a simple provider scoped round-robin selection strategy.
 type RoundRobinSelector struct {
 	mu      sync.Mutex
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 )
@@ -111,3 +112,160 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestWeightedSelectorPick_PrefersTierAndWeights(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "low", Attributes: map[string]string{"priority": "1"}},
+		{ID: "heavy", Attributes: map[string]string{"priority": "5", "weight": "3"}},
+		{ID: "light", Metadata: map[string]any{"priority": float64(5)}},
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		counts[got.ID]++
+	}
+	if counts["low"] != 0 {
+		t.Fatalf("lower tier picked %d times while top tier available", counts["low"])
+	}
+	if counts["heavy"] != 6 || counts["light"] != 2 {
+		t.Fatalf("weighted distribution = %v, want heavy=6 light=2", counts)
+	}
+}
+
+func TestWeightedSelectorPick_SpillsToLowerTier(t *testing.T) {
+	t.Parallel()
+
+	selector := &WeightedSelector{}
+	auths := []*Auth{
+		{ID: "primary", Attributes: map[string]string{"priority": "10"}, Disabled: true},
+		{ID: "cooling", Attributes: map[string]string{"priority": "10"}, Unavailable: true, NextRetryAfter: time.Now().Add(time.Minute)},
+		{ID: "fallback"},
+	}
+
+	got, err := selector.Pick(context.Background(), "claude", "", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "fallback" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "fallback")
+	}
+}
+
+func TestStickySelectorPick_KeepsSessionAndRepins(t *testing.T) {
+	t.Parallel()
+
+	selector := NewStickySelector(&RoundRobinSelector{}, time.Minute)
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	session := cliproxyexecutor.Options{OriginalRequest: []byte(`{"metadata":{"user_id":"u-1"},"messages":[{"role":"user","content":"hi"}]}`)}
+	other := cliproxyexecutor.Options{OriginalRequest: []byte(`{"messages":[{"role":"user","content":"different"}]}`)}
+
+	pinned, err := selector.Pick(context.Background(), "claude", "", session, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err = selector.Pick(context.Background(), "claude", "", other, auths); err != nil {
+			t.Fatalf("Pick() other #%d error = %v", i, err)
+		}
+		got, errPick := selector.Pick(context.Background(), "claude", "", session, auths)
+		if errPick != nil {
+			t.Fatalf("Pick() session #%d error = %v", i, errPick)
+		}
+		if got.ID != pinned.ID {
+			t.Fatalf("Pick() session #%d auth.ID = %q, want pinned %q", i, got.ID, pinned.ID)
+		}
+	}
+
+	for _, auth := range auths {
+		if auth.ID == pinned.ID {
+			auth.Unavailable = true
+			auth.NextRetryAfter = time.Now().Add(time.Minute)
+		}
+	}
+	repinned, err := selector.Pick(context.Background(), "claude", "", session, auths)
+	if err != nil {
+		t.Fatalf("Pick() after cooldown error = %v", err)
+	}
+	if repinned.ID == pinned.ID {
+		t.Fatalf("Pick() kept cooling auth %q", pinned.ID)
+	}
+	again, err := selector.Pick(context.Background(), "claude", "", session, auths)
+	if err != nil {
+		t.Fatalf("Pick() after repin error = %v", err)
+	}
+	if again.ID != repinned.ID {
+		t.Fatalf("Pick() auth.ID = %q, want repinned %q", again.ID, repinned.ID)
+	}
+}
+
+func TestSessionAffinityKey_Sources(t *testing.T) {
+	t.Parallel()
+
+	header := cliproxyexecutor.Options{
+		Metadata:        map[string]any{cliproxyexecutor.SessionAffinityMetadataKey: "s-1"},
+		OriginalRequest: []byte(`{"prompt_cache_key":"pc"}`),
+	}
+	if got := SessionAffinityKey(header); got != "header:s-1" {
+		t.Fatalf("SessionAffinityKey(header) = %q", got)
+	}
+	if got := SessionAffinityKey(cliproxyexecutor.Options{OriginalRequest: []byte(`{"prompt_cache_key":"pc"}`)}); got != "cache:pc" {
+		t.Fatalf("SessionAffinityKey(prompt_cache_key) = %q", got)
+	}
+	turn1 := SessionAffinityKey(cliproxyexecutor.Options{OriginalRequest: []byte(`{"contents":[{"role":"user","parts":[{"text":"q"}]}]}`)})
+	turn2 := SessionAffinityKey(cliproxyexecutor.Options{OriginalRequest: []byte(`{"contents":[{"role":"user","parts":[{"text":"q"}]},{"role":"model","parts":[{"text":"a"}]},{"role":"user","parts":[{"text":"q2"}]}]}`)})
+	if turn1 == "" || turn1 != turn2 {
+		t.Fatalf("conversation prefix keys differ across turns: %q vs %q", turn1, turn2)
+	}
+	if got := SessionAffinityKey(cliproxyexecutor.Options{}); got != "" {
+		t.Fatalf("SessionAffinityKey(empty) = %q, want empty", got)
+	}
+}
+
+func TestLeastLoadSelectorPick_PrefersIdleAndFast(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewLoadTracker()
+	selector := &LeastLoadSelector{}
+	selector.SetLoadTracker(tracker)
+	auths := []*Auth{{ID: "slow"}, {ID: "fast"}, {ID: "busy"}}
+
+	tracker.Observe("slow", "m", 900*time.Millisecond, true)
+	tracker.Observe("fast", "m", 100*time.Millisecond, true)
+	tracker.Observe("busy", "m", 100*time.Millisecond, true)
+	for i := 0; i < 3; i++ {
+		defer tracker.Begin("busy")()
+	}
+
+	got, err := selector.Pick(context.Background(), "codex", "m", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "fast" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "fast")
+	}
+
+	release := tracker.Begin("fast")
+	for i := 0; i < 8; i++ {
+		tracker.Begin("fast")
+	}
+	got, err = selector.Pick(context.Background(), "codex", "m", cliproxyexecutor.Options{}, auths)
+	release()
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "busy" {
+		t.Fatalf("Pick() with saturated fast auth = %q, want %q", got.ID, "busy")
+	}
+
+	stats := tracker.Snapshot()
+	if len(stats) != 3 || stats[0].AuthID != "busy" || stats[0].InFlight != 3 {
+		t.Fatalf("Snapshot() = %+v", stats)
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// DefaultSessionAffinityTTL is how long an idle session stays pinned to a credential.
+	DefaultSessionAffinityTTL = time.Hour
+	// maxStickyEntries bounds the number of remembered sessions.
+	maxStickyEntries = 10000
+)
+
+type stickyEntry struct {
+	authID  string
+	expires time.Time
+}
+
+// StickySelector keeps requests that share a session affinity key on the same credential so
+// upstream prompt caches and cached thinking signatures stay valid across turns. Requests
+// without an affinity key, new sessions and sessions whose credential became unavailable are
+// delegated to the wrapped selector, and the session is re-pinned to its choice.
+type StickySelector struct {
+	fallback Selector
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]stickyEntry
+}
+
+// NewStickySelector wraps fallback with session affinity. A non-positive ttl uses DefaultSessionAffinityTTL.
+func NewStickySelector(fallback Selector, ttl time.Duration) *StickySelector {
+	if fallback == nil {
+		fallback = &RoundRobinSelector{}
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionAffinityTTL
+	}
+	return &StickySelector{fallback: fallback, ttl: ttl, entries: make(map[string]stickyEntry)}
+}
+
+// SetLoadTracker forwards the manager's load tracker to the wrapped selector.
+func (s *StickySelector) SetLoadTracker(tracker *LoadTracker) {
+	if aware, ok := s.fallback.(loadTrackerAware); ok {
+		aware.SetLoadTracker(tracker)
+	}
+}
+
+// Pick returns the credential pinned to the request's session when it is still available,
+// otherwise it asks the wrapped selector and pins the session to the result.
+func (s *StickySelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	affinity := SessionAffinityKey(opts)
+	if affinity == "" {
+		return s.fallback.Pick(ctx, provider, model, opts, auths)
+	}
+	key := provider + "|" + affinity
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		available, err := getAvailableAuths(auths, provider, model, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range available {
+			if candidate.ID == entry.authID {
+				s.remember(key, candidate.ID, now)
+				return candidate, nil
+			}
+		}
+	}
+
+	selected, err := s.fallback.Pick(ctx, provider, model, opts, auths)
+	if err != nil || selected == nil {
+		return selected, err
+	}
+	s.remember(key, selected.ID, now)
+	return selected, nil
+}
+
+func (s *StickySelector) remember(key, authID string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[key]; !exists && len(s.entries) >= maxStickyEntries {
+		s.evictLocked(now)
+	}
+	s.entries[key] = stickyEntry{authID: authID, expires: now.Add(s.ttl)}
+}
+
+// evictLocked drops expired sessions and, when none expired, the one closest to expiry.
+func (s *StickySelector) evictLocked(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+	if len(s.entries) >= maxStickyEntries && oldestKey != "" {
+		delete(s.entries, oldestKey)
+	}
+}
+
+// SessionAffinityKey derives the session identity of a request. In order of preference it uses
+// the X-Session-Affinity header, Claude metadata.user_id, the Codex prompt_cache_key and finally
+// a hash of the conversation prefix (system prompt and first message). It returns "" when the
+// request carries nothing usable.
+func SessionAffinityKey(opts cliproxyexecutor.Options) string {
+	if raw, ok := opts.Metadata[cliproxyexecutor.SessionAffinityMetadataKey].(string); ok {
+		if value := strings.TrimSpace(raw); value != "" {
+			return "header:" + value
+		}
+	}
+	payload := opts.OriginalRequest
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return ""
+	}
+	if value := strings.TrimSpace(gjson.GetBytes(payload, "metadata.user_id").String()); value != "" {
+		return "user:" + value
+	}
+	if value := strings.TrimSpace(gjson.GetBytes(payload, "prompt_cache_key").String()); value != "" {
+		return "cache:" + value
+	}
+	return conversationPrefixKey(payload)
+}
+
+// conversationPrefixKey hashes the parts of a request that stay constant across the turns of
+// a conversation in the OpenAI, Claude, Responses and Gemini request formats: the system prompt
+// and the first non-system message.
+func conversationPrefixKey(payload []byte) string {
+	var first gjson.Result
+	for _, path := range []string{"messages", "input", "contents", "request.contents"} {
+		if first = firstConversationTurn(gjson.GetBytes(payload, path)); first.Exists() {
+			break
+		}
+	}
+	if !first.Exists() {
+		return ""
+	}
+	hasher := sha256.New()
+	for _, path := range []string{"system", "instructions", "systemInstruction", "system_instruction", "request.systemInstruction"} {
+		if system := gjson.GetBytes(payload, path); system.Exists() {
+			hasher.Write([]byte(system.Raw))
+		}
+	}
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(first.Raw))
+	return "prefix:" + hex.EncodeToString(hasher.Sum(nil)[:16])
+}
+
+func firstConversationTurn(turns gjson.Result) gjson.Result {
+	if turns.Type == gjson.String {
+		return turns
+	}
+	if !turns.IsArray() {
+		return gjson.Result{}
+	}
+	var first gjson.Result
+	turns.ForEach(func(_, turn gjson.Result) bool {
+		switch turn.Get("role").String() {
+		case "system", "developer":
+			return true
+		}
+		first = turn
+		return false
+	})
+	return first
+}
@@ -11,3 +11,13 @@ type Store interface {
 	// Delete removes the auth record identified by id.
 	Delete(ctx context.Context, id string) error
 }
+
+// RuntimeStateStore is implemented by stores that also persist runtime availability state
+// (cooldowns and quota backoff) separately from the auth records.
+type RuntimeStateStore interface {
+	// LoadRuntimeState returns the saved runtime state keyed by auth ID.
+	LoadRuntimeState(ctx context.Context) (map[string]*RuntimeState, error)
+	// SaveRuntimeState replaces the saved runtime state with states. Stores shared between
+	// replicas must leave the state other processes saved in place.
+	SaveRuntimeState(ctx context.Context, states map[string]*RuntimeState) error
+}
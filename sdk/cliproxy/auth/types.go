@@ -75,6 +75,8 @@ type QuotaState struct {
 	NextRecoverAt time.Time `json:"next_recover_at"`
 	// BackoffLevel stores the progressive cooldown exponent used for rate limits.
 	BackoffLevel int `json:"backoff_level,omitempty"`
+	// Usage holds the remaining capacity the provider last reported.
+	Usage *QuotaUsage `json:"usage,omitempty"`
 }
 
 // ModelState captures the execution state for a specific model under an auth entry.
@@ -245,6 +247,56 @@ func (a *Auth) AccountInfo() (string, string) {
 	return "", ""
 }
 
+// Priority returns the selection tier of the credential; higher values are preferred.
+// It reads the "priority" attribute (config keys) or metadata field (auth files) and defaults to 0.
+func (a *Auth) Priority() int {
+	value, _ := a.routingInt("priority")
+	return value
+}
+
+// Weight returns the relative share of traffic the credential receives within its tier.
+// It reads the "weight" attribute or metadata field and defaults to 1.
+func (a *Auth) Weight() int {
+	value, ok := a.routingInt("weight")
+	if !ok || value <= 0 {
+		return 1
+	}
+	return value
+}
+
+func (a *Auth) routingInt(key string) (int, bool) {
+	if a == nil {
+		return 0, false
+	}
+	if raw := strings.TrimSpace(a.Attributes[key]); raw != "" {
+		if value, err := strconv.Atoi(raw); err == nil {
+			return value, true
+		}
+	}
+	return intValue(a.Metadata[key])
+}
+
+// intValue converts a numeric metadata value decoded from JSON or set in code to an int.
+func intValue(raw any) (int, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case json.Number:
+		if value, err := v.Int64(); err == nil {
+			return int(value), true
+		}
+	case string:
+		if value, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
 // ExpirationTime attempts to extract the credential expiration timestamp from metadata.
 // It inspects common keys such as "expired", "expire", "expires_at", and also
 // nested "token" objects to remain compatible with legacy auth file formats.
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// WeightedSelector prefers the highest-priority tier of available credentials and
+// distributes requests inside that tier by weight using smooth weighted round-robin.
+// When every credential of a tier is cooling down or disabled, selection spills to the
+// next tier and returns automatically once the higher tier becomes available again.
+type WeightedSelector struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+// Pick selects the next auth from the highest available priority tier.
+func (s *WeightedSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	tier := topPriorityTier(available)
+	if len(tier) == 1 {
+		return tier[0], nil
+	}
+
+	key := provider + ":" + model
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = make(map[string]map[string]int)
+	}
+	weights := s.current[key]
+	if weights == nil {
+		weights = make(map[string]int)
+		s.current[key] = weights
+	}
+	// Forget credentials that left the tier so a returning tier starts from a fair state.
+	for id := range weights {
+		if !containsAuth(tier, id) {
+			delete(weights, id)
+		}
+	}
+	total := 0
+	var best *Auth
+	for _, candidate := range tier {
+		weight := candidate.Weight()
+		total += weight
+		weights[candidate.ID] += weight
+		if best == nil || weights[candidate.ID] > weights[best.ID] {
+			best = candidate
+		}
+	}
+	weights[best.ID] -= total
+	return best, nil
+}
+
+// topPriorityTier returns the credentials sharing the highest priority, preserving order.
+func topPriorityTier(available []*Auth) []*Auth {
+	if len(available) <= 1 {
+		return available
+	}
+	top := available[0].Priority()
+	for _, candidate := range available[1:] {
+		if p := candidate.Priority(); p > top {
+			top = p
+		}
+	}
+	tier := make([]*Auth, 0, len(available))
+	for _, candidate := range available {
+		if candidate.Priority() == top {
+			tier = append(tier, candidate)
+		}
+	}
+	return tier
+}
+
+func containsAuth(auths []*Auth, id string) bool {
+	for _, candidate := range auths {
+		if candidate.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -5,12 +5,12 @@ package cliproxy
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/pipeline"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
@@ -47,6 +47,9 @@ type Builder struct {
 
 	// serverOptions contains additional server configuration options.
 	serverOptions []api.ServerOption
+
+	// pipelineHooks run around every executor call, in registration order.
+	pipelineHooks []pipeline.Hook
 }
 
 // Hooks allows callers to plug into service lifecycle stages.
@@ -143,6 +146,17 @@ func (b *Builder) WithServerOptions(opts ...api.ServerOption) *Builder {
 	return b
 }
 
+// WithPipelineHooks appends hooks that run around every executor call. BeforeExecute,
+// OnStreamChunk and AfterExecute callbacks run in the order the hooks were registered.
+func (b *Builder) WithPipelineHooks(hooks ...pipeline.Hook) *Builder {
+	for _, hook := range hooks {
+		if hook != nil {
+			b.pipelineHooks = append(b.pipelineHooks, hook)
+		}
+	}
+	return b
+}
+
 // WithLocalManagementPassword configures a password that is only accepted from localhost management requests.
 func (b *Builder) WithLocalManagementPassword(password string) *Builder {
 	if password == "" {
@@ -199,23 +213,15 @@ func (b *Builder) Build() (*Service, error) {
 			dirSetter.SetBaseDir(b.cfg.AuthDir)
 		}
 
-		strategy := ""
-		if b.cfg != nil {
-			strategy = strings.ToLower(strings.TrimSpace(b.cfg.Routing.Strategy))
-		}
-		var selector coreauth.Selector
-		switch strategy {
-		case "fill-first", "fillfirst", "ff":
-			selector = &coreauth.FillFirstSelector{}
-		default:
-			selector = &coreauth.RoundRobinSelector{}
-		}
-
-		coreManager = coreauth.NewManager(tokenStore, selector, nil)
+		coreManager = coreauth.NewManager(tokenStore, newRoutingSelector(b.cfg), nil)
 	}
 	// Attach a default RoundTripper provider so providers can opt-in per-auth transports.
 	coreManager.SetRoundTripperProvider(newDefaultRoundTripperProvider())
 	coreManager.SetOAuthModelMappings(b.cfg.OAuthModelMappings)
+	coreManager.SetModelFallbacks(b.cfg.Fallbacks)
+	if len(b.pipelineHooks) > 0 {
+		coreManager.SetExecutorWrapper(pipeline.WrapperFor(b.pipelineHooks...))
+	}
 
 	service := &Service{
 		cfg:            b.cfg,
@@ -1,3 +1,15 @@
 package executor
 
 const GeminiFilesUploadURLMetadataKey = "gemini.files.upload_url"
+
+// SessionAffinityMetadataKey carries a client supplied session identifier used to keep
+// a conversation on the same credential.
+const SessionAffinityMetadataKey = "session_affinity_key"
+
+// RequestQueueMetadataKey carries the client's X-CPA-Queue header, which opts a request in to
+// ("true") or out of ("false") waiting in the request queue while all credentials cool down.
+const RequestQueueMetadataKey = "request_queue"
+
+// ClientKeyMetadataKey carries the API key of the calling client. The request queue uses it to
+// share dispatch slots fairly between clients.
+const ClientKeyMetadataKey = "client_key"
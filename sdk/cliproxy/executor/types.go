@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 
@@ -60,3 +61,47 @@ type StatusError interface {
 	error
 	StatusCode() int
 }
+
+// ResponseObserver receives the status code and headers of the upstream HTTP responses an
+// executor receives while serving a request.
+type ResponseObserver func(statusCode int, header http.Header)
+
+type responseObserverKey struct{}
+
+// WithResponseObserver returns a context whose upstream responses are reported to observer.
+func WithResponseObserver(ctx context.Context, observer ResponseObserver) context.Context {
+	if observer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, responseObserverKey{}, observer)
+}
+
+// ResponseObserverFromContext returns the observer installed by WithResponseObserver, or nil.
+func ResponseObserverFromContext(ctx context.Context) ResponseObserver {
+	if ctx == nil {
+		return nil
+	}
+	observer, _ := ctx.Value(responseObserverKey{}).(ResponseObserver)
+	return observer
+}
+
+type httpClientKey struct{}
+
+// WithHTTPClient returns a context whose upstream calls are made with client. Its transport
+// takes precedence over the proxy-url of the auth and the config, and its Timeout, when set,
+// replaces the timeout of the executor.
+func WithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	if client == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, httpClientKey{}, client)
+}
+
+// HTTPClientFromContext returns the client installed by WithHTTPClient, or nil.
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	if ctx == nil {
+		return nil
+	}
+	client, _ := ctx.Value(httpClientKey{}).(*http.Client)
+	return client
+}
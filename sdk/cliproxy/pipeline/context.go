@@ -11,16 +11,22 @@ import (
 
 // Context encapsulates execution state shared across middleware, translators, and executors.
 type Context struct {
-	// Request encapsulates the provider facing request payload.
+	// Request is the request handed to the executor. Its payload is still in the client's
+	// format (Options.SourceFormat); the executor translates it for the provider afterwards
+	// and hands the result to OnTranslatedRequest.
 	Request cliproxyexecutor.Request
 	// Options carries execution flags (streaming, headers, etc.).
 	Options cliproxyexecutor.Options
 	// Auth references the credential selected for execution.
 	Auth *cliproxyauth.Auth
-	// Translator represents the pipeline responsible for schema adaptation.
+	// Translator is the pipeline the executor translates the request with. BeforeExecute may add
+	// request middleware to it; the OnTranslatedRequest stage is already installed.
 	Translator *sdktranslator.Pipeline
-	// HTTPClient allows middleware to customise the outbound transport per request.
+	// HTTPClient, when set by BeforeExecute, makes the upstream call. Its transport replaces the
+	// configured proxy-url and its Timeout, when set, the executor's timeout.
 	HTTPClient *http.Client
+	// Response holds the result returned to the caller; AfterExecute hooks may replace it.
+	Response cliproxyexecutor.Response
 }
 
 // Hook captures middleware callbacks around execution.
@@ -30,11 +36,19 @@ type Hook interface {
 	OnStreamChunk(ctx context.Context, execCtx *Context, chunk cliproxyexecutor.StreamChunk)
 }
 
+// TranslatedRequestHook is implemented by hooks that rewrite the request after the executor
+// translated it into the provider format, right before it is sent upstream. It runs every time
+// the executor translates the request, after BeforeExecute.
+type TranslatedRequestHook interface {
+	OnTranslatedRequest(ctx context.Context, execCtx *Context, req *sdktranslator.RequestEnvelope)
+}
+
 // HookFunc aggregates optional hook implementations.
 type HookFunc struct {
-	Before func(context.Context, *Context)
-	After  func(context.Context, *Context, cliproxyexecutor.Response, error)
-	Stream func(context.Context, *Context, cliproxyexecutor.StreamChunk)
+	Before     func(context.Context, *Context)
+	Translated func(context.Context, *Context, *sdktranslator.RequestEnvelope)
+	After      func(context.Context, *Context, cliproxyexecutor.Response, error)
+	Stream     func(context.Context, *Context, cliproxyexecutor.StreamChunk)
 }
 
 // BeforeExecute implements Hook.
@@ -44,6 +58,13 @@ func (h HookFunc) BeforeExecute(ctx context.Context, execCtx *Context) {
 	}
 }
 
+// OnTranslatedRequest implements TranslatedRequestHook.
+func (h HookFunc) OnTranslatedRequest(ctx context.Context, execCtx *Context, req *sdktranslator.RequestEnvelope) {
+	if h.Translated != nil {
+		h.Translated(ctx, execCtx, req)
+	}
+}
+
 // AfterExecute implements Hook.
 func (h HookFunc) AfterExecute(ctx context.Context, execCtx *Context, resp cliproxyexecutor.Response, err error) {
 	if h.After != nil {
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// WrapExecutor returns an executor that runs hooks around every Execute, ExecuteStream and
+// CountTokens call of exec. Hooks run in the order given: BeforeExecute may rewrite the request
+// and options or set an HTTP client used for the upstream call, OnTranslatedRequest (for hooks
+// implementing TranslatedRequestHook) may rewrite the request once the executor translated it
+// for the provider, OnStreamChunk sees every chunk of a stream, and AfterExecute runs once the
+// call or stream has finished and may replace Context.Response to post-process the result. A
+// panicking hook is logged and skipped.
+//
+// BeforeExecute sees the request payload in Options.SourceFormat, the format the client sent;
+// responses and chunks are in the format returned to the client.
+func WrapExecutor(exec cliproxyauth.ProviderExecutor, hooks ...Hook) cliproxyauth.ProviderExecutor {
+	if exec == nil || len(hooks) == 0 {
+		return exec
+	}
+	return &hookedExecutor{ProviderExecutor: exec, hooks: append([]Hook(nil), hooks...)}
+}
+
+// WrapperFor returns a wrapper for cliproxyauth.Manager.SetExecutorWrapper that runs hooks
+// around every executor call, or nil when no hook is given.
+func WrapperFor(hooks ...Hook) func(cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+	if len(hooks) == 0 {
+		return nil
+	}
+	hooks = append([]Hook(nil), hooks...)
+	return func(exec cliproxyauth.ProviderExecutor) cliproxyauth.ProviderExecutor {
+		return WrapExecutor(exec, hooks...)
+	}
+}
+
+type hookedExecutor struct {
+	cliproxyauth.ProviderExecutor
+	hooks []Hook
+}
+
+// Execute implements cliproxyauth.ProviderExecutor.
+func (e *hookedExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	execCtx := &Context{Request: req, Options: opts, Auth: auth}
+	ctx = e.before(ctx, execCtx)
+	resp, err := e.ProviderExecutor.Execute(ctx, auth, execCtx.Request, execCtx.Options)
+	execCtx.Response = resp
+	e.after(ctx, execCtx, err)
+	return execCtx.Response, err
+}
+
+// ExecuteStream implements cliproxyauth.ProviderExecutor.
+func (e *hookedExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	execCtx := &Context{Request: req, Options: opts, Auth: auth}
+	ctx = e.before(ctx, execCtx)
+	chunks, err := e.ProviderExecutor.ExecuteStream(ctx, auth, execCtx.Request, execCtx.Options)
+	if err != nil {
+		e.after(ctx, execCtx, err)
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var streamErr error
+		for chunk := range chunks {
+			if chunk.Err != nil && streamErr == nil {
+				streamErr = chunk.Err
+			}
+			for _, hook := range e.hooks {
+				safeCall("OnStreamChunk", func() { hook.OnStreamChunk(ctx, execCtx, chunk) })
+			}
+			out <- chunk
+		}
+		e.after(ctx, execCtx, streamErr)
+	}()
+	return out, nil
+}
+
+// CountTokens implements cliproxyauth.ProviderExecutor.
+func (e *hookedExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	execCtx := &Context{Request: req, Options: opts, Auth: auth}
+	ctx = e.before(ctx, execCtx)
+	resp, err := e.ProviderExecutor.CountTokens(ctx, auth, execCtx.Request, execCtx.Options)
+	execCtx.Response = resp
+	e.after(ctx, execCtx, err)
+	return execCtx.Response, err
+}
+
+// before runs the BeforeExecute hooks and returns the context the call is made with. The
+// context carries the translator pipeline that runs the OnTranslatedRequest hooks.
+func (e *hookedExecutor) before(ctx context.Context, execCtx *Context) context.Context {
+	execCtx.Translator = sdktranslator.NewPipeline(nil)
+	execCtx.Translator.UseRequest(func(ctx context.Context, req sdktranslator.RequestEnvelope, next sdktranslator.RequestHandler) (sdktranslator.RequestEnvelope, error) {
+		translated, err := next(ctx, req)
+		if err != nil {
+			return translated, err
+		}
+		for _, hook := range e.hooks {
+			if h, ok := hook.(TranslatedRequestHook); ok {
+				safeCall("OnTranslatedRequest", func() { h.OnTranslatedRequest(ctx, execCtx, &translated) })
+			}
+		}
+		return translated, nil
+	})
+	for _, hook := range e.hooks {
+		safeCall("BeforeExecute", func() { hook.BeforeExecute(ctx, execCtx) })
+	}
+	ctx = sdktranslator.WithPipeline(ctx, execCtx.Translator)
+	return cliproxyexecutor.WithHTTPClient(ctx, execCtx.HTTPClient)
+}
+
+func (e *hookedExecutor) after(ctx context.Context, execCtx *Context, err error) {
+	for _, hook := range e.hooks {
+		safeCall("AfterExecute", func() { hook.AfterExecute(ctx, execCtx, execCtx.Response, err) })
+	}
+}
+
+func safeCall(stage string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("pipeline: %s hook panic recovered: %v", stage, r)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+type echoExecutor struct{}
+
+func (echoExecutor) Identifier() string { return "echo" }
+
+func (echoExecutor) Execute(_ context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: req.Payload}, nil
+}
+
+func (echoExecutor) ExecuteStream(_ context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	out := make(chan cliproxyexecutor.StreamChunk, 2)
+	out <- cliproxyexecutor.StreamChunk{Payload: req.Payload}
+	out <- cliproxyexecutor.StreamChunk{Payload: []byte("done")}
+	close(out)
+	return out, nil
+}
+
+func (echoExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	return auth, nil
+}
+
+func (echoExecutor) CountTokens(context.Context, *cliproxyauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func TestWrapExecutor_RunsHooksInOrder(t *testing.T) {
+	var calls []string
+	first := HookFunc{
+		Before: func(_ context.Context, c *Context) {
+			calls = append(calls, "before-1:"+c.Auth.ID)
+			c.Request.Payload = []byte("rewritten")
+		},
+		After: func(_ context.Context, c *Context, resp cliproxyexecutor.Response, _ error) {
+			calls = append(calls, "after-1:"+string(resp.Payload))
+			c.Response.Payload = []byte("post-processed")
+		},
+		Stream: func(_ context.Context, _ *Context, chunk cliproxyexecutor.StreamChunk) {
+			calls = append(calls, "chunk:"+string(chunk.Payload))
+		},
+	}
+	panicking := HookFunc{Before: func(context.Context, *Context) { panic("boom") }}
+	second := HookFunc{
+		Before: func(context.Context, *Context) { calls = append(calls, "before-2") },
+		After: func(_ context.Context, _ *Context, resp cliproxyexecutor.Response, _ error) {
+			calls = append(calls, "after-2:"+string(resp.Payload))
+		},
+	}
+	exec := WrapExecutor(echoExecutor{}, first, panicking, second)
+	auth := &cliproxyauth.Auth{ID: "auth-1"}
+
+	resp, err := exec.Execute(context.Background(), auth, cliproxyexecutor.Request{Payload: []byte("original")}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if string(resp.Payload) != "post-processed" {
+		t.Fatalf("expected the after hook to replace the response, got %q", resp.Payload)
+	}
+	want := []string{"before-1:auth-1", "before-2", "after-1:rewritten", "after-2:post-processed"}
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected hook calls %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("call %d: got %q, want %q", i, calls[i], want[i])
+		}
+	}
+
+	calls = nil
+	chunks, err := exec.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{Payload: []byte("original")}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("execute stream: %v", err)
+	}
+	for range chunks {
+	}
+	want = []string{"before-1:auth-1", "before-2", "chunk:rewritten", "chunk:done", "after-1:", "after-2:post-processed"}
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected stream hook calls %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("stream call %d: got %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+type clientCapturingExecutor struct {
+	echoExecutor
+	client *http.Client
+}
+
+func (e *clientCapturingExecutor) Execute(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.client = cliproxyexecutor.HTTPClientFromContext(ctx)
+	return cliproxyexecutor.Response{Payload: req.Payload}, nil
+}
+
+func TestWrapExecutor_PassesHookHTTPClient(t *testing.T) {
+	client := &http.Client{Timeout: time.Second}
+	inner := &clientCapturingExecutor{}
+	exec := WrapExecutor(inner, HookFunc{Before: func(_ context.Context, c *Context) { c.HTTPClient = client }})
+
+	if _, err := exec.Execute(context.Background(), &cliproxyauth.Auth{ID: "auth-1"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if inner.client != client {
+		t.Fatalf("expected the hook client in the executor context, got %v", inner.client)
+	}
+}
+
+// translatingExecutor translates the request for the provider the way the built-in executors
+// do and echoes the translated payload.
+type translatingExecutor struct{ echoExecutor }
+
+func (translatingExecutor) Execute(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	payload := sdktranslator.TranslateRequestContext(ctx, opts.SourceFormat, sdktranslator.FromString("openai"), req.Model, req.Payload, false)
+	return cliproxyexecutor.Response{Payload: payload}, nil
+}
+
+func TestWrapExecutor_RunsTranslatedRequestHooks(t *testing.T) {
+	var translator *sdktranslator.Pipeline
+	var seen sdktranslator.RequestEnvelope
+	exec := WrapExecutor(translatingExecutor{}, HookFunc{
+		Before: func(_ context.Context, c *Context) { translator = c.Translator },
+		Translated: func(_ context.Context, c *Context, req *sdktranslator.RequestEnvelope) {
+			seen = *req
+			req.Body = []byte(`{"rewritten":"` + c.Auth.ID + `"}`)
+		},
+	})
+
+	resp, err := exec.Execute(context.Background(), &cliproxyauth.Auth{ID: "auth-1"}, cliproxyexecutor.Request{Model: "gpt-5", Payload: []byte(`{"original":true}`)}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if translator == nil {
+		t.Fatal("expected BeforeExecute to see the translator pipeline")
+	}
+	if seen.Format != sdktranslator.FromString("openai") || seen.Model != "gpt-5" || string(seen.Body) != `{"original":true}` {
+		t.Fatalf("unexpected translated request %+v", seen)
+	}
+	if string(resp.Payload) != `{"rewritten":"auth-1"}` {
+		t.Fatalf("expected the executor to send the rewritten request, got %s", resp.Payload)
+	}
+}
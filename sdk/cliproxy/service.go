@@ -8,10 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
@@ -310,6 +312,33 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 	}
 }
 
+// startClusterSync shares credential state with other replicas when enabled and supported by
+// the token store.
+func (s *Service) startClusterSync() {
+	if s == nil || s.coreManager == nil || s.cfg == nil || !s.cfg.Cluster.Enabled {
+		return
+	}
+	provider, ok := sdkAuth.GetTokenStore().(coreauth.ClusterStateProvider)
+	if !ok {
+		log.Warn("cluster state sharing is enabled but the token store does not support it")
+		return
+	}
+	replicaID := strings.TrimSpace(s.cfg.Cluster.ReplicaID)
+	if replicaID == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "replica"
+		}
+		replicaID = host + "-" + uuid.NewString()[:8]
+	}
+	cluster, err := provider.ClusterState(replicaID)
+	if err != nil {
+		log.Errorf("failed to initialize cluster state: %v", err)
+		return
+	}
+	s.coreManager.StartClusterSync(context.Background(), cluster)
+}
+
 func (s *Service) applyRetryConfig(cfg *config.Config) {
 	if s == nil || s.coreManager == nil || cfg == nil {
 		return
@@ -318,6 +347,45 @@ func (s *Service) applyRetryConfig(cfg *config.Config) {
 	s.coreManager.SetRetryConfig(cfg.RequestRetry, maxInterval)
 }
 
+func (s *Service) applyCircuitBreakerConfig(cfg *config.Config) {
+	if s == nil || s.coreManager == nil || cfg == nil {
+		return
+	}
+	breaker := cfg.CircuitBreaker
+	s.coreManager.SetCircuitBreakerConfig(
+		breaker.Enabled,
+		breaker.FailureThreshold,
+		time.Duration(breaker.OpenSeconds)*time.Second,
+		time.Duration(breaker.MaxOpenSeconds)*time.Second,
+	)
+}
+
+// newRoutingSelector builds the credential selector described by the routing section.
+func newRoutingSelector(cfg *config.Config) coreauth.Selector {
+	if cfg == nil {
+		return coreauth.NewSelector("")
+	}
+	selector := coreauth.NewSelector(cfg.Routing.Strategy)
+	if cfg.Routing.SessionAffinity {
+		ttl := time.Duration(cfg.Routing.SessionAffinityTTL) * time.Second
+		return coreauth.NewStickySelector(selector, ttl)
+	}
+	return selector
+}
+
+// routingSignature summarises the routing settings that require a new selector when changed.
+func routingSignature(cfg *config.Config) string {
+	strategy := coreauth.NormalizeStrategy(cfg.Routing.Strategy)
+	if !cfg.Routing.SessionAffinity {
+		return strategy
+	}
+	ttl := time.Duration(cfg.Routing.SessionAffinityTTL) * time.Second
+	if ttl <= 0 {
+		ttl = coreauth.DefaultSessionAffinityTTL
+	}
+	return fmt.Sprintf("%s with session affinity (ttl=%s)", strategy, ttl)
+}
+
 func openAICompatInfoFromAuth(a *coreauth.Auth) (providerKey string, compatName string, ok bool) {
 	if a == nil {
 		return "", "", false
@@ -432,11 +500,17 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	s.applyRetryConfig(s.cfg)
+	s.applyCircuitBreakerConfig(s.cfg)
 
 	if s.coreManager != nil {
+		s.coreManager.SetRequestQueueConfig(s.cfg.RequestQueue)
+		s.coreManager.SetHedgingConfig(s.cfg.Hedging)
+		// Token usage drains the token buckets of the rate shaper.
+		usage.RegisterPlugin(s.coreManager)
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
 			log.Warnf("failed to load auth store: %v", errLoad)
 		}
+		s.startClusterSync()
 	}
 
 	s.ensureWebsocketGateway()
@@ -512,10 +586,12 @@ func (s *Service) Run(ctx context.Context) error {
 
 	var watcherWrapper *WatcherWrapper
 	reloadCallback := func(newCfg *config.Config) {
-		previousStrategy := ""
+		previousRouting := ""
+		var previousProbe config.HealthProbeConfig
 		s.cfgMu.RLock()
 		if s.cfg != nil {
-			previousStrategy = strings.ToLower(strings.TrimSpace(s.cfg.Routing.Strategy))
+			previousRouting = routingSignature(s.cfg)
+			previousProbe = s.cfg.HealthProbe
 		}
 		s.cfgMu.RUnlock()
 
@@ -528,30 +604,13 @@ func (s *Service) Run(ctx context.Context) error {
 			return
 		}
 
-		nextStrategy := strings.ToLower(strings.TrimSpace(newCfg.Routing.Strategy))
-		normalizeStrategy := func(strategy string) string {
-			switch strategy {
-			case "fill-first", "fillfirst", "ff":
-				return "fill-first"
-			default:
-				return "round-robin"
-			}
-		}
-		previousStrategy = normalizeStrategy(previousStrategy)
-		nextStrategy = normalizeStrategy(nextStrategy)
-		if s.coreManager != nil && previousStrategy != nextStrategy {
-			var selector coreauth.Selector
-			switch nextStrategy {
-			case "fill-first":
-				selector = &coreauth.FillFirstSelector{}
-			default:
-				selector = &coreauth.RoundRobinSelector{}
-			}
-			s.coreManager.SetSelector(selector)
-			log.Infof("routing strategy updated to %s", nextStrategy)
+		if nextRouting := routingSignature(newCfg); s.coreManager != nil && previousRouting != nextRouting {
+			s.coreManager.SetSelector(newRoutingSelector(newCfg))
+			log.Infof("routing strategy updated to %s", nextRouting)
 		}
 
 		s.applyRetryConfig(newCfg)
+		s.applyCircuitBreakerConfig(newCfg)
 		if s.server != nil {
 			s.server.UpdateClients(newCfg)
 		}
@@ -560,6 +619,12 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfgMu.Unlock()
 		if s.coreManager != nil {
 			s.coreManager.SetOAuthModelMappings(newCfg.OAuthModelMappings)
+			s.coreManager.SetModelFallbacks(newCfg.Fallbacks)
+			s.coreManager.SetRequestQueueConfig(newCfg.RequestQueue)
+			s.coreManager.SetHedgingConfig(newCfg.Hedging)
+			if !reflect.DeepEqual(previousProbe, newCfg.HealthProbe) {
+				s.coreManager.StartHealthProbes(context.Background(), newCfg.HealthProbe)
+			}
 		}
 		s.rebindExecutors()
 	}
@@ -587,6 +652,10 @@ func (s *Service) Run(ctx context.Context) error {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		if s.cfg.HealthProbe.Enabled {
+			s.coreManager.StartHealthProbes(context.Background(), s.cfg.HealthProbe)
+			log.Info("credential health probes started")
+		}
 	}
 
 	select {
@@ -669,6 +738,11 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopHealthProbes()
+			s.coreManager.StopClusterSync()
+			if err := s.coreManager.SaveRuntimeState(ctx); err != nil {
+				log.Warnf("failed to save runtime auth state: %v", err)
+			}
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
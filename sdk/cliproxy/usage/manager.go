@@ -18,6 +18,8 @@ type Record struct {
 	Source      string
 	RequestedAt time.Time
 	Failed      bool
+	Hedged      bool
+	Cached      bool
 	Detail      Detail
 }
 
@@ -30,6 +32,22 @@ type Detail struct {
 	TotalTokens     int64
 }
 
+type hedgedContextKey struct{}
+
+// WithHedged returns a context whose usage records are marked as hedged duplicates.
+func WithHedged(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hedgedContextKey{}, true)
+}
+
+// IsHedged reports whether ctx belongs to a hedged duplicate request.
+func IsHedged(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	hedged, _ := ctx.Value(hedgedContextKey{}).(bool)
+	return hedged
+}
+
 // Plugin consumes usage records emitted by the proxy runtime.
 type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
@@ -116,6 +134,9 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 		m.mu.Unlock()
 		return
 	}
+	if IsHedged(ctx) {
+		record.Hedged = true
+	}
 	m.queue = append(m.queue, queueItem{ctx: ctx, record: record})
 	m.mu.Unlock()
 	m.cond.Signal()
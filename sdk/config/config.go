@@ -9,14 +9,27 @@ import internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 type SDKConfig = internalconfig.SDKConfig
 type AccessConfig = internalconfig.AccessConfig
 type AccessProvider = internalconfig.AccessProvider
+type APIKeyPolicy = internalconfig.APIKeyPolicy
+type ClientKey = internalconfig.ClientKey
 
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type CoalescingConfig = internalconfig.CoalescingConfig
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode
 type ModelNameMapping = internalconfig.ModelNameMapping
+type ModelFallback = internalconfig.ModelFallback
+type ClusterConfig = internalconfig.ClusterConfig
+type HealthProbeConfig = internalconfig.HealthProbeConfig
+type HealthProbeProvider = internalconfig.HealthProbeProvider
+type RequestQueueConfig = internalconfig.RequestQueueConfig
+type HedgingConfig = internalconfig.HedgingConfig
+type HedgedModel = internalconfig.HedgedModel
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
+type ContextWindowConfig = internalconfig.ContextWindowConfig
+type ContextWindowPolicy = internalconfig.ContextWindowPolicy
 type PayloadConfig = internalconfig.PayloadConfig
 type PayloadRule = internalconfig.PayloadRule
 type PayloadModelRule = internalconfig.PayloadModelRule
@@ -24,6 +37,8 @@ type PayloadModelRule = internalconfig.PayloadModelRule
 type GeminiKey = internalconfig.GeminiKey
 type CodexKey = internalconfig.CodexKey
 type ClaudeKey = internalconfig.ClaudeKey
+type RateLimit = internalconfig.RateLimit
+type ModelRateLimit = internalconfig.ModelRateLimit
 type VertexCompatKey = internalconfig.VertexCompatKey
 type VertexCompatModel = internalconfig.VertexCompatModel
 type OpenAICompatibility = internalconfig.OpenAICompatibility
@@ -34,6 +49,7 @@ type TLS = internalconfig.TLSConfig
 
 const (
 	AccessProviderTypeConfigAPIKey = internalconfig.AccessProviderTypeConfigAPIKey
+	AccessProviderTypeJWT          = internalconfig.AccessProviderTypeJWT
 	DefaultAccessProviderName      = internalconfig.DefaultAccessProviderName
 	DefaultPanelGitHubRepository   = internalconfig.DefaultPanelGitHubRepository
 )
@@ -42,6 +58,8 @@ func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
 	return internalconfig.MakeInlineAPIKeyProvider(keys)
 }
 
+func HashClientKey(plaintext string) string { return internalconfig.HashClientKey(plaintext) }
+
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }
 
 func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
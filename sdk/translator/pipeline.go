@@ -1,6 +1,10 @@
 package translator
 
-import "context"
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
 
 // RequestEnvelope represents a request in the translation pipeline.
 type RequestEnvelope struct {
@@ -104,3 +108,39 @@ func (p *Pipeline) TranslateResponse(ctx context.Context, from, to Format, resp
 
 	return handler(ctx, resp)
 }
+
+type pipelineContextKey struct{}
+
+// WithPipeline returns a context whose request translations through TranslateRequestContext
+// run the middleware of p. A nil p leaves ctx unchanged.
+func WithPipeline(ctx context.Context, p *Pipeline) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, pipelineContextKey{}, p)
+}
+
+// PipelineFromContext returns the pipeline attached by WithPipeline, or nil.
+func PipelineFromContext(ctx context.Context) *Pipeline {
+	if ctx == nil {
+		return nil
+	}
+	p, _ := ctx.Value(pipelineContextKey{}).(*Pipeline)
+	return p
+}
+
+// TranslateRequestContext translates a request like TranslateRequest, through the pipeline
+// attached to ctx when there is one. When a middleware fails, the request is translated
+// without the pipeline.
+func TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	p := PipelineFromContext(ctx)
+	if p == nil {
+		return TranslateRequest(from, to, model, rawJSON, stream)
+	}
+	env, err := p.TranslateRequest(ctx, from, to, RequestEnvelope{Format: from, Model: model, Stream: stream, Body: rawJSON})
+	if err != nil {
+		log.Warnf("translator: request pipeline failed, translating without it: %v", err)
+		return p.registry.TranslateRequest(from, to, model, rawJSON, stream)
+	}
+	return env.Body
+}
@@ -193,3 +193,90 @@ func readFile(t *testing.T, path string) string {
 	}
 	return string(data)
 }
+
+func TestPlaintextAPIKeyMigration(t *testing.T) {
+	path := writeConfig(t, `
+port: 8080
+api-keys:
+  - "plain-client-key-1234"
+api-key-policies:
+  - api-key: "plain-client-key-1234"
+    allowed-models:
+      - "gemini-*"
+budgets:
+  - api-key: "plain-client-key-1234"
+    total-tokens: 1000
+context-window:
+  keys:
+    - api-key: "plain-client-key-1234"
+      strategy: "drop-oldest"
+`)
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.APIKeys) != 0 {
+		t.Fatalf("expected plaintext api-keys to be cleared, got %v", cfg.APIKeys)
+	}
+	if len(cfg.ClientKeys) != 1 {
+		t.Fatalf("expected 1 client key, got %+v", cfg.ClientKeys)
+	}
+	entry := cfg.ClientKeys[0]
+	if entry.Hash != config.HashClientKey("plain-client-key-1234") || entry.Hint != "...1234" {
+		t.Fatalf("client key digest mismatch: %+v", entry)
+	}
+	if len(cfg.APIKeyPolicies) != 1 || cfg.APIKeyPolicies[0].APIKey != entry.ID {
+		t.Fatalf("policy not rewritten to key id %q: %+v", entry.ID, cfg.APIKeyPolicies)
+	}
+	if len(cfg.Budgets) != 1 || cfg.Budgets[0].APIKey != entry.ID {
+		t.Fatalf("budget not rewritten to key id %q: %+v", entry.ID, cfg.Budgets)
+	}
+	if len(cfg.ContextWindow.Keys) != 1 || cfg.ContextWindow.Keys[0].APIKey != entry.ID {
+		t.Fatalf("context window policy not rewritten to key id %q: %+v", entry.ID, cfg.ContextWindow.Keys)
+	}
+
+	updated := readFile(t, path)
+	if strings.Contains(updated, "plain-client-key-1234") {
+		t.Fatalf("plaintext key still present:\n%s", updated)
+	}
+
+	reloaded, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if len(reloaded.ClientKeys) != 1 || reloaded.ClientKeys[0].ID != entry.ID || reloaded.ClientKeys[0].Hash != entry.Hash {
+		t.Fatalf("client keys not stable across reload: %+v", reloaded.ClientKeys)
+	}
+}
+
+func TestPlaintextAPIKeyMigrationStableWithoutPersistence(t *testing.T) {
+	path := writeConfig(t, `
+port: 8080
+api-keys:
+  - "plain-client-key-1234"
+budgets:
+  - api-key: "plain-client-key-1234"
+    total-tokens: 1000
+`)
+	first, err := config.LoadConfigOptional(path, true)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	second, err := config.LoadConfigOptional(path, true)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if !strings.Contains(readFile(t, path), "plain-client-key-1234") {
+		t.Fatalf("optional load should not rewrite the config file")
+	}
+	if len(first.ClientKeys) != 1 || len(second.ClientKeys) != 1 {
+		t.Fatalf("expected 1 client key per load, got %+v and %+v", first.ClientKeys, second.ClientKeys)
+	}
+	want := config.ClientKeyIDFromHash(config.HashClientKey("plain-client-key-1234"))
+	if first.ClientKeys[0].ID != want || second.ClientKeys[0].ID != want {
+		t.Fatalf("migrated ids = %q and %q, want %q", first.ClientKeys[0].ID, second.ClientKeys[0].ID, want)
+	}
+	if second.Budgets[0].APIKey != want {
+		t.Fatalf("budget not rewritten to key id %q: %+v", want, second.Budgets)
+	}
+}
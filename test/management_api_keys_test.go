@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashManagementKey(t *testing.T, key string) string {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash key: %v", err)
+	}
+	return string(hashed)
+}
+
+func TestManagementAPIKeysKeepDeclaredAccessProviders(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8080\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	jwtProvider := config.AccessProvider{
+		Name:   "sso",
+		Type:   config.AccessProviderTypeJWT,
+		Config: map[string]any{"issuer": "https://issuer.example.com", "jwks-url": "https://issuer.example.com/jwks"},
+	}
+	cfg := &config.Config{
+		RemoteManagement: config.RemoteManagement{AllowRemote: true, SecretKey: hashManagementKey(t, "admin-secret")},
+	}
+	cfg.Access.Providers = []config.AccessProvider{
+		jwtProvider,
+		{Name: config.DefaultAccessProviderName, Type: config.AccessProviderTypeConfigAPIKey, APIKeys: []string{"first-key"}},
+	}
+	h := management.NewHandler(cfg, configPath, nil)
+
+	r := gin.New()
+	mgmt := r.Group("/v0/management")
+	mgmt.Use(h.Middleware())
+	mgmt.PUT("/api-keys", h.PutAPIKeys)
+	mgmt.PATCH("/api-keys", h.PatchAPIKeys)
+	mgmt.DELETE("/api-keys", h.DeleteAPIKeys)
+
+	steps := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPut, "/api-keys", `["first-key","second-key"]`},
+		{http.MethodPatch, "/api-keys", `{"old":"second-key","new":"third-key"}`},
+		{http.MethodDelete, "/api-keys?value=third-key", ""},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, "/v0/management"+step.path, bytes.NewReader([]byte(step.body)))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s status = %d: %s", step.method, step.path, w.Code, w.Body.String())
+		}
+
+		if len(cfg.Access.Providers) != 1 || cfg.Access.Providers[0].Name != jwtProvider.Name {
+			t.Fatalf("%s %s: providers = %+v, want only the jwt provider", step.method, step.path, cfg.Access.Providers)
+		}
+		saved, err := config.LoadConfig(configPath)
+		if err != nil {
+			t.Fatalf("reload config: %v", err)
+		}
+		found := false
+		for _, provider := range saved.Access.Providers {
+			if provider.Type == config.AccessProviderTypeJWT && provider.Name == jwtProvider.Name {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s %s: jwt provider missing from saved config: %+v", step.method, step.path, saved.Access.Providers)
+		}
+	}
+}
+
+func TestManagementAPIKeysStoreHashedClientKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8080\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg := &config.Config{
+		RemoteManagement: config.RemoteManagement{AllowRemote: true, SecretKey: hashManagementKey(t, "admin-secret")},
+	}
+	h := management.NewHandler(cfg, configPath, nil)
+
+	r := gin.New()
+	mgmt := r.Group("/v0/management")
+	mgmt.Use(h.Middleware())
+	mgmt.PUT("/api-keys", h.PutAPIKeys)
+	mgmt.PATCH("/api-keys", h.PatchAPIKeys)
+	mgmt.DELETE("/api-keys", h.DeleteAPIKeys)
+	mgmt.GET("/api-keys", h.GetAPIKeys)
+	doStatus := func(want int, method, path, body string) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/v0/management"+path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("%s %s status = %d, want %d: %s", method, path, w.Code, want, w.Body.String())
+		}
+	}
+	do := func(method, path, body string) {
+		t.Helper()
+		doStatus(http.StatusOK, method, path, body)
+	}
+
+	do(http.MethodPut, "/api-keys", `["first-secret-key","second-secret-key"]`)
+	if len(cfg.APIKeys) != 0 || len(cfg.ClientKeys) != 2 {
+		t.Fatalf("api-keys = %v, client-keys = %+v; want two hashed client keys", cfg.APIKeys, cfg.ClientKeys)
+	}
+	secondID := config.ClientKeyIDFromHash(config.HashClientKey("second-secret-key"))
+	if cfg.ClientKeys[1].ID != secondID || cfg.ClientKeys[1].Hash != config.HashClientKey("second-secret-key") {
+		t.Fatalf("unexpected client key %+v", cfg.ClientKeys[1])
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(data), "first-secret-key") || strings.Contains(string(data), "second-secret-key") {
+		t.Fatalf("plaintext key persisted:\n%s", data)
+	}
+
+	do(http.MethodPut, "/api-keys", `["first-secret-key"]`)
+	if len(cfg.ClientKeys) != 2 {
+		t.Fatalf("re-adding a known key duplicated it: %+v", cfg.ClientKeys)
+	}
+
+	do(http.MethodPatch, "/api-keys", `{"old":"second-secret-key","new":"third-secret-key"}`)
+	if len(cfg.ClientKeys) != 2 || cfg.ClientKeys[1].ID != secondID || cfg.ClientKeys[1].Hash != config.HashClientKey("third-secret-key") {
+		t.Fatalf("patch should rotate the key in place: %+v", cfg.ClientKeys)
+	}
+
+	do(http.MethodDelete, "/api-keys?value=third-secret-key", "")
+	if len(cfg.ClientKeys) != 1 || cfg.ClientKeys[0].Hash != config.HashClientKey("first-secret-key") {
+		t.Fatalf("delete should remove the matching client key: %+v", cfg.ClientKeys)
+	}
+	doStatus(http.StatusNotFound, http.MethodDelete, "/api-keys?value=third-secret-key", "")
+	doStatus(http.StatusGone, http.MethodGet, "/api-keys", "")
+}
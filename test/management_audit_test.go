@@ -0,0 +1,119 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestManagementAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8080\n"), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	cfg := &config.Config{
+		RemoteManagement: config.RemoteManagement{
+			AllowRemote: true,
+			SecretKey:   hashManagementKey(t, "admin-secret"),
+			Keys: []config.ManagementKey{
+				{Name: "dashboard", Key: hashManagementKey(t, "viewer-key"), Role: config.ManagementRoleViewer},
+			},
+		},
+	}
+	h := management.NewHandler(cfg, configPath, nil)
+
+	r := gin.New()
+	mgmt := r.Group("/v0/management")
+	mgmt.Use(h.AuditMiddleware(), h.Middleware())
+	mgmt.PUT("/debug", h.PutDebug)
+	mgmt.GET("/audit", h.GetAuditLog)
+
+	do := func(method, path, key string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v0/management"+path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPut, "/debug", "admin-secret", []byte(`{"value":true}`)); w.Code != http.StatusOK {
+		t.Fatalf("PUT /debug status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/debug", "viewer-key", []byte(`{"value":false}`)); w.Code != http.StatusForbidden {
+		t.Fatalf("viewer PUT /debug status = %d, want 403", w.Code)
+	}
+	// Unauthenticated calls are not audited.
+	do(http.MethodPut, "/debug", "wrong-key", []byte(`{"value":false}`))
+
+	w := do(http.MethodGet, "/audit?limit=10", "viewer-key", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /audit status = %d: %s", w.Code, w.Body.String())
+	}
+	var page struct {
+		Entries []audit.Entry `json:"entries"`
+		Total   int           `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode audit page: %v", err)
+	}
+	if page.Total != 2 || len(page.Entries) != 2 {
+		t.Fatalf("audit page = %+v, want 2 entries", page)
+	}
+	denied, applied := page.Entries[0], page.Entries[1]
+	if denied.Identity != "dashboard" || denied.Outcome != audit.OutcomeFailure || denied.Status != http.StatusForbidden {
+		t.Fatalf("denied entry = %+v", denied)
+	}
+	if applied.Identity != "secret-key" || applied.Outcome != audit.OutcomeSuccess || applied.Route != "/v0/management/debug" {
+		t.Fatalf("applied entry = %+v", applied)
+	}
+	if len(applied.Changes) != 1 || !strings.Contains(applied.Changes[0], "debug: false -> true") {
+		t.Fatalf("applied changes = %v", applied.Changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, audit.FileName)); err != nil {
+		t.Fatalf("audit file not written: %v", err)
+	}
+}
+
+// recordingAuditPersister records the batches handed to PersistAuditLog.
+type recordingAuditPersister struct {
+	batches [][][]byte
+}
+
+func (p *recordingAuditPersister) PersistAuditLog(_ context.Context, _ string, records [][]byte) error {
+	p.batches = append(p.batches, records)
+	return nil
+}
+
+func TestAuditLogPersistsInBatches(t *testing.T) {
+	persister := &recordingAuditPersister{}
+	auditLog := audit.NewLog(filepath.Join(t.TempDir(), audit.FileName), persister)
+	for i := 0; i < 3; i++ {
+		if err := auditLog.Append(context.Background(), audit.Entry{Method: http.MethodPut, Route: "/v0/management/debug"}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if len(persister.batches) != 0 {
+		t.Fatalf("records should not be persisted one by one, got %d batches", len(persister.batches))
+	}
+	auditLog.Flush(context.Background())
+	if len(persister.batches) != 1 || len(persister.batches[0]) != 3 {
+		t.Fatalf("expected one batch of 3 records, got %d batches", len(persister.batches))
+	}
+	auditLog.Flush(context.Background())
+	if len(persister.batches) != 1 {
+		t.Fatalf("flushing without new records should not persist again")
+	}
+}